@@ -0,0 +1,533 @@
+// Package api contains the types of version 1 of the HTTP API. They are decoupled from the models in
+// the ruck package, which are also used for storage, and all fields are named in snake case.
+//
+// Within a version, fields and error codes are only added, never renamed or removed. Types of the ruck
+// package that are already named consistently (e.g. ruck.PersonalAccessToken) are used directly.
+package api
+
+import (
+	"time"
+
+	"github.com/coffeemakr/ruck"
+)
+
+const (
+	// Version is the version of the API described by this package.
+	Version = "v1"
+	// Prefix is the path prefix of all routes of the API version.
+	Prefix = "/api/" + Version
+)
+
+// Capabilities of a server, which depend on its configuration.
+const (
+	CapabilitySSO                  = "sso"
+	CapabilityRegistration         = "registration"
+	CapabilityInviteOnly           = "invite_only"
+	CapabilityEmailVerification    = "email_verification"
+	CapabilityTwoFactor            = "two_factor"
+	CapabilityPersonalAccessTokens = "personal_access_tokens"
+)
+
+// ServerInfo describes the version and features of the server.
+type ServerInfo struct {
+	Version      string   `json:"version"`
+	APIVersions  []string `json:"api_versions"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// HasCapability returns true if the server announces the capability.
+func (s *ServerInfo) HasCapability(capability string) bool {
+	for _, c := range s.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+type User struct {
+	Name          string `json:"name"`
+	EmailAddress  string `json:"email_address"`
+	EmailVerified bool   `json:"email_verified"`
+	Disabled      bool   `json:"disabled"`
+	DisplayName   string `json:"display_name,omitempty"`
+	TimeZone      string `json:"time_zone,omitempty"`
+	Language      string `json:"language,omitempty"`
+}
+
+func NewUser(user *ruck.User) *User {
+	if user == nil {
+		return nil
+	}
+	return &User{
+		Name:          user.Name,
+		EmailAddress:  user.EmailAddress,
+		EmailVerified: user.EmailVerified,
+		Disabled:      user.IsDisabled,
+		DisplayName:   user.DisplayName,
+		TimeZone:      user.TimeZone,
+		Language:      user.Language,
+	}
+}
+
+func (u *User) Model() *ruck.User {
+	if u == nil {
+		return nil
+	}
+	return &ruck.User{
+		Name:          u.Name,
+		EmailAddress:  u.EmailAddress,
+		EmailVerified: u.EmailVerified,
+		IsDisabled:    u.Disabled,
+		DisplayName:   u.DisplayName,
+		TimeZone:      u.TimeZone,
+		Language:      u.Language,
+	}
+}
+
+type AuthenticationResult struct {
+	Token             string    `json:"token,omitempty"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	RefreshToken      string    `json:"refresh_token,omitempty"`
+	User              *User     `json:"user,omitempty"`
+	TwoFactorRequired bool      `json:"two_factor_required,omitempty"`
+	TwoFactorToken    string    `json:"two_factor_token,omitempty"`
+}
+
+func NewAuthenticationResult(result *ruck.AuthenticationResult) *AuthenticationResult {
+	return &AuthenticationResult{
+		Token:             result.Token,
+		ExpiresAt:         result.ExpiresAt,
+		RefreshToken:      result.RefreshToken,
+		User:              NewUser(result.User),
+		TwoFactorRequired: result.TwoFactorRequired,
+		TwoFactorToken:    result.TwoFactorToken,
+	}
+}
+
+func (a *AuthenticationResult) Model() *ruck.AuthenticationResult {
+	return &ruck.AuthenticationResult{
+		Token:             a.Token,
+		ExpiresAt:         a.ExpiresAt,
+		RefreshToken:      a.RefreshToken,
+		User:              a.User.Model(),
+		TwoFactorRequired: a.TwoFactorRequired,
+		TwoFactorToken:    a.TwoFactorToken,
+	}
+}
+
+// LoginRequest contains the credentials of a user.
+type LoginRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+func NewLoginRequest(credentials *ruck.Credentials) *LoginRequest {
+	return &LoginRequest{
+		Name:     credentials.Name,
+		Password: string(credentials.Password),
+	}
+}
+
+func (l *LoginRequest) Model() *ruck.Credentials {
+	return &ruck.Credentials{
+		Name:     l.Name,
+		Password: []byte(l.Password),
+	}
+}
+
+type RegistrationRequest struct {
+	Name                 string `json:"name"`
+	Email                string `json:"email"`
+	Password             string `json:"password"`
+	PasswordConfirmation string `json:"password_confirmation"`
+	RegistrationCode     string `json:"registration_code,omitempty"`
+	GroupID              string `json:"group_id,omitempty"`
+}
+
+func NewRegistrationRequest(request *ruck.RegistrationRequest) *RegistrationRequest {
+	return &RegistrationRequest{
+		Name:                 request.Name,
+		Email:                request.Email,
+		Password:             string(request.Password),
+		PasswordConfirmation: string(request.PasswordConfirmation),
+		RegistrationCode:     request.RegistrationCode,
+		GroupID:              request.GroupID,
+	}
+}
+
+func (r *RegistrationRequest) Model() *ruck.RegistrationRequest {
+	return &ruck.RegistrationRequest{
+		Name:                 r.Name,
+		Email:                r.Email,
+		Password:             []byte(r.Password),
+		PasswordConfirmation: []byte(r.PasswordConfirmation),
+		RegistrationCode:     r.RegistrationCode,
+		GroupID:              r.GroupID,
+	}
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword      string `json:"current_password"`
+	Password             string `json:"password"`
+	PasswordConfirmation string `json:"password_confirmation"`
+}
+
+func NewPasswordChangeRequest(request *ruck.PasswordChangeRequest) *PasswordChangeRequest {
+	return &PasswordChangeRequest{
+		CurrentPassword:      string(request.CurrentPassword),
+		Password:             string(request.Password),
+		PasswordConfirmation: string(request.PasswordConfirmation),
+	}
+}
+
+func (p *PasswordChangeRequest) Model() *ruck.PasswordChangeRequest {
+	return &ruck.PasswordChangeRequest{
+		CurrentPassword:      []byte(p.CurrentPassword),
+		Password:             []byte(p.Password),
+		PasswordConfirmation: []byte(p.PasswordConfirmation),
+	}
+}
+
+type PasswordForgottenRequest struct {
+	Name string `json:"name"`
+}
+
+type PasswordResetRequest struct {
+	Token                string `json:"token"`
+	Password             string `json:"password"`
+	PasswordConfirmation string `json:"password_confirmation"`
+}
+
+func NewPasswordResetRequest(request *ruck.PasswordResetRequest) *PasswordResetRequest {
+	return &PasswordResetRequest{
+		Token:                request.Token,
+		Password:             string(request.Password),
+		PasswordConfirmation: string(request.PasswordConfirmation),
+	}
+}
+
+func (p *PasswordResetRequest) Model() *ruck.PasswordResetRequest {
+	return &ruck.PasswordResetRequest{
+		Token:                p.Token,
+		Password:             []byte(p.Password),
+		PasswordConfirmation: []byte(p.PasswordConfirmation),
+	}
+}
+
+// AccountUpdateRequest changes the profile of the user. Only the set fields are changed.
+type AccountUpdateRequest struct {
+	DisplayName *string `json:"display_name,omitempty"`
+	Email       *string `json:"email,omitempty"`
+	TimeZone    *string `json:"time_zone,omitempty"`
+	Language    *string `json:"language,omitempty"`
+}
+
+func NewAccountUpdateRequest(request *ruck.AccountUpdateRequest) *AccountUpdateRequest {
+	return &AccountUpdateRequest{
+		DisplayName: request.DisplayName,
+		Email:       request.Email,
+		TimeZone:    request.TimeZone,
+		Language:    request.Language,
+	}
+}
+
+func (a *AccountUpdateRequest) Model() *ruck.AccountUpdateRequest {
+	return &ruck.AccountUpdateRequest{
+		DisplayName: a.DisplayName,
+		Email:       a.Email,
+		TimeZone:    a.TimeZone,
+		Language:    a.Language,
+	}
+}
+
+type AccountDeletionRequest struct {
+	Password string `json:"password"`
+}
+
+type AccountExport struct {
+	User                 *User                      `json:"user"`
+	Groups               []*Group                   `json:"groups"`
+	Tasks                []*Task                    `json:"tasks"`
+	Executions           []*TaskExecution           `json:"executions"`
+	NotificationSettings *ruck.NotificationSettings `json:"notification_settings"`
+	PushSettings         *ruck.PushSettings         `json:"push_settings,omitempty"`
+}
+
+func NewAccountExport(export *ruck.AccountExport) *AccountExport {
+	executions := make([]*TaskExecution, 0, len(export.Executions))
+	for _, execution := range export.Executions {
+		executions = append(executions, NewTaskExecution(execution))
+	}
+	return &AccountExport{
+		User:                 NewUser(export.User),
+		Groups:               NewGroups(export.Groups),
+		Tasks:                NewTasks(export.Tasks),
+		Executions:           executions,
+		NotificationSettings: export.NotificationSettings,
+		PushSettings:         export.PushSettings,
+	}
+}
+
+type Group struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	MemberNames []string `json:"member_names"`
+	// ReminderLeadMinutes is the time before the due date at which the assignee is reminded of a
+	// task, 0 for the default of the server.
+	ReminderLeadMinutes int        `json:"reminder_lead_minutes"`
+	Escalation          Escalation `json:"escalation"`
+	// ClaimPolicy restricts which tasks the members can claim, empty for open and overdue tasks.
+	ClaimPolicy ruck.ClaimPolicy `json:"claim_policy,omitempty"`
+	// CoverPolicy decides about the turn of the assignee if another member completes a task, empty
+	// to keep the turn.
+	CoverPolicy ruck.CoverPolicy `json:"cover_policy,omitempty"`
+}
+
+// Escalation is the policy for overdue tasks of a group, steps with zero minutes are disabled.
+type Escalation struct {
+	// NotifyGroupAfterMinutes is the time after the due date at which all members are notified.
+	NotifyGroupAfterMinutes int `json:"notify_group_after_minutes"`
+	// ActAfterMinutes is the time after the due date at which the action is executed.
+	ActAfterMinutes int                   `json:"act_after_minutes"`
+	Action          ruck.EscalationAction `json:"action,omitempty"`
+	// Penalty is recorded in the balance of the assignee when the action is executed.
+	Penalty int `json:"penalty"`
+}
+
+func NewEscalation(policy ruck.EscalationPolicy) Escalation {
+	return Escalation{
+		NotifyGroupAfterMinutes: int(policy.NotifyGroupAfter / time.Minute),
+		ActAfterMinutes:         int(policy.ActAfter / time.Minute),
+		Action:                  policy.Action,
+		Penalty:                 policy.Penalty,
+	}
+}
+
+func (e Escalation) Model() ruck.EscalationPolicy {
+	return ruck.EscalationPolicy{
+		NotifyGroupAfter: time.Duration(e.NotifyGroupAfterMinutes) * time.Minute,
+		ActAfter:         time.Duration(e.ActAfterMinutes) * time.Minute,
+		Action:           e.Action,
+		Penalty:          e.Penalty,
+	}
+}
+
+func NewGroup(group *ruck.Group) *Group {
+	if group == nil {
+		return nil
+	}
+	return &Group{
+		ID:                  group.ID,
+		Name:                group.Name,
+		MemberNames:         group.MemberNames,
+		ReminderLeadMinutes: int(group.ReminderLeadTime / time.Minute),
+		Escalation:          NewEscalation(group.Escalation),
+		ClaimPolicy:         group.ClaimPolicy,
+		CoverPolicy:         group.CoverPolicy,
+	}
+}
+
+func NewGroups(groups []*ruck.Group) []*Group {
+	result := make([]*Group, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, NewGroup(group))
+	}
+	return result
+}
+
+func (g *Group) Model() *ruck.Group {
+	if g == nil {
+		return nil
+	}
+	return &ruck.Group{
+		ID:               g.ID,
+		Name:             g.Name,
+		MemberNames:      g.MemberNames,
+		ReminderLeadTime: time.Duration(g.ReminderLeadMinutes) * time.Minute,
+		Escalation:       g.Escalation.Model(),
+		ClaimPolicy:      g.ClaimPolicy,
+		CoverPolicy:      g.CoverPolicy,
+	}
+}
+
+type GroupRequest struct {
+	Name string `json:"name"`
+}
+
+// GroupUpdateRequest changes the fields of a group which are set.
+type GroupUpdateRequest struct {
+	Name                *string `json:"name,omitempty"`
+	ReminderLeadMinutes *int    `json:"reminder_lead_minutes,omitempty"`
+	// Escalation replaces the whole policy.
+	Escalation  *Escalation       `json:"escalation,omitempty"`
+	ClaimPolicy *ruck.ClaimPolicy `json:"claim_policy,omitempty"`
+	CoverPolicy *ruck.CoverPolicy `json:"cover_policy,omitempty"`
+}
+
+type IntervalUnit string
+
+const (
+	Days   IntervalUnit = "days"
+	Weeks  IntervalUnit = "weeks"
+	Months IntervalUnit = "months"
+	Years  IntervalUnit = "years"
+)
+
+// intervalUnits maps the units of the API to the stored units, which differ for months.
+var intervalUnits = map[IntervalUnit]ruck.IntervalUnit{
+	Days:   ruck.Days,
+	Weeks:  ruck.Weeks,
+	Months: ruck.Months,
+	Years:  ruck.Years,
+}
+
+func newIntervalUnit(unit ruck.IntervalUnit) IntervalUnit {
+	for apiUnit, modelUnit := range intervalUnits {
+		if modelUnit == unit {
+			return apiUnit
+		}
+	}
+	return IntervalUnit(unit)
+}
+
+func (u IntervalUnit) Model() ruck.IntervalUnit {
+	if unit, ok := intervalUnits[u]; ok {
+		return unit
+	}
+	// invalid units are rejected by the server
+	return ruck.IntervalUnit(u)
+}
+
+type Interval struct {
+	Unit   IntervalUnit `json:"unit"`
+	Amount uint32       `json:"amount"`
+}
+
+func NewInterval(interval ruck.Interval) Interval {
+	return Interval{
+		Unit:   newIntervalUnit(interval.Unit),
+		Amount: interval.Amount,
+	}
+}
+
+func (i Interval) Model() ruck.Interval {
+	return ruck.Interval{
+		Unit:   i.Unit.Model(),
+		Amount: i.Amount,
+	}
+}
+
+type Task struct {
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Interval      Interval       `json:"interval"`
+	LastExecution *TaskExecution `json:"last_execution,omitempty"`
+	GroupID       string         `json:"group_id"`
+	Group         *Group         `json:"group,omitempty"`
+	AssigneeName  string         `json:"assignee_name"`
+	Assignee      *User          `json:"assignee,omitempty"`
+	DueDate       time.Time      `json:"due_date"`
+	// Queue is the order of the turns of the members, the turn of the first member is the current one.
+	Queue []string `json:"queue,omitempty"`
+}
+
+func NewTask(task *ruck.Task) *Task {
+	if task == nil {
+		return nil
+	}
+	queue := task.Queue
+	if task.Group != nil {
+		queue = task.RotationQueue()
+	}
+	return &Task{
+		ID:            task.ID,
+		Name:          task.Name,
+		Interval:      NewInterval(task.Interval),
+		LastExecution: NewTaskExecution(task.LastExecution),
+		GroupID:       task.GroupID,
+		Group:         NewGroup(task.Group),
+		AssigneeName:  task.AssigneeName,
+		Assignee:      NewUser(task.Assignee),
+		DueDate:       task.DueDate,
+		Queue:         queue,
+	}
+}
+
+func NewTasks(tasks []*ruck.Task) []*Task {
+	result := make([]*Task, 0, len(tasks))
+	for _, task := range tasks {
+		result = append(result, NewTask(task))
+	}
+	return result
+}
+
+func (t *Task) Model() *ruck.Task {
+	if t == nil {
+		return nil
+	}
+	return &ruck.Task{
+		ID:            t.ID,
+		Name:          t.Name,
+		Interval:      t.Interval.Model(),
+		LastExecution: t.LastExecution.Model(),
+		GroupID:       t.GroupID,
+		Group:         t.Group.Model(),
+		AssigneeName:  t.AssigneeName,
+		Assignee:      t.Assignee.Model(),
+		DueDate:       t.DueDate,
+		Queue:         t.Queue,
+	}
+}
+
+// TaskRequest creates a task. A random member is assigned if AssigneeName is empty, unless Open is set.
+type TaskRequest struct {
+	Name         string   `json:"name"`
+	Interval     Interval `json:"interval"`
+	AssigneeName string   `json:"assignee_name,omitempty"`
+	// Open creates the task without assignee, so that anyone can take it.
+	Open bool `json:"open,omitempty"`
+}
+
+func (r *TaskRequest) Model() *ruck.TaskRequest {
+	return &ruck.TaskRequest{
+		Name:         r.Name,
+		Interval:     r.Interval.Model(),
+		AssigneeName: r.AssigneeName,
+		Open:         r.Open,
+	}
+}
+
+type TaskExecution struct {
+	ExecutorName string    `json:"executor_name"`
+	Executor     *User     `json:"executor,omitempty"`
+	Time         time.Time `json:"time"`
+	TaskID       string    `json:"task_id"`
+	Task         *Task     `json:"task,omitempty"`
+}
+
+func NewTaskExecution(execution *ruck.TaskExecution) *TaskExecution {
+	if execution == nil {
+		return nil
+	}
+	return &TaskExecution{
+		ExecutorName: execution.ExecutorName,
+		Executor:     NewUser(execution.Executor),
+		Time:         execution.Time,
+		TaskID:       execution.TaskId,
+		Task:         NewTask(execution.Task),
+	}
+}
+
+func (e *TaskExecution) Model() *ruck.TaskExecution {
+	if e == nil {
+		return nil
+	}
+	return &ruck.TaskExecution{
+		ExecutorName: e.ExecutorName,
+		Executor:     e.Executor.Model(),
+		Time:         e.Time,
+		TaskId:       e.TaskID,
+		Task:         e.Task.Model(),
+	}
+}
@@ -0,0 +1,12 @@
+package api
+
+import "time"
+
+// CalendarFeed contains the URLs of the iCalendar feed of a user. Anyone who knows the URLs can read
+// the tasks of the groups of the user until the feed is rotated or deleted.
+type CalendarFeed struct {
+	URL string `json:"url"`
+	// MineURL only contains the occurrences assigned to the user.
+	MineURL   string    `json:"mine_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
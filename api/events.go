@@ -0,0 +1,39 @@
+package api
+
+import "time"
+
+type EventType string
+
+const (
+	EventTaskCreated   EventType = "task.created"
+	EventTaskUpdated   EventType = "task.updated"
+	EventTaskCompleted EventType = "task.completed"
+	EventTaskDeleted   EventType = "task.deleted"
+	EventTaskDueSoon   EventType = "task.due_soon"
+	EventTaskOverdue   EventType = "task.overdue"
+	EventTaskEscalated EventType = "task.escalated"
+	EventTaskClaimed   EventType = "task.claimed"
+	EventMemberJoined  EventType = "group.member_joined"
+	EventMemberLeft    EventType = "group.member_left"
+	EventGroupDeleted  EventType = "group.deleted"
+)
+
+// Event is sent on the event stream if something changed in a group of the user.
+type Event struct {
+	ID      string    `json:"id"`
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	GroupID string    `json:"group_id"`
+	// Actor is the name of the user who caused the event.
+	Actor string `json:"actor,omitempty"`
+	// Task is set for the task events.
+	Task *Task `json:"task,omitempty"`
+	// Execution is set for completed tasks.
+	Execution *TaskExecution `json:"execution,omitempty"`
+	// Group is set for the group events.
+	Group *Group `json:"group,omitempty"`
+	// MemberName is the user who joined or left the group.
+	MemberName string `json:"member_name,omitempty"`
+	// History is set for escalations and claims.
+	History *TaskHistoryEntry `json:"history,omitempty"`
+}
@@ -0,0 +1,44 @@
+package api
+
+import "time"
+
+type TaskHistoryType string
+
+const (
+	HistoryCompleted TaskHistoryType = "completed"
+	// HistoryEscalated is recorded when the members were notified about the overdue task.
+	HistoryEscalated TaskHistoryType = "escalated"
+	// HistoryReassigned is recorded when the overdue task was assigned to the next member.
+	HistoryReassigned TaskHistoryType = "reassigned"
+	// HistoryOpened is recorded when the assignee of the overdue task was removed.
+	HistoryOpened TaskHistoryType = "opened"
+	// HistoryClaimed is recorded when a member took over the task.
+	HistoryClaimed TaskHistoryType = "claimed"
+)
+
+// TaskHistoryEntry records a change of the assignee or an escalation of a task.
+type TaskHistoryEntry struct {
+	ID      string          `json:"id"`
+	TaskID  string          `json:"task_id"`
+	GroupID string          `json:"group_id"`
+	Type    TaskHistoryType `json:"type"`
+	Time    time.Time       `json:"time"`
+	// Actor is the user who caused the entry, empty for the server.
+	Actor string `json:"actor,omitempty"`
+	// AssigneeName is the assignee after the entry, empty if anyone can take the task.
+	AssigneeName         string `json:"assignee_name,omitempty"`
+	PreviousAssigneeName string `json:"previous_assignee_name,omitempty"`
+	// DueDate is the due date of the task before the entry.
+	DueDate time.Time `json:"due_date"`
+	// Penalty was recorded in the balance of the previous assignee.
+	Penalty int `json:"penalty,omitempty"`
+}
+
+// MemberBalance shows how much a member contributed to the tasks of a group.
+type MemberBalance struct {
+	MemberName string `json:"member_name"`
+	Completed  int    `json:"completed"`
+	Penalties  int    `json:"penalties"`
+	// Balance is the number of completed tasks minus the penalties.
+	Balance int `json:"balance"`
+}
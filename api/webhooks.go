@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Headers of webhook requests. The signature is "sha256=" followed by the hex encoded HMAC-SHA256
+// of the timestamp, a dot and the body, keyed with the secret of the webhook.
+const (
+	WebhookSignatureHeader = "X-Ruck-Signature"
+	WebhookTimestampHeader = "X-Ruck-Timestamp"
+	WebhookEventHeader     = "X-Ruck-Event"
+	WebhookDeliveryHeader  = "X-Ruck-Delivery"
+)
+
+// EventPing is only sent to webhooks by the test endpoint.
+const EventPing EventType = "ping"
+
+// EventTypes are the event types webhooks can subscribe to.
+var EventTypes = []EventType{
+	EventTaskCreated,
+	EventTaskUpdated,
+	EventTaskCompleted,
+	EventTaskDeleted,
+	EventTaskDueSoon,
+	EventTaskOverdue,
+	EventTaskEscalated,
+	EventTaskClaimed,
+	EventMemberJoined,
+	EventMemberLeft,
+	EventGroupDeleted,
+}
+
+func (t EventType) IsValid() bool {
+	for _, eventType := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook receives the events of a group as HTTP POST requests.
+type Webhook struct {
+	ID      string `json:"id"`
+	GroupID string `json:"group_id"`
+	URL     string `json:"url"`
+	// Events are the subscribed event types, all events are sent if it is empty.
+	Events    []EventType `json:"events"`
+	CreatedAt time.Time   `json:"created_at"`
+	// Secret is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+// Accepts returns true if the webhook subscribed to the event type.
+func (w *Webhook) Accepts(eventType EventType) bool {
+	if eventType == EventPing || len(w.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range w.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookRequest struct {
+	URL string `json:"url"`
+	// Secret signs the requests, the server generates one if it is empty.
+	Secret string      `json:"secret,omitempty"`
+	Events []EventType `json:"events,omitempty"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an entry of the delivery log of a webhook.
+type WebhookDelivery struct {
+	ID        string                `json:"id"`
+	WebhookID string                `json:"webhook_id"`
+	EventID   string                `json:"event_id"`
+	EventType EventType             `json:"event_type"`
+	Status    WebhookDeliveryStatus `json:"status"`
+	Attempts  int                   `json:"attempts"`
+	// ResponseStatus is the HTTP status code of the last attempt.
+	ResponseStatus int        `json:"response_status,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastAttempt    *time.Time `json:"last_attempt,omitempty"`
+	NextAttempt    *time.Time `json:"next_attempt,omitempty"`
+}
+
+// WebhookSignature returns the value of the signature header for the request body.
+func WebhookSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -12,10 +12,11 @@ AMD64_BINARY=$(addprefix $(AMD64_BUILDDIR)/,$(BINARY))
 INSTALLED=$(DESTDIR)$(prefix)/bin/ruckd
 
 BINARIES=$(ARM_BINARY) $(AMD64_BINARY) 
-LDFLAGS=-s -w
+VERSION?=$(shell git describe --tags --always 2>/dev/null || echo dev)
+LDFLAGS=-s -w -X github.com/coffeemakr/ruck.Version=$(VERSION)
 
 
-SOURCES+=$(wildcard cmd/*.go) $(wildcard *.go) $(wildcard ../*.go)
+SOURCES+=$(wildcard cmd/*.go) $(wildcard *.go) $(wildcard ../*.go) $(wildcard ../api/*.go)
 GO=go build -ldflags "$(LDFLAGS)"
 .PHONY: all
 all: $(BINARIES)
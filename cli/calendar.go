@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/coffeemakr/ruck/api"
+)
+
+// GetCalendarFeed returns the URLs of the calendar feed. The error matches ErrNotFound if the feed
+// wasn't created yet.
+func (c *Client) GetCalendarFeed() (*api.CalendarFeed, error) {
+	var feed api.CalendarFeed
+	err := c.receiveJsonAuthenticated("GET", "/account/calendar", &feed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar feed: %w", err)
+	}
+	return &feed, nil
+}
+
+// RotateCalendarFeed creates the calendar feed or replaces its URLs, the previous ones stop working.
+func (c *Client) RotateCalendarFeed() (*api.CalendarFeed, error) {
+	var feed api.CalendarFeed
+	err := c.receiveJsonAuthenticated("POST", "/account/calendar", &feed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate calendar feed: %w", err)
+	}
+	return &feed, nil
+}
+
+func (c *Client) DeleteCalendarFeed() error {
+	err := c.sendAuthenticated("DELETE", "/account/calendar")
+	if err != nil {
+		return fmt.Errorf("failed to delete calendar feed: %w", err)
+	}
+	return nil
+}
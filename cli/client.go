@@ -6,17 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
 
 	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
 )
 
 var (
 	ErrNoTokenSaved = errors.New("no saved token")
+	ErrStaticToken  = errors.New("the token is set by the environment and can't be changed")
 	ErrNotFound     = errors.New("item not found")
+	// ErrUnsupportedServer is returned if the server doesn't support the API version of the client.
+	ErrUnsupportedServer = errors.New("the server doesn't support API " + api.Version + ", please update ruckd")
 )
 
 type Client struct {
@@ -24,10 +27,11 @@ type Client struct {
 	Client        *http.Client
 	TokenStore    TokenStore
 	token         string
+	serverInfo    *api.ServerInfo
 }
 
 func (c *Client) getUrl(relativeUrl string) string {
-	return c.Configuration.BaseURL + relativeUrl
+	return c.Configuration.BaseURL + api.Prefix + relativeUrl
 }
 
 func (c *Client) newRequest(method string, relativeUrl string, authenticationToken string, body io.Reader) (*http.Request, error) {
@@ -43,25 +47,7 @@ func (c *Client) newRequest(method string, relativeUrl string, authenticationTok
 
 func checkResponse(response *http.Response) error {
 	if response.StatusCode >= 300 || response.StatusCode < 100 {
-		if response.StatusCode == 404 {
-			return ErrNotFound
-		}
-		contentType := response.Header.Get("Content-Type")
-		var description string
-		if contentType == "application/json" {
-			var jsonError map[string]interface{}
-			decoder := json.NewDecoder(response.Body)
-			err := decoder.Decode(&jsonError)
-			if err == nil {
-				description = jsonError["description"].(string)
-			}
-		} else {
-			descriptionBytes, err := ioutil.ReadAll(response.Body)
-			if err == nil {
-				description = string(descriptionBytes[:])
-			}
-		}
-		return fmt.Errorf("request failed with status code %d \n\n%s", response.StatusCode, description)
+		return newAPIError(response)
 	}
 	return nil
 }
@@ -69,16 +55,16 @@ func checkResponse(response *http.Response) error {
 func (c *Client) sendJson(method string, relativeUrl string, authenticationToken string, body interface{}) (*http.Response, error) {
 	bodyBytes, err := json.Marshal(body)
 	if err != nil {
-		return nil, fmt.Errorf("JSON creation failed: %s", err)
+		return nil, fmt.Errorf("JSON creation failed: %w", err)
 	}
 	req, err := c.newRequest(method, relativeUrl, authenticationToken, bytes.NewReader(bodyBytes))
 	if err != nil {
-		return nil, fmt.Errorf("request creation failed: %s", err)
+		return nil, fmt.Errorf("request creation failed: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	response, err := c.Client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request sending failed: %s", err)
+		return nil, fmt.Errorf("request sending failed: %w", err)
 	}
 	if err := checkResponse(response); err != nil {
 		return nil, err
@@ -86,60 +72,176 @@ func (c *Client) sendJson(method string, relativeUrl string, authenticationToken
 	return response, nil
 }
 
-func (c *Client) receiveJsonAuthenticated(method string, relativeUrl string, result interface{}) error {
+// doAuthenticated sends a request with the access token. If the server rejects the token,
+// it is refreshed and the request is sent again.
+func (c *Client) doAuthenticated(method string, relativeUrl string, body []byte) (*http.Response, error) {
 	token, err := c.Token()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	req, err := c.newRequest(method, relativeUrl, token, nil)
+	response, err := c.doWithToken(method, relativeUrl, token, body)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if response.StatusCode != http.StatusUnauthorized {
+		return response, nil
+	}
+	response.Body.Close()
+	if err := c.Refresh(); err != nil {
+		return nil, fmt.Errorf("session expired, please log in again: %w", err)
+	}
+	return c.doWithToken(method, relativeUrl, c.token, body)
+}
+
+func (c *Client) doWithToken(method string, relativeUrl string, token string, body []byte) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := c.newRequest(method, relativeUrl, token, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("request creation failed: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 	response, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request sending failed: %w", err)
+	}
+	return response, nil
+}
+
+func (c *Client) receiveJsonAuthenticated(method string, relativeUrl string, result interface{}) error {
+	response, err := c.doAuthenticated(method, relativeUrl, nil)
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 	if err := checkResponse(response); err != nil {
 		return err
 	}
 	// Decode response
 	err = json.NewDecoder(response.Body).Decode(result)
 	if err != nil {
-		return fmt.Errorf("parsing response: %s", err)
+		return fmt.Errorf("parsing response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) sendAndReceiveJsonAuthenticated(method string, relativeUrl string, body interface{}, result interface{}) error {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("JSON creation failed: %w", err)
+	}
+	response, err := c.doAuthenticated(method, relativeUrl, bodyBytes)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+	if err := checkResponse(response); err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	if result == nil {
+		return nil
+	}
+	err = json.NewDecoder(response.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("failed to read response JSON: %w", err)
 	}
 	return nil
 }
 
+func (c *Client) sendAuthenticated(method string, relativeUrl string) error {
+	response, err := c.doAuthenticated(method, relativeUrl, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return checkResponse(response)
+}
+
 func (c *Client) sendAndReceiveJson(method string, relativeUrl string, authenticationToken string, body interface{}, result interface{}) error {
 	response, err := c.sendJson(method, relativeUrl, authenticationToken, body)
 	if err != nil {
-		return fmt.Errorf("request failed: %s", err)
+		return fmt.Errorf("request failed: %w", err)
 	}
 	// Decode response
 	err = json.NewDecoder(response.Body).Decode(result)
 	if err != nil {
-		return fmt.Errorf("failed to read response JSON: %s", err)
+		return fmt.Errorf("failed to read response JSON: %w", err)
 	}
 	return nil
 }
 
-func (c *Client) Login(credentials *ruck.Credentials) error {
-	var authenticationResult ruck.AuthenticationResult
-	err := c.sendAndReceiveJson("POST", "/login", "", credentials, &authenticationResult)
+// Login authenticates with the credentials. If the account uses two-factor authentication,
+// readCode is called to ask for the one-time code.
+func (c *Client) Login(credentials *ruck.Credentials, readCode func() (string, error)) error {
+	var authenticationResult api.AuthenticationResult
+	err := c.sendAndReceiveJson("POST", "/login", "", api.NewLoginRequest(credentials), &authenticationResult)
+	if err != nil {
+		return err
+	}
+	if authenticationResult.TwoFactorRequired {
+		code, err := readCode()
+		if err != nil {
+			return err
+		}
+		request := &ruck.TwoFactorLoginRequest{
+			Token: authenticationResult.TwoFactorToken,
+			Code:  code,
+		}
+		authenticationResult = api.AuthenticationResult{}
+		err = c.sendAndReceiveJson("POST", "/login/2fa", "", request, &authenticationResult)
+		if err != nil {
+			return err
+		}
+	}
+	return c.saveAuthenticationResult(&authenticationResult)
+}
+
+func (c *Client) saveAuthenticationResult(result *api.AuthenticationResult) error {
+	c.token = result.Token
+	err := c.TokenStore.SaveToken(result.Token)
+	if err != nil {
+		return err
+	}
+	return c.TokenStore.SaveRefreshToken(result.RefreshToken)
+}
+
+// Refresh exchanges the stored refresh token for a new access token.
+func (c *Client) Refresh() error {
+	var authenticationResult api.AuthenticationResult
+	refreshToken, err := c.TokenStore.GetRefreshToken()
 	if err != nil {
 		return err
 	}
-	err = c.TokenStore.SaveToken(authenticationResult.Token)
+	request := ruck.RefreshRequest{RefreshToken: refreshToken}
+	err = c.sendAndReceiveJson("POST", "/token/refresh", "", &request, &authenticationResult)
 	if err != nil {
 		return err
 	}
+	return c.saveAuthenticationResult(&authenticationResult)
+}
+
+// Logout revokes the tokens on the server and removes them from the token store.
+// The tokens are removed even if the server can't be reached.
+func (c *Client) Logout() error {
+	err := c.sendAuthenticated("POST", "/logout")
+	c.token = ""
+	if clearErr := c.TokenStore.Clear(); clearErr != nil {
+		return clearErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke token on server: %w", err)
+	}
 	return nil
 }
 
 func (c *Client) Register(request *ruck.RegistrationRequest) (*ruck.User, error) {
-	var user ruck.User
-	err := c.sendAndReceiveJson("POST", "/register", "", request, &user)
-	return &user, err
+	var user api.User
+	err := c.sendAndReceiveJson("POST", "/register", "", api.NewRegistrationRequest(request), &user)
+	return user.Model(), err
 }
 
 func (c *Client) LoadToken() error {
@@ -152,26 +254,34 @@ func (c *Client) LoadToken() error {
 }
 
 func (c *Client) CreateGroup(name string) (*ruck.Group, error) {
-	group := ruck.Group{
-		Name: name,
+	var group api.Group
+	err := c.sendAndReceiveJsonAuthenticated("POST", "/groups", &api.GroupRequest{Name: name}, &group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group: %w", err)
 	}
-	token, err := c.Token()
+	return group.Model(), nil
+}
+
+func (c *Client) ListGroup() ([]*ruck.Group, error) {
+	var groups []*api.Group
+	err := c.receiveJsonAuthenticated("GET", "/groups", &groups)
 	if err != nil {
 		return nil, err
 	}
-	err = c.sendAndReceiveJson("POST", "/groups", token, &group, &group)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create group: %s", err)
+	results := make([]*ruck.Group, 0, len(groups))
+	for _, group := range groups {
+		results = append(results, group.Model())
 	}
-	return &group, nil
+	return results, nil
 }
 
-func (c *Client) ListGroup() (results []*ruck.Group, err error) {
-	err = c.receiveJsonAuthenticated("GET", "/groups", &results)
+func (c *Client) UpdateGroup(groupId string, request *api.GroupUpdateRequest) (*ruck.Group, error) {
+	var group api.Group
+	err := c.sendAndReceiveJsonAuthenticated("PATCH", joinUrl("groups", groupId), request, &group)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to update group: %w", err)
 	}
-	return results, nil
+	return group.Model(), nil
 }
 
 func (c *Client) Token() (string, error) {
@@ -191,82 +301,280 @@ func joinUrl(parts ...string) string {
 	return "/" + path.Join(parts...)
 }
 
-func (c *Client) send(method string, relativeUrl string, authenticationToken string) error {
-	request, err := c.newRequest(method, relativeUrl, authenticationToken, nil)
+func (c *Client) DeleteGroupByID(id string) error {
+	err := c.sendAuthenticated("DELETE", joinUrl("groups", id))
 	if err != nil {
-		return err
+		return fmt.Errorf("group deletion failed: %w", err)
 	}
-	resp, err := c.Client.Do(request)
+	return nil
+}
+
+func (c *Client) JoinGroup(groupId string) error {
+	err := c.sendAuthenticated("POST", joinUrl("groups", groupId, "join"))
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to join group: %w", err)
 	}
-	if resp.StatusCode >= 300 || resp.StatusCode < 100 {
-		return fmt.Errorf("request failed with status '%s'", resp.Status)
+	return nil
+}
+
+// CreateTask creates the task in its group. If open is set, the task has no assignee and anyone can
+// take it, otherwise a random member is assigned if the task has no assignee.
+func (c *Client) CreateTask(task *ruck.Task, open bool) error {
+	groupId := task.GroupID
+	if groupId == "" {
+		return errors.New("group ID not set")
+	}
+	request := api.TaskRequest{
+		Name:         task.Name,
+		Interval:     api.NewInterval(task.Interval),
+		AssigneeName: task.AssigneeName,
+		Open:         open,
+	}
+	var created api.Task
+	err := c.sendAndReceiveJsonAuthenticated("POST", joinUrl("groups", groupId, "tasks"), &request, &created)
+	if err != nil {
+		return fmt.Errorf("creation of task failed: %w", err)
 	}
+	*task = *created.Model()
 	return nil
 }
 
-func (c *Client) DeleteGroupByID(id string) error {
-	token, err := c.Token()
+func (c *Client) GetTaskList() ([]*ruck.Task, error) {
+	var tasks []*api.Task
+	err := c.receiveJsonAuthenticated("GET", "/tasks", &tasks)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to get list of tasks: %w", err)
+	}
+	results := make([]*ruck.Task, 0, len(tasks))
+	for _, task := range tasks {
+		results = append(results, task.Model())
 	}
-	err = c.send("DELETE", joinUrl("groups", id), token)
+	return results, nil
+}
+
+// GetOpenTasks returns the tasks without assignee which anyone can claim.
+func (c *Client) GetOpenTasks() ([]*ruck.Task, error) {
+	var tasks []*api.Task
+	err := c.receiveJsonAuthenticated("GET", "/tasks?open=true", &tasks)
 	if err != nil {
-		return fmt.Errorf("group deletion failed: %s", err)
+		return nil, fmt.Errorf("failed to get open tasks: %w", err)
 	}
-	return nil
+	results := make([]*ruck.Task, 0, len(tasks))
+	for _, task := range tasks {
+		results = append(results, task.Model())
+	}
+	return results, nil
 }
 
-func (c *Client) JoinGroup(groupId string) error {
-	token, err := c.Token()
+func (c *Client) GetTaskDetails(taskId string) (*ruck.Task, error) {
+	var task api.Task
+	var err error
+	err = c.receiveJsonAuthenticated("GET", joinUrl("tasks", taskId), &task)
 	if err != nil {
-		return err
+		err = fmt.Errorf("failed to get task: %w", err)
+		return nil, err
+	}
+	return task.Model(), nil
+}
+
+func (c *Client) CompleteTask(taskID string) (*ruck.TaskExecution, error) {
+	var execution api.TaskExecution
+	err := c.receiveJsonAuthenticated("POST", joinUrl("tasks", taskID, "complete"), &execution)
+	if err != nil {
+		return nil, err
+	}
+	return execution.Model(), nil
+}
+
+// ClaimTask assigns the task to the user.
+func (c *Client) ClaimTask(taskID string) (*ruck.Task, error) {
+	var task api.Task
+	err := c.receiveJsonAuthenticated("POST", joinUrl("tasks", taskID, "claim"), &task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim task: %w", err)
+	}
+	return task.Model(), nil
+}
+
+func (c *Client) VerifyEmail(token string) (*ruck.User, error) {
+	var user api.User
+	body := map[string]string{"token": token}
+	err := c.sendAndReceiveJson("POST", "/verify-email", "", body, &user)
+	if err != nil {
+		return nil, fmt.Errorf("email verification failed: %w", err)
 	}
-	err = c.send("POST", joinUrl("groups", groupId, "join"), token)
+	return user.Model(), nil
+}
+
+func (c *Client) ResendVerificationEmail(credentials *ruck.Credentials) error {
+	_, err := c.sendJson("POST", "/verify-email/resend", "", api.NewLoginRequest(credentials))
 	if err != nil {
-		return fmt.Errorf("failed to join group: %s", err)
+		return fmt.Errorf("failed to resend verification mail: %w", err)
 	}
 	return nil
 }
 
-func (c *Client) CreateTask(task *ruck.Task) error {
-	token, err := c.Token()
+// ChangePassword changes the password and stores the new token, because the old one is invalidated.
+func (c *Client) ChangePassword(request *ruck.PasswordChangeRequest) error {
+	var authenticationResult api.AuthenticationResult
+	err := c.sendAndReceiveJsonAuthenticated("POST", "/account/password", api.NewPasswordChangeRequest(request), &authenticationResult)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to change password: %w", err)
 	}
-	groupId := task.GroupID
-	if groupId == "" {
-		return errors.New("group ID not set")
+	return c.saveAuthenticationResult(&authenticationResult)
+}
+
+func (c *Client) ForgotPassword(name string) error {
+	_, err := c.sendJson("POST", "/password/forgot", "", &api.PasswordForgottenRequest{Name: name})
+	if err != nil {
+		return fmt.Errorf("failed to request password reset: %w", err)
 	}
-	err = c.sendAndReceiveJson("POST", joinUrl("groups", groupId, "tasks"), token, task, task)
+	return nil
+}
+
+func (c *Client) ResetPassword(request *ruck.PasswordResetRequest) error {
+	_, err := c.sendJson("POST", "/password/reset", "", api.NewPasswordResetRequest(request))
 	if err != nil {
-		return fmt.Errorf("creation of task failed: %s", err)
+		return fmt.Errorf("failed to reset password: %w", err)
 	}
 	return nil
 }
 
-func (c *Client) GetTaskList() (tasks []*ruck.Task, err error) {
-	err = c.receiveJsonAuthenticated("GET", "/tasks", &tasks)
+func (c *Client) CreatePersonalAccessToken(name string, scope ruck.TokenScope) (*ruck.PersonalAccessToken, error) {
+	var token ruck.PersonalAccessToken
+	request := ruck.PersonalAccessTokenRequest{
+		Name:  name,
+		Scope: scope,
+	}
+	err := c.sendAndReceiveJsonAuthenticated("POST", "/account/tokens", &request, &token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access token: %w", err)
+	}
+	return &token, nil
+}
+
+func (c *Client) ListPersonalAccessTokens() (tokens []*ruck.PersonalAccessToken, err error) {
+	err = c.receiveJsonAuthenticated("GET", "/account/tokens", &tokens)
 	if err != nil {
-		err = fmt.Errorf("failed to get list of tasks: %s", err)
+		err = fmt.Errorf("failed to get list of access tokens: %w", err)
 	}
 	return
 }
 
-func (c *Client) GetTaskDetails(taskId string) (*ruck.Task, error) {
-	var task ruck.Task
-	var err error
-	err = c.receiveJsonAuthenticated("GET", joinUrl("tasks", taskId), &task)
+func (c *Client) RevokePersonalAccessToken(id string) error {
+	err := c.sendAuthenticated("DELETE", joinUrl("account", "tokens", id))
 	if err != nil {
-		err = fmt.Errorf("failed to get task: %s", err)
-		return nil, err
+		return fmt.Errorf("failed to revoke access token: %w", err)
 	}
-	return &task, nil
+	return nil
 }
 
-func (c *Client) CompleteTask(taskID string) (execution *ruck.TaskExecution, err error) {
-	execution = new(ruck.TaskExecution)
-	err = c.receiveJsonAuthenticated("POST", joinUrl("tasks", taskID, "complete"), execution)
-	return
+func (c *Client) EnrollTwoFactor() (*ruck.TwoFactorEnrollment, error) {
+	var enrollment ruck.TwoFactorEnrollment
+	err := c.receiveJsonAuthenticated("POST", "/account/2fa", &enrollment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enroll two-factor authentication: %w", err)
+	}
+	return &enrollment, nil
+}
+
+func (c *Client) ConfirmTwoFactor(code string) ([]string, error) {
+	var recoveryCodes ruck.RecoveryCodes
+	err := c.sendAndReceiveJsonAuthenticated("POST", "/account/2fa/confirm", &ruck.TwoFactorCodeRequest{Code: code}, &recoveryCodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	return recoveryCodes.Codes, nil
+}
+
+func (c *Client) DisableTwoFactor(code string) error {
+	err := c.sendAndReceiveJsonAuthenticated("POST", "/account/2fa/disable", &ruck.TwoFactorCodeRequest{Code: code}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) GetAccount() (*ruck.User, error) {
+	var user api.User
+	err := c.receiveJsonAuthenticated("GET", "/account", &user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	return user.Model(), nil
+}
+
+func (c *Client) UpdateAccount(request *ruck.AccountUpdateRequest) (*ruck.User, error) {
+	var user api.User
+	err := c.sendAndReceiveJsonAuthenticated("PATCH", "/account", api.NewAccountUpdateRequest(request), &user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update account: %w", err)
+	}
+	return user.Model(), nil
+}
+
+// ExportAccount returns the JSON export of all data of the account.
+func (c *Client) ExportAccount() (json.RawMessage, error) {
+	var export json.RawMessage
+	err := c.receiveJsonAuthenticated("GET", "/account/export", &export)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export account: %w", err)
+	}
+	return export, nil
+}
+
+// DeleteAccount deletes the account on the server and removes the stored tokens.
+func (c *Client) DeleteAccount(password []byte) error {
+	err := c.sendAndReceiveJsonAuthenticated("DELETE", "/account", &api.AccountDeletionRequest{Password: string(password)}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+	c.token = ""
+	return c.TokenStore.Clear()
+}
+
+func (c *Client) GetNotificationSettings() (*ruck.NotificationSettings, error) {
+	var settings ruck.NotificationSettings
+	err := c.receiveJsonAuthenticated("GET", "/account/notifications", &settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification settings: %w", err)
+	}
+	return &settings, nil
+}
+
+func (c *Client) UpdateNotificationSettings(request *ruck.NotificationSettingsUpdateRequest) (*ruck.NotificationSettings, error) {
+	var settings ruck.NotificationSettings
+	err := c.sendAndReceiveJsonAuthenticated("PATCH", "/account/notifications", request, &settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notification settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// ServerInfo returns the version and capabilities of the server. It returns ErrUnsupportedServer if
+// the server doesn't support the API version of the client.
+func (c *Client) ServerInfo() (*api.ServerInfo, error) {
+	if c.serverInfo != nil {
+		return c.serverInfo, nil
+	}
+	var serverInfo api.ServerInfo
+	request, err := c.newRequest("GET", "/version", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.Client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer response.Body.Close()
+	if err := checkResponse(response); errors.Is(err, ErrNotFound) {
+		return nil, ErrUnsupportedServer
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get server version: %w", err)
+	}
+	if err := json.NewDecoder(response.Body).Decode(&serverInfo); err != nil {
+		return nil, fmt.Errorf("failed to read server version: %w", err)
+	}
+	c.serverInfo = &serverInfo
+	return c.serverInfo, nil
 }
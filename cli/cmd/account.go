@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/spf13/cobra"
+)
+
+var (
+	accountCommand = &cobra.Command{
+		Use: "account",
+	}
+	accountPasswdCommand = &cobra.Command{
+		Use:   "passwd",
+		Short: "Change the password",
+		Run:   runChangePassword,
+		Args:  cobra.NoArgs,
+	}
+	accountForgotPasswordCommand = &cobra.Command{
+		Use:   "forgot-password",
+		Short: "Request a password reset token by mail",
+		Run:   runForgotPassword,
+		Args:  cobra.NoArgs,
+	}
+	accountResetPasswordCommand = &cobra.Command{
+		Use:   "reset-password TOKEN",
+		Short: "Set a new password with a reset token",
+		Run:   runResetPassword,
+		Args:  cobra.ExactArgs(1),
+	}
+	accountShowCommand = &cobra.Command{
+		Use:   "show",
+		Short: "Show the profile of the account",
+		Run:   runShowAccount,
+		Args:  cobra.NoArgs,
+	}
+	accountUpdateCommand = &cobra.Command{
+		Use:   "update",
+		Short: "Change the display name, email address, time zone or language",
+		Run:   runUpdateAccount,
+		Args:  cobra.NoArgs,
+	}
+	accountExportCommand = &cobra.Command{
+		Use:   "export",
+		Short: "Export all data of the account as JSON",
+		Run:   runExportAccount,
+		Args:  cobra.NoArgs,
+	}
+	accountDeleteCommand = &cobra.Command{
+		Use:   "delete",
+		Short: "Delete the account, tasks assigned to you are assigned to the next member",
+		Run:   runDeleteAccount,
+		Args:  cobra.NoArgs,
+	}
+	accountNotificationsCommand = &cobra.Command{
+		Use:   "notifications",
+		Short: "Show or change which notification mails you receive",
+		Run:   runNotifications,
+		Args:  cobra.NoArgs,
+	}
+	accountTwoFactorCommand = &cobra.Command{
+		Use:   "2fa",
+		Short: "Manage the two-factor authentication",
+	}
+	accountTwoFactorEnableCommand = &cobra.Command{
+		Use:   "enable",
+		Short: "Enable two-factor authentication with an authenticator app",
+		Run:   runEnableTwoFactor,
+		Args:  cobra.NoArgs,
+	}
+	accountTwoFactorDisableCommand = &cobra.Command{
+		Use:   "disable",
+		Short: "Disable two-factor authentication",
+		Run:   runDisableTwoFactor,
+		Args:  cobra.NoArgs,
+	}
+)
+
+var accountExportOutput string
+
+func init() {
+	accountTwoFactorCommand.AddCommand(accountTwoFactorEnableCommand, accountTwoFactorDisableCommand)
+	accountUpdateCommand.Flags().String("display-name", "", "The name shown to other users")
+	accountUpdateCommand.Flags().String("email", "", "The new email address, which must be verified again")
+	accountUpdateCommand.Flags().String("timezone", "", "IANA time zone, e.g. Europe/Zurich")
+	accountUpdateCommand.Flags().String("language", "", "Preferred language, e.g. de-CH")
+	accountNotificationsCommand.Flags().Bool("reminders", false, "Mail reminders when your tasks are due soon or overdue")
+	accountNotificationsCommand.Flags().Bool("daily-digest", false, "Mail your due tasks every morning")
+	accountNotificationsCommand.Flags().Bool("weekly-summary", false, "Mail a summary of your groups every Monday")
+	accountExportCommand.Flags().StringVarP(&accountExportOutput, "output", "o", "", "Write the export to the file instead of stdout")
+	accountCommand.AddCommand(accountShowCommand, accountUpdateCommand, accountExportCommand, accountDeleteCommand,
+		accountNotificationsCommand, accountPasswdCommand, accountForgotPasswordCommand, accountResetPasswordCommand, accountTwoFactorCommand)
+}
+
+// readNewPassword asks for a new password until the confirmation matches.
+func readNewPassword() (password []byte, err error) {
+	for {
+		fmt.Print("New Password          :")
+		password, err = readPassword()
+		fmt.Println()
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Print("Password Confirmation :")
+		passwordConfirmation, err := readPassword()
+		fmt.Println()
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(password, passwordConfirmation) {
+			return password, nil
+		}
+		fmt.Println("\nPassword don't match. Please try again.")
+	}
+}
+
+func runChangePassword(cmd *cobra.Command, args []string) {
+	fmt.Print("Current Password      :")
+	currentPassword, err := readPassword()
+	fmt.Println()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	password, err := readNewPassword()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = client.ChangePassword(&ruck.PasswordChangeRequest{
+		CurrentPassword:      currentPassword,
+		Password:             password,
+		PasswordConfirmation: password,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Password changed.")
+}
+
+func runForgotPassword(cmd *cobra.Command, args []string) {
+	fmt.Print("Name    : ")
+	name, err := readPlainText()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := client.ForgotPassword(name); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("If the account has a verified email address, a reset token was sent to it.")
+}
+
+func runResetPassword(cmd *cobra.Command, args []string) {
+	password, err := readNewPassword()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = client.ResetPassword(&ruck.PasswordResetRequest{
+		Token:                args[0],
+		Password:             password,
+		PasswordConfirmation: password,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Password changed. You can log in now.")
+}
+
+func runEnableTwoFactor(cmd *cobra.Command, args []string) {
+	enrollment, err := client.EnrollTwoFactor()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Add the following account to your authenticator app:")
+	fmt.Println()
+	fmt.Println("  " + enrollment.URI)
+	fmt.Println()
+	fmt.Println("Or enter the secret manually: " + enrollment.Secret)
+	fmt.Print("TOTP code: ")
+	code, err := readPlainText()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	recoveryCodes, err := client.ConfirmTwoFactor(code)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Two-factor authentication is enabled.")
+	fmt.Println("Store these recovery codes in a safe place, each can be used once instead of a TOTP code:")
+	for _, recoveryCode := range recoveryCodes {
+		fmt.Println("  " + recoveryCode)
+	}
+}
+
+func runDisableTwoFactor(cmd *cobra.Command, args []string) {
+	code, err := readTwoFactorCode()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := client.DisableTwoFactor(code); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Two-factor authentication is disabled.")
+}
+
+func printAccount(user *ruck.User) {
+	fmt.Printf("Name         : %s\n", user.Name)
+	fmt.Printf("Display Name : %s\n", user.DisplayName)
+	fmt.Printf("Email        : %s (verified: %t)\n", user.EmailAddress, user.EmailVerified)
+	fmt.Printf("Time Zone    : %s\n", user.TimeZone)
+	fmt.Printf("Language     : %s\n", user.Language)
+}
+
+func runShowAccount(cmd *cobra.Command, args []string) {
+	user, err := client.GetAccount()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	printAccount(user)
+}
+
+// changedFlag returns a pointer to the value if the flag was set, so it can be cleared with an empty value.
+func changedFlag(cmd *cobra.Command, name string) *string {
+	if !cmd.Flags().Changed(name) {
+		return nil
+	}
+	value, err := cmd.Flags().GetString(name)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return &value
+}
+
+func runUpdateAccount(cmd *cobra.Command, args []string) {
+	request := &ruck.AccountUpdateRequest{
+		DisplayName: changedFlag(cmd, "display-name"),
+		Email:       changedFlag(cmd, "email"),
+		TimeZone:    changedFlag(cmd, "timezone"),
+		Language:    changedFlag(cmd, "language"),
+	}
+	user, err := client.UpdateAccount(request)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	printAccount(user)
+	if request.Email != nil && !user.EmailVerified {
+		fmt.Println("Please verify your new email address with the link sent to it.")
+	}
+}
+
+// changedBoolFlag returns a pointer to the value if the flag was set.
+func changedBoolFlag(cmd *cobra.Command, name string) *bool {
+	if !cmd.Flags().Changed(name) {
+		return nil
+	}
+	value, err := cmd.Flags().GetBool(name)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return &value
+}
+
+func runNotifications(cmd *cobra.Command, args []string) {
+	var settings *ruck.NotificationSettings
+	var err error
+	request := &ruck.NotificationSettingsUpdateRequest{
+		Reminders:     changedBoolFlag(cmd, "reminders"),
+		DailyDigest:   changedBoolFlag(cmd, "daily-digest"),
+		WeeklySummary: changedBoolFlag(cmd, "weekly-summary"),
+	}
+	if request.Reminders == nil && request.DailyDigest == nil && request.WeeklySummary == nil {
+		settings, err = client.GetNotificationSettings()
+	} else {
+		settings, err = client.UpdateNotificationSettings(request)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Reminders      : %t\n", settings.Reminders)
+	fmt.Printf("Daily Digest   : %t\n", settings.DailyDigest)
+	fmt.Printf("Weekly Summary : %t\n", settings.WeeklySummary)
+}
+
+func runExportAccount(cmd *cobra.Command, args []string) {
+	export, err := client.ExportAccount()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var formatted bytes.Buffer
+	if err := json.Indent(&formatted, export, "", "  "); err != nil {
+		log.Fatalln(err)
+	}
+	formatted.WriteString("\n")
+	if accountExportOutput == "" {
+		_, err = formatted.WriteTo(os.Stdout)
+	} else {
+		err = ioutil.WriteFile(accountExportOutput, formatted.Bytes(), 0600)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func runDeleteAccount(cmd *cobra.Command, args []string) {
+	fmt.Print("Type DELETE to delete your account: ")
+	confirmation, err := readPlainText()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if confirmation != "DELETE" {
+		log.Fatalln("Aborted.")
+	}
+	fmt.Print("Password (empty if you use single sign-on): ")
+	password, err := readPassword()
+	fmt.Println()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := client.DeleteAccount(password); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Account deleted.")
+}
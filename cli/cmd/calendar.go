@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/coffeemakr/ruck/api"
+	"github.com/coffeemakr/ruck/cli"
+	"github.com/spf13/cobra"
+)
+
+var (
+	calendarCommand = &cobra.Command{
+		Use:   "calendar",
+		Short: "Subscribe to your tasks in a calendar app",
+	}
+	calendarUrlCommand = &cobra.Command{
+		Use:   "url",
+		Short: "Print the URL of the iCalendar feed, it is created if necessary",
+		Long: `Print the URL of the iCalendar feed with the upcoming occurrences of the tasks
+of your groups. Anyone who knows the URL can read the feed, use --rotate to
+replace it if it was leaked.`,
+		Run:  runCalendarUrl,
+		Args: cobra.NoArgs,
+	}
+	calendarDisableCommand = &cobra.Command{
+		Use:   "disable",
+		Short: "Delete the iCalendar feed, its URLs stop working",
+		Run:   runCalendarDisable,
+		Args:  cobra.NoArgs,
+	}
+	calendarMine   bool
+	calendarRotate bool
+)
+
+func init() {
+	calendarUrlCommand.Flags().BoolVar(&calendarMine, "mine", false, "Only include the occurrences assigned to you")
+	calendarUrlCommand.Flags().BoolVar(&calendarRotate, "rotate", false, "Replace the URL, the previous one stops working")
+	calendarCommand.AddCommand(calendarUrlCommand, calendarDisableCommand)
+}
+
+func runCalendarUrl(cmd *cobra.Command, args []string) {
+	var feed *api.CalendarFeed
+	var err error
+	if !calendarRotate {
+		feed, err = client.GetCalendarFeed()
+	}
+	if calendarRotate || errors.Is(err, cli.ErrNotFound) {
+		feed, err = client.RotateCalendarFeed()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if calendarMine {
+		fmt.Println(feed.MineURL)
+	} else {
+		fmt.Println(feed.URL)
+	}
+}
+
+func runCalendarDisable(cmd *cobra.Command, args []string) {
+	if err := client.DeleteCalendarFeed(); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Calendar feed deleted.")
+}
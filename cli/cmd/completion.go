@@ -21,4 +21,5 @@ To configure your bash shell to load completions for each session add to your ba
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return rootCommand.GenBashCompletion(os.Stdout)
 	},
+	Annotations: map[string]string{offlineAnnotation: ""},
 }
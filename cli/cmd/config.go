@@ -7,8 +7,9 @@ import (
 
 var (
 	configCommand = &cobra.Command{
-		Use:  "config",
-		RunE: runConfig,
+		Use:         "config",
+		RunE:        runConfig,
+		Annotations: map[string]string{offlineAnnotation: ""},
 	}
 )
 
@@ -3,7 +3,10 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
 	"github.com/coffeemakr/ruck/cli"
 	"github.com/spf13/cobra"
 )
@@ -41,6 +44,63 @@ var groupSetDefaultCommand = &cobra.Command{
 	Args: cobra.ExactArgs(1),
 }
 
+var groupSetLeadTimeCommand = &cobra.Command{
+	Use:   "set-lead-time DURATION",
+	Short: "Set how long before the due date the assignees of the default group are reminded",
+	Long: `Set how long before the due date the assignees of the default group are reminded,
+e.g. 2h or 48h. The default of the server is used if it is 0.`,
+	Run:  runSetLeadTime,
+	Args: cobra.ExactArgs(1),
+}
+
+var groupSetEscalationCommand = &cobra.Command{
+	Use:   "set-escalation",
+	Short: "Set what happens with overdue tasks of the default group",
+	Long: `Set what happens with overdue tasks of the default group. All members are notified
+after --notify-after. After --act-after the task is reassigned to the next member
+(--action reassign) or anyone can take it (--action open) and the penalty is
+recorded in the balance of the assignee. A delay of 0 disables the step.`,
+	Run:  runSetEscalation,
+	Args: cobra.NoArgs,
+}
+
+var (
+	groupEscalationNotifyAfter, groupEscalationActAfter time.Duration
+	groupEscalationAction                               string
+	groupEscalationPenalty                              int
+)
+
+var groupSetClaimPolicyCommand = &cobra.Command{
+	Use:   "set-claim-policy POLICY",
+	Short: "Set which tasks the members of the default group can claim",
+	Long: `Set which tasks the members of the default group can claim. Tasks without
+assignee can always be claimed. With "overdue" the tasks of other members can be
+claimed when they are overdue, with "any" at any time and with "open" never.`,
+	Run:       runSetClaimPolicy,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"overdue", "any", "open"},
+}
+
+var groupSetCoverPolicyCommand = &cobra.Command{
+	Use:   "set-cover-policy POLICY",
+	Short: "Set how the rotation continues if someone covers a task of another member",
+	Long: `Set how the rotation of the tasks of the default group continues if someone
+completes a task while it is another member's turn. The executor always moves to
+the end of the queue. With "keep" the member keeps the turn and is assigned again,
+with "swap" the member takes the place of the executor in the queue and with
+"skip" the turn counts as taken.`,
+	Run:       runSetCoverPolicy,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"keep", "swap", "skip"},
+}
+
+var groupBalanceCommand = &cobra.Command{
+	Use:   "balance",
+	Short: "Show how many tasks the members of the default group completed",
+	Run:   runGroupBalance,
+	Args:  cobra.NoArgs,
+}
+
 var groupGetDefaultCommand = &cobra.Command{
 	Use:  "get-default",
 	Run:  runGetDefaultGroup,
@@ -58,6 +118,100 @@ func runGetDefaultGroup(cmd *cobra.Command, args []string) {
 	fmt.Printf("Default Group ID: %s\n", client.Configuration.Group)
 }
 
+func runSetLeadTime(cmd *cobra.Command, args []string) {
+	leadTime, err := time.ParseDuration(args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	minutes := int(leadTime / time.Minute)
+	group, err := client.UpdateGroup(requireDefaultGroup(client), &api.GroupUpdateRequest{ReminderLeadMinutes: &minutes})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if group.ReminderLeadTime == 0 {
+		fmt.Printf("Group %s uses the default lead time of the server.\n", group.Name)
+	} else {
+		fmt.Printf("Assignees of group %s are reminded %s before the due date.\n", group.Name, group.ReminderLeadTime)
+	}
+}
+
+func runSetEscalation(cmd *cobra.Command, args []string) {
+	group, err := client.UpdateGroup(requireDefaultGroup(client), &api.GroupUpdateRequest{
+		Escalation: &api.Escalation{
+			NotifyGroupAfterMinutes: int(groupEscalationNotifyAfter / time.Minute),
+			ActAfterMinutes:         int(groupEscalationActAfter / time.Minute),
+			Action:                  ruck.EscalationAction(groupEscalationAction),
+			Penalty:                 groupEscalationPenalty,
+		},
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	policy := group.Escalation
+	if policy.NotifyGroupAfter == 0 && policy.Action == ruck.EscalationNone {
+		fmt.Printf("Overdue tasks of group %s aren't escalated.\n", group.Name)
+		return
+	}
+	if policy.NotifyGroupAfter != 0 {
+		fmt.Printf("Members of group %s are notified %s after the due date.\n", group.Name, policy.NotifyGroupAfter)
+	}
+	switch policy.Action {
+	case ruck.EscalationReassign:
+		fmt.Printf("Overdue tasks are reassigned %s after the due date with a penalty of %d.\n", policy.ActAfter, policy.Penalty)
+	case ruck.EscalationOpen:
+		fmt.Printf("Overdue tasks are opened for anyone %s after the due date with a penalty of %d.\n", policy.ActAfter, policy.Penalty)
+	}
+}
+
+func runSetClaimPolicy(cmd *cobra.Command, args []string) {
+	policy := ruck.ClaimPolicy(args[0])
+	if args[0] == "overdue" {
+		policy = ruck.ClaimOverdue
+	}
+	group, err := client.UpdateGroup(requireDefaultGroup(client), &api.GroupUpdateRequest{ClaimPolicy: &policy})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	switch group.ClaimPolicy {
+	case ruck.ClaimAny:
+		fmt.Printf("Members of group %s can claim any task.\n", group.Name)
+	case ruck.ClaimOpen:
+		fmt.Printf("Members of group %s can only claim tasks without assignee.\n", group.Name)
+	default:
+		fmt.Printf("Members of group %s can claim tasks without assignee and overdue tasks.\n", group.Name)
+	}
+}
+
+func runSetCoverPolicy(cmd *cobra.Command, args []string) {
+	policy := ruck.CoverPolicy(args[0])
+	if args[0] == "keep" {
+		policy = ruck.CoverKeepTurn
+	}
+	group, err := client.UpdateGroup(requireDefaultGroup(client), &api.GroupUpdateRequest{CoverPolicy: &policy})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	switch group.CoverPolicy {
+	case ruck.CoverSwapTurns:
+		fmt.Printf("Members of group %s swap turns with the members who cover for them.\n", group.Name)
+	case ruck.CoverSkipTurn:
+		fmt.Printf("Members of group %s lose their turn if someone covers for them.\n", group.Name)
+	default:
+		fmt.Printf("Members of group %s keep their turn if someone covers for them.\n", group.Name)
+	}
+}
+
+func runGroupBalance(cmd *cobra.Command, args []string) {
+	balances, err := client.GetGroupBalance(requireDefaultGroup(client))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("%-20s %9s %9s %7s\n", "MEMBER", "COMPLETED", "PENALTIES", "BALANCE")
+	for _, balance := range balances {
+		fmt.Printf("%-20s %9d %9d %7d\n", balance.MemberName, balance.Completed, balance.Penalties, balance.Balance)
+	}
+}
+
 func setDefaultGroup(client *cli.Client, groupID string) error {
 	client.Configuration.Group = groupID
 	err := cli.WriteConfig(client.Configuration)
@@ -117,5 +271,10 @@ func runJoinGroup(cmd *cobra.Command, args []string) {
 }
 
 func init() {
-	groupCommand.AddCommand(groupAddCommand, groupListCommand, groupPruneCommand, groupJoinCommand, groupSetDefaultCommand, groupGetDefaultCommand)
+	groupSetEscalationCommand.Flags().DurationVar(&groupEscalationNotifyAfter, "notify-after", 0, "Notify all members this long after the due date")
+	groupSetEscalationCommand.Flags().DurationVar(&groupEscalationActAfter, "act-after", 0, "Execute the action this long after the due date")
+	groupSetEscalationCommand.Flags().StringVar(&groupEscalationAction, "action", "", "reassign or open")
+	groupSetEscalationCommand.Flags().IntVar(&groupEscalationPenalty, "penalty", 0, "Penalty of the assignee when the action is executed")
+	groupCommand.AddCommand(groupAddCommand, groupListCommand, groupPruneCommand, groupJoinCommand, groupSetDefaultCommand, groupGetDefaultCommand, groupSetLeadTimeCommand,
+		groupSetEscalationCommand, groupSetClaimPolicyCommand, groupSetCoverPolicyCommand, groupBalanceCommand)
 }
@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os/exec"
+	"runtime"
 	"syscall"
 
 	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -16,6 +19,41 @@ var loginCommand = &cobra.Command{
 	Run: runLogin,
 }
 
+var loginWithSSO bool
+
+var logoutCommand = &cobra.Command{
+	Use:   "logout",
+	Short: "Revoke the current session and remove the stored tokens",
+	Run:   runLogout,
+	Args:  cobra.NoArgs,
+	// the stored tokens are removed even if the server can't be reached
+	Annotations: map[string]string{offlineAnnotation: ""},
+}
+
+func init() {
+	loginCommand.Flags().BoolVar(&loginWithSSO, "sso", false, "Log in with the identity provider of the server")
+}
+
+// openBrowser shows the URL and tries to open it in the default browser.
+func openBrowser(location string) error {
+	fmt.Println("Open the following URL to log in:")
+	fmt.Println()
+	fmt.Println("  " + location)
+	fmt.Println()
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		command = exec.Command("open", location)
+	case "windows":
+		command = exec.Command("rundll32", "url.dll,FileProtocolHandler", location)
+	default:
+		command = exec.Command("xdg-open", location)
+	}
+	// the URL is printed, so failing to start a browser is fine
+	_ = command.Start()
+	return nil
+}
+
 func readPassword() (password []byte, err error) {
 	password, err = terminal.ReadPassword(syscall.Stdin)
 	if err != nil {
@@ -50,12 +88,32 @@ func readCredentials() (*ruck.Credentials, error) {
 	return &credentials, nil
 }
 
+func readTwoFactorCode() (string, error) {
+	fmt.Print("TOTP code (or recovery code): ")
+	return readPlainText()
+}
+
 func runLogin(cmd *cobra.Command, args []string) {
+	if loginWithSSO {
+		requireCapability(api.CapabilitySSO, "The server doesn't support single sign-on.")
+		if err := client.LoginWithSSO(openBrowser); err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println("Logged in.")
+		return
+	}
 	creds, err := readCredentials()
 	if err != nil {
 		log.Fatalln(err)
 	}
-	if err := client.Login(creds); err != nil {
+	if err := client.Login(creds, readTwoFactorCode); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func runLogout(cmd *cobra.Command, args []string) {
+	if err := client.Logout(); err != nil {
 		log.Fatalln(err)
 	}
+	fmt.Println("Logged out.")
 }
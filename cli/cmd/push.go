@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pushCommand = &cobra.Command{
+		Use:   "push",
+		Short: "Manage push notifications through ntfy, Gotify or a JSON endpoint",
+		Long: `Push notifications are sent when your tasks are due soon or overdue and when a
+task is assigned to you after someone completed it.
+
+Services:
+  ntfy    URL is the topic URL, e.g. https://ntfy.sh/my-topic, the token is optional
+  gotify  URL is the server URL, the token is an application token
+  json    URL receives the messages as JSON POST requests, the token is sent as bearer token`,
+	}
+	pushShowCommand = &cobra.Command{
+		Use:   "show",
+		Short: "Show the push settings",
+		Run:   runPushShow,
+		Args:  cobra.NoArgs,
+	}
+	pushSetCommand = &cobra.Command{
+		Use:       "set SERVICE URL",
+		Short:     "Send push notifications to the service, replacing the previous settings",
+		Run:       runPushSet,
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{string(ruck.PushServiceNtfy), string(ruck.PushServiceGotify), string(ruck.PushServiceJSON)},
+	}
+	pushRemoveCommand = &cobra.Command{
+		Use:     "rm",
+		Aliases: []string{"remove"},
+		Short:   "Disable push notifications",
+		Run:     runPushRemove,
+		Args:    cobra.NoArgs,
+	}
+	pushTestCommand = &cobra.Command{
+		Use:   "test",
+		Short: "Send a test message",
+		Run:   runPushTest,
+		Args:  cobra.NoArgs,
+	}
+	pushSetToken       string
+	pushSetReminders   bool
+	pushSetAssignments bool
+)
+
+func init() {
+	pushSetCommand.Flags().StringVar(&pushSetToken, "token", "", "The access or application token of the service")
+	pushSetCommand.Flags().BoolVar(&pushSetReminders, "reminders", true, "Push when your tasks are due soon or overdue")
+	pushSetCommand.Flags().BoolVar(&pushSetAssignments, "assignments", true, "Push when a task is assigned to you")
+	pushCommand.AddCommand(pushShowCommand, pushSetCommand, pushRemoveCommand, pushTestCommand)
+	accountCommand.AddCommand(pushCommand)
+}
+
+func printPushSettings(settings *ruck.PushSettings) {
+	fmt.Printf("Service     : %s\n", settings.Service)
+	fmt.Printf("URL         : %s\n", settings.URL)
+	fmt.Printf("Reminders   : %t\n", settings.Reminders)
+	fmt.Printf("Assignments : %t\n", settings.Assignments)
+}
+
+func runPushShow(cmd *cobra.Command, args []string) {
+	settings, err := client.GetPushSettings()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	printPushSettings(settings)
+}
+
+func runPushSet(cmd *cobra.Command, args []string) {
+	settings, err := client.SetPushSettings(&ruck.PushSettings{
+		Service:     ruck.PushService(args[0]),
+		URL:         args[1],
+		Token:       pushSetToken,
+		Reminders:   pushSetReminders,
+		Assignments: pushSetAssignments,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	printPushSettings(settings)
+	fmt.Println("Send a test message with: ruck account push test")
+}
+
+func runPushRemove(cmd *cobra.Command, args []string) {
+	if err := client.DeletePushSettings(); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Push notifications disabled.")
+}
+
+func runPushTest(cmd *cobra.Command, args []string) {
+	if err := client.TestPush(); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("The push service accepted the test message.")
+}
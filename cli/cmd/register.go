@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
 	"github.com/spf13/cobra"
 	"log"
 )
@@ -13,6 +14,16 @@ var registerCommand  = &cobra.Command{
 	Run: runRegister,
 }
 
+var (
+	registrationCode    string
+	registrationGroupID string
+)
+
+func init() {
+	registerCommand.Flags().StringVar(&registrationCode, "code", "", "Registration code if the registration is invite-only")
+	registerCommand.Flags().StringVar(&registrationGroupID, "group", "", "ID of the group you are invited to")
+}
+
 func readRegistration() (*ruck.RegistrationRequest, error) {
 	var passwordsAreValid bool
 	var password, passwordConfirmation []byte
@@ -58,15 +69,18 @@ func readRegistration() (*ruck.RegistrationRequest, error) {
 }
 
 func runRegister(cmd *cobra.Command, args []string) {
+	requireCapability(api.CapabilityRegistration, "The registration is closed on this server.")
 	request, err := readRegistration()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	request.RegistrationCode = registrationCode
+	request.GroupID = registrationGroupID
 	user, err := client.Register(request)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	log.Printf("Registred user: %s\n", user)
+	log.Printf("Registred user: %v\n", user)
 
 }
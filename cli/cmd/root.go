@@ -12,8 +12,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// tokenEnvironmentVariable can be set to a personal access token, which is used instead of the stored token
+const tokenEnvironmentVariable = "RUCK_TOKEN"
+
+// offlineAnnotation marks commands which work without the server.
+const offlineAnnotation = "offline"
+
 var rootCommand = &cobra.Command{
-	Use: "ruck",
+	Use:               "ruck",
+	PersistentPreRunE: checkServer,
 }
 
 var (
@@ -22,10 +29,30 @@ var (
 )
 
 func init() {
-	rootCommand.AddCommand(loginCommand, registerCommand, completionCommand, groupCommand, taskCommand, configCommand)
+	rootCommand.AddCommand(loginCommand, logoutCommand, registerCommand, verifyEmailCommand, accountCommand, tokenCommand, completionCommand, groupCommand, taskCommand, watchCommand, calendarCommand, configCommand)
 	rootCommand.PersistentFlags().StringVar(&proxyStr, "proxy", "", "Proxy URL (e.g. http://localhost:8080)")
 }
 
+// checkServer makes sure the server supports the API of the client before running a command.
+func checkServer(cmd *cobra.Command, args []string) error {
+	if _, offline := cmd.Annotations[offlineAnnotation]; offline || cmd.Name() == "help" {
+		return nil
+	}
+	_, err := client.ServerInfo()
+	return err
+}
+
+// requireCapability exits if the server doesn't support the feature.
+func requireCapability(capability string, message string) {
+	serverInfo, err := client.ServerInfo()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if !serverInfo.HasCapability(capability) {
+		log.Fatalln(message)
+	}
+}
+
 func Execute() {
 	config, err := cli.LoadConfig()
 	if err != nil {
@@ -48,9 +75,14 @@ func Execute() {
 		}
 	}
 
-	tokenStore, err := cli.NewFileTokenStore()
-	if err != nil {
-		log.Fatalln(err)
+	var tokenStore cli.TokenStore
+	if token := os.Getenv(tokenEnvironmentVariable); token != "" {
+		tokenStore = cli.NewStaticTokenStore(token)
+	} else {
+		tokenStore, err = cli.NewFileTokenStore()
+		if err != nil {
+			log.Fatalln(err)
+		}
 	}
 
 	if strings.HasSuffix(config.BaseURL, "/") {
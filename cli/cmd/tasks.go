@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
 	"github.com/coffeemakr/ruck/cli"
 	"github.com/spf13/cobra"
 )
@@ -38,6 +39,7 @@ var (
 	}
 	taskAddOptionDaily, taskAddOptionWeekly, taskAddOptionMonthly, taskAddOptionYearly bool
 	taskAddOptionInterval                                                              uint32
+	taskAddOptionAnyone                                                                bool
 
 	taskDoneCommand = &cobra.Command{
 		Use:     "complete",
@@ -46,6 +48,30 @@ var (
 		Run:     runCompleteTask,
 		Args:    cobra.ExactArgs(1),
 	}
+
+	taskHistoryCommand = &cobra.Command{
+		Use:   "history ID",
+		Short: "Show who completed the task and how it was escalated",
+		Run:   runTaskHistory,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	taskClaimCommand = &cobra.Command{
+		Use:   "claim ID",
+		Short: "Take over a task",
+		Long: `Take over a task. Tasks without assignee can always be claimed, tasks of other
+members as allowed by the claim policy of the group. The rotation continues
+after you when you complete it.`,
+		Run:  runClaimTask,
+		Args: cobra.ExactArgs(1),
+	}
+
+	taskPoolCommand = &cobra.Command{
+		Use:   "pool",
+		Short: "List the tasks without assignee which anyone can claim",
+		Run:   runTaskPool,
+		Args:  cobra.NoArgs,
+	}
 )
 
 func runTaskGet(cmd *cobra.Command, args []string) {
@@ -55,9 +81,10 @@ func runTaskGet(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatalln(err)
 	}
-	t, err := template.New("taskTemplate").Parse("ID    {{.ID}}\n" +
+	t, err := template.New("taskTemplate").Funcs(template.FuncMap{"join": strings.Join}).Parse("ID    {{.ID}}\n" +
 		"Name  {{.Name}}\n" +
-		"Group {{.Group}}\n")
+		"Group {{.Group}}\n" +
+		"Queue {{join .Queue \", \"}}\n")
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -73,7 +100,8 @@ func init() {
 	taskAddCommand.PersistentFlags().BoolVarP(&taskAddOptionMonthly, "monthly", "m", false, "Repeat task monthly")
 	taskAddCommand.PersistentFlags().BoolVarP(&taskAddOptionYearly, "yearly", "y", false, "Repeat task yearly")
 	taskAddCommand.PersistentFlags().Uint32Var(&taskAddOptionInterval, "interval", 1, "Interval number e.g. X weeks when --weeks flag is used")
-	taskCommand.AddCommand(taskAddCommand, taskListCommand, taskGetCommand, taskDoneCommand)
+	taskAddCommand.PersistentFlags().BoolVar(&taskAddOptionAnyone, "anyone", false, "Create the task without assignee, so that anyone can take it")
+	taskCommand.AddCommand(taskAddCommand, taskListCommand, taskGetCommand, taskDoneCommand, taskHistoryCommand, taskClaimCommand, taskPoolCommand)
 }
 
 func getDaysUntilTime(due time.Time) int {
@@ -96,17 +124,40 @@ func formatDue(dueDate time.Time) string {
 	}
 }
 
+// displayAssignee returns the name of the assignee or anyone for open tasks.
+func displayAssignee(assigneeName string) string {
+	if assigneeName == "" {
+		return "anyone"
+	}
+	return assigneeName
+}
+
+func printTasks(tasks []*ruck.Task) {
+	sort.Sort(ruck.ByDueDate(tasks))
+	for _, task := range tasks {
+		ID := task.ID
+		fmt.Printf("%s %-40s %-20s %s\n", ID, task.Name, displayAssignee(task.AssigneeName), formatDue(task.DueDate))
+	}
+}
+
 func runTaskList(cmd *cobra.Command, args []string) {
 	tasks, err := client.GetTaskList()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	sort.Sort(ruck.ByDueDate(tasks))
-	for _, task := range tasks {
-		ID := task.ID
-		fmt.Printf("%s %-40s %-20s %s\n", ID, task.Name, task.AssigneeName, formatDue(task.DueDate))
+	printTasks(tasks)
+}
+
+func runTaskPool(cmd *cobra.Command, args []string) {
+	tasks, err := client.GetOpenTasks()
+	if err != nil {
+		log.Fatalln(err)
 	}
+	if len(tasks) == 0 {
+		fmt.Println("No open tasks.")
+	}
+	printTasks(tasks)
 }
 
 func getIntervalUnit() (unit ruck.IntervalUnit, err error) {
@@ -150,7 +201,7 @@ func getIntervalUnit() (unit ruck.IntervalUnit, err error) {
 func requireDefaultGroup(client *cli.Client) string {
 	group := client.Configuration.Group
 	if group == "" {
-		fmt.Println("ERROR: Default group not set\n")
+		fmt.Println("ERROR: Default group not set")
 		os.Exit(1)
 	}
 	return group
@@ -172,7 +223,7 @@ func runAddTask(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	err = client.CreateTask(&task)
+	err = client.CreateTask(&task, taskAddOptionAnyone)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -185,5 +236,48 @@ func runCompleteTask(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatalln(err)
 	}
-	fmt.Printf("Task completed: %s\n", execution)
+	fmt.Printf("Task completed: %v\n", execution)
+}
+
+func describeHistoryEntry(entry *api.TaskHistoryEntry) string {
+	var description string
+	switch entry.Type {
+	case api.HistoryCompleted:
+		description = fmt.Sprintf("%s completed it, next: %s", entry.Actor, displayAssignee(entry.AssigneeName))
+	case api.HistoryEscalated:
+		description = "the group was notified"
+	case api.HistoryReassigned:
+		description = fmt.Sprintf("reassigned from %s to %s", entry.PreviousAssigneeName, entry.AssigneeName)
+	case api.HistoryOpened:
+		description = fmt.Sprintf("opened for anyone, was assigned to %s", entry.PreviousAssigneeName)
+	case api.HistoryClaimed:
+		description = fmt.Sprintf("%s claimed it from %s", entry.Actor, displayAssignee(entry.PreviousAssigneeName))
+	default:
+		description = string(entry.Type)
+	}
+	if entry.Penalty != 0 {
+		description += fmt.Sprintf(" (penalty %d for %s)", entry.Penalty, entry.PreviousAssigneeName)
+	}
+	return description
+}
+
+func runTaskHistory(cmd *cobra.Command, args []string) {
+	entries, err := client.GetTaskHistory(args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No history.")
+	}
+	for _, entry := range entries {
+		fmt.Printf("%s %s\n", entry.Time.Local().Format("2006-01-02 15:04"), describeHistoryEntry(entry))
+	}
+}
+
+func runClaimTask(cmd *cobra.Command, args []string) {
+	task, err := client.ClaimTask(args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("%s is yours, %s.\n", task.Name, formatDue(task.DueDate))
 }
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tokenCommand = &cobra.Command{
+		Use:   "token",
+		Short: "Manage personal access tokens for scripts",
+		Long: `Personal access tokens allow scripts to use ruck without logging in.
+Set the environment variable ` + tokenEnvironmentVariable + ` to the token to use it.`,
+	}
+	tokenCreateCommand = &cobra.Command{
+		Use:  "create NAME",
+		Run:  runTokenCreate,
+		Args: cobra.ExactArgs(1),
+	}
+	tokenListCommand = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Run:     runTokenList,
+		Args:    cobra.NoArgs,
+	}
+	tokenRevokeCommand = &cobra.Command{
+		Use:     "revoke ID",
+		Aliases: []string{"rm"},
+		Run:     runTokenRevoke,
+		Args:    cobra.ExactArgs(1),
+	}
+	tokenCreateScope string
+)
+
+func init() {
+	var scopes []string
+	for _, scope := range ruck.PersonalAccessTokenScopes {
+		scopes = append(scopes, string(scope))
+	}
+	tokenCreateCommand.Flags().StringVarP(&tokenCreateScope, "scope", "s", string(ruck.ScopeRead),
+		"The scope of the token ("+strings.Join(scopes, ", ")+")")
+	tokenCommand.AddCommand(tokenCreateCommand, tokenListCommand, tokenRevokeCommand)
+}
+
+func runTokenCreate(cmd *cobra.Command, args []string) {
+	token, err := client.CreatePersonalAccessToken(args[0], ruck.TokenScope(tokenCreateScope))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Token %s created. It won't be shown again:\n\n%s\n", token.ID, token.Token)
+}
+
+func runTokenList(cmd *cobra.Command, args []string) {
+	tokens, err := client.ListPersonalAccessTokens()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(tokens) == 0 {
+		fmt.Println("No tokens.")
+	}
+	for _, token := range tokens {
+		lastUsed := "never"
+		if !token.LastUsedAt.IsZero() {
+			lastUsed = token.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%s %-30s %-9s last used: %s\n", token.ID, token.Name, token.Scope, lastUsed)
+	}
+}
+
+func runTokenRevoke(cmd *cobra.Command, args []string) {
+	if err := client.RevokePersonalAccessToken(args[0]); err != nil {
+		log.Fatalln(err)
+	}
+}
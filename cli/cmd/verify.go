@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyEmailCommand = &cobra.Command{
+		Use:   "verify-email",
+		Short: "Verify the email address with the token from the verification mail",
+		Run:   runVerifyEmail,
+		Args:  cobra.ExactArgs(1),
+	}
+	verifyEmailResendCommand = &cobra.Command{
+		Use:   "resend",
+		Short: "Send the verification mail again",
+		Run:   runResendVerificationEmail,
+		Args:  cobra.NoArgs,
+	}
+)
+
+func init() {
+	verifyEmailCommand.AddCommand(verifyEmailResendCommand)
+}
+
+func runVerifyEmail(cmd *cobra.Command, args []string) {
+	user, err := client.VerifyEmail(args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Email address %s verified.\n", user.EmailAddress)
+}
+
+func runResendVerificationEmail(cmd *cobra.Command, args []string) {
+	creds, err := readCredentials()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := client.ResendVerificationEmail(creds); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Verification mail sent.")
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/coffeemakr/ruck/api"
+	"github.com/coffeemakr/ruck/cli"
+	"github.com/spf13/cobra"
+)
+
+// watchReconnectDelay is the time to wait before reconnecting after the connection was lost.
+const watchReconnectDelay = 5 * time.Second
+
+var watchCommand = &cobra.Command{
+	Use:   "watch",
+	Short: "Print changes of the tasks and groups as they happen",
+	Args:  cobra.NoArgs,
+	Run:   runWatch,
+}
+
+func taskName(event *api.Event) string {
+	if event.Task == nil {
+		return "a task"
+	}
+	return fmt.Sprintf("%q", event.Task.Name)
+}
+
+func describeEvent(event *api.Event) string {
+	switch event.Type {
+	case api.EventTaskCreated:
+		return fmt.Sprintf("%s created %s", event.Actor, taskName(event))
+	case api.EventTaskUpdated:
+		return fmt.Sprintf("%s changed %s", event.Actor, taskName(event))
+	case api.EventTaskCompleted:
+		description := fmt.Sprintf("%s completed %s", event.Actor, taskName(event))
+		if event.Task != nil && event.Task.AssigneeName != "" {
+			description += ", next: " + event.Task.AssigneeName
+		}
+		return description
+	case api.EventTaskDeleted:
+		return fmt.Sprintf("%s deleted %s", event.Actor, taskName(event))
+	case api.EventTaskDueSoon:
+		return fmt.Sprintf("%s is due soon", taskName(event))
+	case api.EventTaskOverdue:
+		return fmt.Sprintf("%s is overdue", taskName(event))
+	case api.EventTaskEscalated:
+		if event.History != nil {
+			return fmt.Sprintf("%s is still overdue: %s", taskName(event), describeHistoryEntry(event.History))
+		}
+		return fmt.Sprintf("%s is still overdue", taskName(event))
+	case api.EventTaskClaimed:
+		return fmt.Sprintf("%s claimed %s", event.Actor, taskName(event))
+	case api.EventMemberJoined:
+		return fmt.Sprintf("%s joined the group", event.MemberName)
+	case api.EventMemberLeft:
+		return fmt.Sprintf("%s left the group", event.MemberName)
+	case api.EventGroupDeleted:
+		return fmt.Sprintf("%s deleted the group", event.Actor)
+	default:
+		return string(event.Type)
+	}
+}
+
+func printEvent(event *api.Event) error {
+	group := event.GroupID
+	if event.Group != nil {
+		group = event.Group.Name
+	} else if event.Task != nil && event.Task.Group != nil {
+		group = event.Task.Group.Name
+	}
+	fmt.Printf("%s [%s] %s\n", event.Time.Local().Format("2006-01-02 15:04"), group, describeEvent(event))
+	return nil
+}
+
+func runWatch(cmd *cobra.Command, args []string) {
+	for {
+		err := client.WatchEvents(printEvent)
+		// errors of the server won't go away by reconnecting
+		var apiError *cli.APIError
+		if errors.As(err, &apiError) || errors.Is(err, cli.ErrNoTokenSaved) {
+			log.Fatalln(err)
+		}
+		if err != nil {
+			log.Printf("Connection lost, reconnecting in %s: %s\n", watchReconnectDelay, err)
+			time.Sleep(watchReconnectDelay)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/coffeemakr/ruck/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	webhookCommand = &cobra.Command{
+		Use:   "webhook",
+		Short: "Manage the webhooks of the default group",
+		Long: `Webhooks receive the events of the group as signed HTTP POST requests.
+The header ` + api.WebhookSignatureHeader + ` contains "sha256=" followed by the HMAC-SHA256 of
+the ` + api.WebhookTimestampHeader + ` header, a dot and the body, keyed with the secret.`,
+	}
+	webhookAddCommand = &cobra.Command{
+		Use:  "add URL",
+		Run:  runWebhookAdd,
+		Args: cobra.ExactArgs(1),
+	}
+	webhookListCommand = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Run:     runWebhookList,
+		Args:    cobra.NoArgs,
+	}
+	webhookRemoveCommand = &cobra.Command{
+		Use:     "rm ID",
+		Aliases: []string{"remove"},
+		Run:     runWebhookRemove,
+		Args:    cobra.ExactArgs(1),
+	}
+	webhookTestCommand = &cobra.Command{
+		Use:  "test ID",
+		Run:  runWebhookTest,
+		Args: cobra.ExactArgs(1),
+	}
+	webhookDeliveriesCommand = &cobra.Command{
+		Use:  "deliveries ID",
+		Run:  runWebhookDeliveries,
+		Args: cobra.ExactArgs(1),
+	}
+	webhookAddSecret string
+	webhookAddEvents []string
+)
+
+func init() {
+	var eventTypes []string
+	for _, eventType := range api.EventTypes {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+	webhookAddCommand.Flags().StringVar(&webhookAddSecret, "secret", "",
+		"The secret to sign the requests with, a random one is generated if it is empty")
+	webhookAddCommand.Flags().StringSliceVarP(&webhookAddEvents, "event", "e", nil,
+		"The events to send ("+strings.Join(eventTypes, ", ")+"), all if not set")
+	webhookCommand.AddCommand(webhookAddCommand, webhookListCommand, webhookRemoveCommand, webhookTestCommand, webhookDeliveriesCommand)
+	groupCommand.AddCommand(webhookCommand)
+}
+
+func runWebhookAdd(cmd *cobra.Command, args []string) {
+	request := api.WebhookRequest{
+		URL:    args[0],
+		Secret: webhookAddSecret,
+	}
+	for _, eventType := range webhookAddEvents {
+		request.Events = append(request.Events, api.EventType(eventType))
+	}
+	webhook, err := client.CreateWebhook(requireDefaultGroup(client), &request)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Webhook %s created.\n", webhook.ID)
+	if webhookAddSecret == "" {
+		fmt.Printf("The secret won't be shown again:\n\n%s\n", webhook.Secret)
+	}
+}
+
+func describeWebhookEvents(events []api.EventType) string {
+	if len(events) == 0 {
+		return "all events"
+	}
+	names := make([]string, 0, len(events))
+	for _, eventType := range events {
+		names = append(names, string(eventType))
+	}
+	return strings.Join(names, ", ")
+}
+
+func runWebhookList(cmd *cobra.Command, args []string) {
+	webhooks, err := client.ListWebhooks(requireDefaultGroup(client))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(webhooks) == 0 {
+		fmt.Println("No webhooks.")
+	}
+	for _, webhook := range webhooks {
+		fmt.Printf("%s %-40s %s\n", webhook.ID, webhook.URL, describeWebhookEvents(webhook.Events))
+	}
+}
+
+func runWebhookRemove(cmd *cobra.Command, args []string) {
+	if err := client.DeleteWebhook(requireDefaultGroup(client), args[0]); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func printWebhookDelivery(delivery *api.WebhookDelivery) {
+	result := string(delivery.Status)
+	if delivery.ResponseStatus != 0 {
+		result += fmt.Sprintf(" (HTTP %d)", delivery.ResponseStatus)
+	}
+	if delivery.Error != "" {
+		result += ": " + delivery.Error
+	}
+	fmt.Printf("%s %s %-20s attempts: %d %s\n", delivery.CreatedAt.Local().Format("2006-01-02 15:04"),
+		delivery.ID, delivery.EventType, delivery.Attempts, result)
+}
+
+func runWebhookTest(cmd *cobra.Command, args []string) {
+	delivery, err := client.TestWebhook(requireDefaultGroup(client), args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	printWebhookDelivery(delivery)
+}
+
+func runWebhookDeliveries(cmd *cobra.Command, args []string) {
+	deliveries, err := client.ListWebhookDeliveries(requireDefaultGroup(client), args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(deliveries) == 0 {
+		fmt.Println("No deliveries.")
+	}
+	for _, delivery := range deliveries {
+		printWebhookDelivery(delivery)
+	}
+}
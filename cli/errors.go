@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/coffeemakr/ruck"
+)
+
+// maxErrorBodySize limits how much of an error response is read.
+const maxErrorBodySize = 64 * 1024
+
+// APIError is returned for error responses of the server. Use errors.As to inspect the code:
+//
+//	var apiError *cli.APIError
+//	if errors.As(err, &apiError) && apiError.Code == ruck.ErrorCodeTaskNotFound { ... }
+type APIError struct {
+	StatusCode int
+	// Code is one of the ruck.ErrorCode constants or empty if the response wasn't an error envelope.
+	Code        string
+	Description string
+	// ID identifies the error in the log of the server.
+	ID string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("request failed with status code %d: %s", e.StatusCode, e.Description)
+	}
+	return fmt.Sprintf("%s (%s, status %d)", e.Description, e.Code, e.StatusCode)
+}
+
+// Is makes errors.Is(err, ErrNotFound) work for all 404 responses.
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// newAPIError reads the error envelope from the response.
+func newAPIError(response *http.Response) *APIError {
+	apiError := &APIError{
+		StatusCode:  response.StatusCode,
+		Description: http.StatusText(response.StatusCode),
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+	if err != nil {
+		return apiError
+	}
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var errorResponse ruck.ErrorResponse
+		if err := json.Unmarshal(body, &errorResponse); err == nil && errorResponse.Error {
+			apiError.Code = errorResponse.Code
+			apiError.ID = errorResponse.ID
+			if errorResponse.Description != "" {
+				apiError.Description = errorResponse.Description
+			}
+			return apiError
+		}
+	}
+	if text := strings.TrimSpace(string(body)); text != "" {
+		apiError.Description = text
+	}
+	return apiError
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/coffeemakr/ruck/api"
+)
+
+// WatchEvents calls handle for each event of the groups of the user. It returns nil when the server
+// ends the stream, e.g. because the token expired, and the error of handle if it fails.
+func (c *Client) WatchEvents(handle func(event *api.Event) error) error {
+	response, err := c.doAuthenticated("GET", "/events", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if err := checkResponse(response); err != nil {
+		return err
+	}
+	return readEvents(response.Body, handle)
+}
+
+// readEvents parses a stream of server-sent events.
+func readEvents(reader io.Reader, handle func(event *api.Event) error) error {
+	var data strings.Builder
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var event api.Event
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return fmt.Errorf("invalid event: %w", err)
+			}
+			data.Reset()
+			if err := handle(&event); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() != 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		default:
+			// the type and ID are part of the data, comments keep the connection alive
+		}
+	}
+	return scanner.Err()
+}
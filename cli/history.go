@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/coffeemakr/ruck/api"
+)
+
+// GetTaskHistory returns the latest completions, reassignments and escalations of the task, the
+// newest first.
+func (c *Client) GetTaskHistory(taskId string) (entries []*api.TaskHistoryEntry, err error) {
+	err = c.receiveJsonAuthenticated("GET", joinUrl("tasks", taskId, "history"), &entries)
+	if err != nil {
+		err = fmt.Errorf("failed to get task history: %w", err)
+	}
+	return
+}
+
+func (c *Client) GetGroupBalance(groupId string) (balances []*api.MemberBalance, err error) {
+	err = c.receiveJsonAuthenticated("GET", joinUrl("groups", groupId, "balance"), &balances)
+	if err != nil {
+		err = fmt.Errorf("failed to get balance of group: %w", err)
+	}
+	return
+}
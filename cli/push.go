@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/coffeemakr/ruck"
+)
+
+func (c *Client) GetPushSettings() (*ruck.PushSettings, error) {
+	var settings ruck.PushSettings
+	err := c.receiveJsonAuthenticated("GET", "/account/push", &settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get push settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetPushSettings replaces the push settings, the token has to be sent again.
+func (c *Client) SetPushSettings(settings *ruck.PushSettings) (*ruck.PushSettings, error) {
+	var result ruck.PushSettings
+	err := c.sendAndReceiveJsonAuthenticated("PUT", "/account/push", settings, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set push settings: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *Client) DeletePushSettings() error {
+	err := c.sendAuthenticated("DELETE", "/account/push")
+	if err != nil {
+		return fmt.Errorf("failed to delete push settings: %w", err)
+	}
+	return nil
+}
+
+// TestPush lets the server send a test message to the push service.
+func (c *Client) TestPush() error {
+	err := c.sendAuthenticated("POST", "/account/push/test")
+	if err != nil {
+		return fmt.Errorf("failed to send test push: %w", err)
+	}
+	return nil
+}
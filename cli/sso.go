@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+)
+
+// ssoTimeout is the time the user has to log in at the identity provider.
+const ssoTimeout = 5 * time.Minute
+
+var ErrSSOTimeout = errors.New("timed out waiting for the single sign-on login")
+
+const ssoDonePage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>ruck</title></head>
+<body><p>%s You can close this window.</p></body></html>
+`
+
+type ssoCallbackResult struct {
+	code string
+	err  error
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// LoginWithSSO logs in at the identity provider of the server. A local server receives the redirect
+// after the login and openURL is called to open the login page in the browser.
+func (c *Client) LoginWithSSO(openURL func(string) error) error {
+	codeVerifier, err := randomString()
+	if err != nil {
+		return err
+	}
+	state, err := randomString()
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to start local server: %s", err)
+	}
+	redirectURI := "http://" + listener.Addr().String() + "/callback"
+
+	results := make(chan ssoCallbackResult, 1)
+	handler := http.NewServeMux()
+	handler.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		var result ssoCallbackResult
+		message := "Logged in."
+		if providerError := query.Get("error"); providerError != "" {
+			result.err = fmt.Errorf("login failed: %s", providerError)
+			message = "Login failed."
+		} else {
+			result.code = query.Get("code")
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprintf(w, ssoDonePage, message)
+		select {
+		case results <- result:
+		default:
+		}
+	})
+	server := &http.Server{Handler: handler}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Shutdown(context.Background())
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	loginURL := c.getUrl("/sso/login") + "?" + url.Values{
+		"redirect_uri":          {redirectURI},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}.Encode()
+	if err := openURL(loginURL); err != nil {
+		return err
+	}
+
+	var result ssoCallbackResult
+	select {
+	case result = <-results:
+	case <-time.After(ssoTimeout):
+		return ErrSSOTimeout
+	}
+	if result.err != nil {
+		return result.err
+	}
+	var authenticationResult api.AuthenticationResult
+	err = c.sendAndReceiveJson("POST", "/sso/token", "", &ruck.SSOTokenRequest{
+		Code:         result.code,
+		CodeVerifier: codeVerifier,
+	}, &authenticationResult)
+	if err != nil {
+		return err
+	}
+	return c.saveAuthenticationResult(&authenticationResult)
+}
@@ -4,15 +4,21 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 )
 
 type TokenStore interface {
 	SaveToken(token string) error
 	GetToken() (string, error)
+	SaveRefreshToken(token string) error
+	GetRefreshToken() (string, error)
+	// Clear removes all stored tokens.
+	Clear() error
 }
 
 type fileTokenStore struct {
-	Path string
+	Path        string
+	RefreshPath string
 }
 
 func NewFileTokenStore() (TokenStore, error) {
@@ -20,33 +26,86 @@ func NewFileTokenStore() (TokenStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	path := path.Join(folder, "access-token.txt")
-	return &fileTokenStore{Path: path}, nil
+	return &fileTokenStore{
+		Path:        path.Join(folder, "access-token.txt"),
+		RefreshPath: path.Join(folder, "refresh-token.txt"),
+	}, nil
 }
 
-func (s *fileTokenStore) SaveToken(token string) error {
-	file, err := os.Create(s.Path)
-	if err != nil {
-		return err
-	}
-	_, err = file.WriteString(token)
-	if err != nil {
+func writeTokenFile(path string, token string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0700)); err != nil {
 		return err
 	}
-	return nil
+	return ioutil.WriteFile(path, []byte(token), 0600)
 }
 
-func (s *fileTokenStore) GetToken() (string, error) {
-	file, err := os.Open(s.Path)
+func readTokenFile(path string) (string, error) {
+	token, err := ioutil.ReadFile(path)
 	if err != nil {
-		if err == os.ErrNotExist {
+		if os.IsNotExist(err) {
 			err = ErrNoTokenSaved
 		}
 		return "", err
 	}
-	token, err := ioutil.ReadAll(file)
-	if err != nil {
-		return "", err
-	}
 	return string(token[:]), nil
 }
+
+func removeTokenFile(path string) error {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *fileTokenStore) SaveToken(token string) error {
+	return writeTokenFile(s.Path, token)
+}
+
+func (s *fileTokenStore) GetToken() (string, error) {
+	return readTokenFile(s.Path)
+}
+
+func (s *fileTokenStore) SaveRefreshToken(token string) error {
+	return writeTokenFile(s.RefreshPath, token)
+}
+
+func (s *fileTokenStore) GetRefreshToken() (string, error) {
+	return readTokenFile(s.RefreshPath)
+}
+
+func (s *fileTokenStore) Clear() error {
+	if err := removeTokenFile(s.Path); err != nil {
+		return err
+	}
+	return removeTokenFile(s.RefreshPath)
+}
+
+// staticTokenStore provides a fixed token, e.g. a personal access token from the environment.
+type staticTokenStore struct {
+	Token string
+}
+
+func NewStaticTokenStore(token string) TokenStore {
+	return &staticTokenStore{Token: token}
+}
+
+func (s *staticTokenStore) SaveToken(token string) error {
+	return ErrStaticToken
+}
+
+func (s *staticTokenStore) GetToken() (string, error) {
+	return s.Token, nil
+}
+
+func (s *staticTokenStore) SaveRefreshToken(token string) error {
+	return ErrStaticToken
+}
+
+func (s *staticTokenStore) GetRefreshToken() (string, error) {
+	return "", ErrNoTokenSaved
+}
+
+func (s *staticTokenStore) Clear() error {
+	return ErrStaticToken
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/coffeemakr/ruck/api"
+)
+
+func (c *Client) CreateWebhook(groupId string, request *api.WebhookRequest) (*api.Webhook, error) {
+	var webhook api.Webhook
+	err := c.sendAndReceiveJsonAuthenticated("POST", joinUrl("groups", groupId, "webhooks"), request, &webhook)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+func (c *Client) ListWebhooks(groupId string) (webhooks []*api.Webhook, err error) {
+	err = c.receiveJsonAuthenticated("GET", joinUrl("groups", groupId, "webhooks"), &webhooks)
+	if err != nil {
+		err = fmt.Errorf("failed to get list of webhooks: %w", err)
+	}
+	return
+}
+
+func (c *Client) DeleteWebhook(groupId string, webhookId string) error {
+	err := c.sendAuthenticated("DELETE", joinUrl("groups", groupId, "webhooks", webhookId))
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the latest deliveries of the webhook, the newest first.
+func (c *Client) ListWebhookDeliveries(groupId string, webhookId string) (deliveries []*api.WebhookDelivery, err error) {
+	err = c.receiveJsonAuthenticated("GET", joinUrl("groups", groupId, "webhooks", webhookId, "deliveries"), &deliveries)
+	if err != nil {
+		err = fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	return
+}
+
+// TestWebhook sends a ping event to the webhook and returns the result.
+func (c *Client) TestWebhook(groupId string, webhookId string) (*api.WebhookDelivery, error) {
+	var delivery api.WebhookDelivery
+	err := c.receiveJsonAuthenticated("POST", joinUrl("groups", groupId, "webhooks", webhookId, "test"), &delivery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to test webhook: %w", err)
+	}
+	return &delivery, nil
+}
@@ -0,0 +1,109 @@
+package ruck
+
+// ErrorResponse is the body of every error response of the API, e.g.
+//
+//	{"error": true, "code": "task_not_found", "description": "Task not found", "id": "Xa3..."}
+//
+// Code is one of the ErrorCode constants and doesn't change, clients should use it to handle
+// specific errors. Description is meant for humans and may change. ID identifies the error in
+// the server log.
+type ErrorResponse struct {
+	Error       bool   `json:"error"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	ID          string `json:"id,omitempty"`
+}
+
+// General errors
+const (
+	ErrorCodeInternal         = "internal_error"
+	ErrorCodeBadRequest       = "bad_request"
+	ErrorCodeInvalidJSON      = "invalid_json"
+	ErrorCodeNotFound         = "not_found"
+	ErrorCodeMethodNotAllowed = "method_not_allowed"
+	ErrorCodeTooManyRequests  = "too_many_requests"
+)
+
+// Authentication errors
+const (
+	ErrorCodeUnauthorized            = "unauthorized"
+	ErrorCodeInvalidToken            = "invalid_token"
+	ErrorCodeTokenExpired            = "token_expired"
+	ErrorCodeInsufficientScope       = "insufficient_scope"
+	ErrorCodeInvalidCredentials      = "invalid_credentials"
+	ErrorCodeAccountLocked           = "account_locked"
+	ErrorCodeUserDisabled            = "user_disabled"
+	ErrorCodeInvalidRefreshToken     = "invalid_refresh_token"
+	ErrorCodeTwoFactorAlreadyEnabled = "two_factor_already_enabled"
+	ErrorCodeTwoFactorNotEnabled     = "two_factor_not_enabled"
+	ErrorCodeInvalidTwoFactorCode    = "invalid_two_factor_code"
+	ErrorCodeInvalidTwoFactorToken   = "invalid_two_factor_token"
+	ErrorCodeSSONotConfigured        = "sso_not_configured"
+	ErrorCodeInvalidRedirectURI      = "invalid_redirect_uri"
+	ErrorCodeCodeChallengeRequired   = "code_challenge_required"
+	ErrorCodeInvalidSSOState         = "invalid_sso_state"
+	ErrorCodeInvalidSSOCode          = "invalid_sso_code"
+	ErrorCodeSSOFailed               = "sso_failed"
+	ErrorCodeSSONoAccount            = "sso_no_account"
+	ErrorCodeSSONameTaken            = "sso_name_taken"
+	ErrorCodeSSOEmailConflict        = "sso_email_conflict"
+)
+
+// Account errors
+const (
+	ErrorCodePasswordsDontMatch       = "passwords_dont_match"
+	ErrorCodeWrongPassword            = "wrong_password"
+	ErrorCodeInvalidResetToken        = "invalid_reset_token"
+	ErrorCodeRegistrationClosed       = "registration_closed"
+	ErrorCodeInvitationRequired       = "invitation_required"
+	ErrorCodeEmailNotVerified         = "email_not_verified"
+	ErrorCodeEmailAlreadyVerified     = "email_already_verified"
+	ErrorCodeNoEmailAddress           = "no_email_address"
+	ErrorCodeInvalidVerificationToken = "invalid_verification_token"
+	ErrorCodeMailNotConfigured        = "mail_not_configured"
+	ErrorCodeInvalidDisplayName       = "invalid_display_name"
+	ErrorCodeInvalidTimeZone          = "invalid_time_zone"
+	ErrorCodeInvalidLanguage          = "invalid_language"
+	ErrorCodeInvalidEmail             = "invalid_email"
+	ErrorCodeAccessTokenNotFound      = "access_token_not_found"
+	ErrorCodeInvalidScope             = "invalid_scope"
+	ErrorCodeInvalidTokenName         = "invalid_token_name"
+	ErrorCodeInvalidUnsubscribeToken  = "invalid_unsubscribe_token"
+)
+
+// Group and task errors
+const (
+	ErrorCodeGroupNotFound      = "group_not_found"
+	ErrorCodeTaskNotFound       = "task_not_found"
+	ErrorCodeAssigneeNotInGroup = "assignee_not_in_group"
+	ErrorCodeInvalidInterval    = "invalid_interval"
+	ErrorCodeInvalidGroupName   = "invalid_group_name"
+	ErrorCodeInvalidLeadTime    = "invalid_lead_time"
+	ErrorCodeInvalidEscalation  = "invalid_escalation"
+	ErrorCodeInvalidClaimPolicy = "invalid_claim_policy"
+	ErrorCodeInvalidCoverPolicy = "invalid_cover_policy"
+	ErrorCodeTaskNotClaimable   = "task_not_claimable"
+)
+
+// Webhook errors
+const (
+	ErrorCodeWebhookNotFound      = "webhook_not_found"
+	ErrorCodeInvalidWebhookURL    = "invalid_webhook_url"
+	ErrorCodeInvalidWebhookSecret = "invalid_webhook_secret"
+	ErrorCodeInvalidEventType     = "invalid_event_type"
+	ErrorCodeTooManyWebhooks      = "too_many_webhooks"
+)
+
+// Push notification errors
+const (
+	ErrorCodePushNotConfigured  = "push_not_configured"
+	ErrorCodeInvalidPushService = "invalid_push_service"
+	ErrorCodeInvalidPushURL     = "invalid_push_url"
+	ErrorCodeInvalidPushToken   = "invalid_push_token"
+	ErrorCodePushFailed         = "push_failed"
+)
+
+// Calendar errors
+const (
+	ErrorCodeCalendarFeedNotFound = "calendar_feed_not_found"
+)
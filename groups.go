@@ -1,7 +1,88 @@
 package ruck
 
+import "time"
+
 type Group struct {
 	ID          string
 	Name        string
 	MemberNames []string
+	// ReminderLeadTime is the time before the due date at which the assignee is reminded of a
+	// task. The default of the server is used if it is zero.
+	ReminderLeadTime time.Duration
+	Escalation       EscalationPolicy
+	ClaimPolicy      ClaimPolicy
+	CoverPolicy      CoverPolicy
+}
+
+// ClaimPolicy restricts which tasks the members can claim for themselves. Tasks without assignee
+// can always be claimed.
+type ClaimPolicy string
+
+const (
+	// ClaimOverdue allows claiming overdue tasks of other members.
+	ClaimOverdue ClaimPolicy = ""
+	// ClaimAny allows claiming any task of the group.
+	ClaimAny ClaimPolicy = "any"
+	// ClaimOpen only allows claiming tasks without assignee.
+	ClaimOpen ClaimPolicy = "open"
+)
+
+func (p ClaimPolicy) IsValid() bool {
+	switch p {
+	case ClaimOverdue, ClaimAny, ClaimOpen:
+		return true
+	}
+	return false
+}
+
+type EscalationAction string
+
+const (
+	EscalationNone EscalationAction = ""
+	// EscalationReassign assigns the overdue task to the next member.
+	EscalationReassign EscalationAction = "reassign"
+	// EscalationOpen removes the assignee, so that any member can take the overdue task.
+	EscalationOpen EscalationAction = "open"
+)
+
+func (a EscalationAction) IsValid() bool {
+	switch a {
+	case EscalationNone, EscalationReassign, EscalationOpen:
+		return true
+	}
+	return false
+}
+
+// EscalationPolicy describes what happens with the overdue tasks of a group. The steps with a zero
+// duration are disabled.
+type EscalationPolicy struct {
+	// NotifyGroupAfter is the time after the due date at which all members are notified.
+	NotifyGroupAfter time.Duration
+	// ActAfter is the time after the due date at which the action is executed.
+	ActAfter time.Duration
+	Action   EscalationAction
+	// Penalty is recorded in the balance of the assignee when the action is executed.
+	Penalty int
+}
+
+// CoverPolicy decides how the rotation of a task continues if a member completes it while it is
+// another member's turn. The executor always moves to the end of the queue.
+type CoverPolicy string
+
+const (
+	// CoverKeepTurn keeps the turn of the member, who is assigned the next time again.
+	CoverKeepTurn CoverPolicy = ""
+	// CoverSwapTurns gives the member the place of the executor in the queue.
+	CoverSwapTurns CoverPolicy = "swap"
+	// CoverSkipTurn counts the turn as taken, the member moves to the end of the queue before the
+	// executor.
+	CoverSkipTurn CoverPolicy = "skip"
+)
+
+func (p CoverPolicy) IsValid() bool {
+	switch p {
+	case CoverKeepTurn, CoverSwapTurns, CoverSkipTurn:
+		return true
+	}
+	return false
 }
@@ -3,6 +3,8 @@ package cmd
 import (
 	"bufio"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/square/go-jose/v3"
+	"golang.org/x/crypto/ed25519"
 )
 
 var (
@@ -27,9 +30,43 @@ func generateSignatureRsaKey() (*jose.JSONWebKey, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newSignatureKey(key, jose.RS256)
+}
+
+func generateSignatureEcdsaKey() (*jose.JSONWebKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newSignatureKey(key, jose.ES256)
+}
+
+func generateSignatureEd25519Key() (*jose.JSONWebKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newSignatureKey(key, jose.EdDSA)
+}
+
+// generateSignatureKey generates a new key for the given signature algorithm.
+func generateSignatureKey(algorithm jose.SignatureAlgorithm) (*jose.JSONWebKey, error) {
+	switch algorithm {
+	case jose.RS256:
+		return generateSignatureRsaKey()
+	case jose.ES256:
+		return generateSignatureEcdsaKey()
+	case jose.EdDSA:
+		return generateSignatureEd25519Key()
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+}
+
+func newSignatureKey(key interface{}, algorithm jose.SignatureAlgorithm) (*jose.JSONWebKey, error) {
 	priv := jose.JSONWebKey{
 		Key:       key,
-		Algorithm: string(jose.RS256),
+		Algorithm: string(algorithm),
 		Use:       "sig",
 	}
 
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/square/go-jose/v3"
+)
+
+var ErrNoKeys = errors.New("no keys in key file")
+
+// loadPrivateKeys reads the signature keys from the file. The file either contains a single JWK
+// or a JWK set. The first key of a set is used to sign tokens.
+func loadPrivateKeys(filename string) (*jose.JSONWebKeySet, error) {
+	var keySet jose.JSONWebKeySet
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &keySet)
+	if err != nil {
+		return nil, err
+	}
+	if len(keySet.Keys) == 0 {
+		// fallback for files generated by generate-secrets
+		var key jose.JSONWebKey
+		err = key.UnmarshalJSON(b)
+		if err != nil {
+			return nil, err
+		}
+		keySet.Keys = []jose.JSONWebKey{key}
+	}
+	for _, key := range keySet.Keys {
+		if key.IsPublic() {
+			return nil, errors.New("key file contains public key " + key.KeyID)
+		}
+	}
+	return &keySet, nil
+}
+
+func writePrivateKeys(filename string, keySet *jose.JSONWebKeySet) error {
+	if len(keySet.Keys) == 0 {
+		return ErrNoKeys
+	}
+	b, err := json.MarshalIndent(keySet, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0600)
+}
+
+func publicKeySet(keySet *jose.JSONWebKeySet) *jose.JSONWebKeySet {
+	var result jose.JSONWebKeySet
+	for _, key := range keySet.Keys {
+		result.Keys = append(result.Keys, key.Public())
+	}
+	return &result
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coffeemakr/ruck/server/handlers"
+	"github.com/spf13/cobra"
+)
+
+var (
+	registrationCodeCommand = &cobra.Command{
+		Use:               "registration-code",
+		Short:             "Manage the codes required to register if the registration is invite-only",
+		PersistentPreRunE: initUserCommand,
+	}
+	registrationCodeCreateCommand = &cobra.Command{
+		Use:   "create",
+		Short: "Create a new registration code",
+		RunE:  runRegistrationCodeCreate,
+		Args:  cobra.NoArgs,
+	}
+	registrationCodeListCommand = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the registration codes which are not expired",
+		RunE:    runRegistrationCodeList,
+		Args:    cobra.NoArgs,
+	}
+	registrationCodeRevokeCommand = &cobra.Command{
+		Use:   "revoke ID",
+		Short: "Revoke a registration code",
+		RunE:  runRegistrationCodeRevoke,
+		Args:  cobra.ExactArgs(1),
+	}
+	registrationCodeValidity time.Duration
+	registrationCodeUses     int
+)
+
+func init() {
+	registrationCodeCreateCommand.Flags().DurationVar(&registrationCodeValidity, "valid", handlers.DefaultRegistrationCodeValidity,
+		"How long the code can be used")
+	registrationCodeCreateCommand.Flags().IntVar(&registrationCodeUses, "uses", 1, "How many users can register with the code")
+	registrationCodeCommand.AddCommand(registrationCodeCreateCommand, registrationCodeListCommand, registrationCodeRevokeCommand)
+}
+
+func runRegistrationCodeCreate(cmd *cobra.Command, args []string) error {
+	if registrationCodeUses < 1 {
+		return errors.New("the code must be usable at least once")
+	}
+	code, model, err := handlers.CreateRegistrationCode(context.Background(), registrationCodeValidity, registrationCodeUses)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Registration code: %s\nID: %s, valid until %s for %d registrations\n", code, model.ID,
+		model.Expiry.Format(time.RFC3339), model.RemainingUses)
+	fmt.Printf("Register with:\n\n    ruck register --code %s\n", code)
+	return nil
+}
+
+func runRegistrationCodeList(*cobra.Command, []string) error {
+	codes, err := handlers.ListRegistrationCodes(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-32s %-25s %-25s %s\n", "ID", "CREATED", "EXPIRES", "REMAINING USES")
+	for _, code := range codes {
+		fmt.Printf("%-32s %-25s %-25s %d\n", code.ID, code.CreatedAt.Format(time.RFC3339),
+			code.Expiry.Format(time.RFC3339), code.RemainingUses)
+	}
+	return nil
+}
+
+func runRegistrationCodeRevoke(cmd *cobra.Command, args []string) error {
+	return handlers.RevokeRegistrationCode(context.Background(), args[0])
+}
@@ -11,7 +11,8 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
-	rootCmd.AddCommand(generateKeysCommand, generateConfigCommand, serverCommand)
+	rootCmd.AddCommand(generateKeysCommand, generateConfigCommand, serverCommand, rotateKeysCommand, userCommand,
+		registrationCodeCommand)
 }
 
 func Execute() error {
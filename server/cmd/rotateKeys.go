@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/square/go-jose/v3"
+)
+
+var (
+	rotateKeysCommand = &cobra.Command{
+		Use:   "rotate-keys",
+		Short: "Generate a new signature key and keep the old ones for verification",
+		Long: `Generates a new signature key which is used to sign all new tokens.
+The previous keys are kept to verify tokens which were issued before.
+The server must be restarted to use the new key.`,
+		RunE: runRotateKeys,
+		Args: cobra.NoArgs,
+	}
+	rotateKeysFile      string
+	rotateKeysAlgorithm string
+	rotateKeysKeep      int
+)
+
+func init() {
+	rotateKeysCommand.Flags().StringVarP(&rotateKeysFile, "file", "f", "", "The key file (default: auth.key of the configuration)")
+	rotateKeysCommand.Flags().StringVarP(&rotateKeysAlgorithm, "algorithm", "a", string(jose.RS256), "The algorithm of the new key (RS256, ES256 or EdDSA)")
+	rotateKeysCommand.Flags().IntVar(&rotateKeysKeep, "keep", 2, "The number of old keys to keep for verification")
+}
+
+func runRotateKeys(cmd *cobra.Command, args []string) error {
+	filename := rotateKeysFile
+	if filename == "" {
+		if err := initConfig(cmd, args); err != nil {
+			return err
+		}
+		filename = serverConfig.Auth.Key
+	}
+	keySet, err := loadPrivateKeys(filename)
+	if err != nil {
+		return err
+	}
+	key, err := generateSignatureKey(jose.SignatureAlgorithm(rotateKeysAlgorithm))
+	if err != nil {
+		return err
+	}
+	oldKeys := keySet.Keys
+	if rotateKeysKeep < len(oldKeys) {
+		for _, removed := range oldKeys[rotateKeysKeep:] {
+			fmt.Printf("Removed key %s\n", removed.KeyID)
+		}
+		oldKeys = oldKeys[:rotateKeysKeep]
+	}
+	keySet.Keys = append([]jose.JSONWebKey{*key}, oldKeys...)
+	if err := writePrivateKeys(filename, keySet); err != nil {
+		return err
+	}
+	fmt.Printf("Key ID: %s\nNew key added to %s. Restart the server to use it.\n", key.KeyID, filename)
+	return nil
+}
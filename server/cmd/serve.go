@@ -3,32 +3,43 @@ package cmd
 import (
 	"context"
 	"encoding/binary"
-	"io/ioutil"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	crypto_rand "crypto/rand"
 	math_rand "math/rand"
 
+	"github.com/coffeemakr/ruck/api"
 	"github.com/coffeemakr/ruck/server"
 	"github.com/coffeemakr/ruck/server/handlers"
+	"github.com/coffeemakr/ruck/server/mail"
+	"github.com/coffeemakr/ruck/server/notify"
+	"github.com/coffeemakr/ruck/server/oidc"
+	"github.com/coffeemakr/ruck/server/passhash"
 	"github.com/gorilla/mux"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"github.com/square/go-jose/v3"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	serverHTTPPort = 8080
 	serverHTTPHost = "127.0.0.1"
 	serverConfig   = server.Configuration{
-		Listen:   &server.ListenConfig{},
-		Database: &server.DatabaseConfig{},
-		Auth:     &server.AuthenticationConfig{},
+		Listen:       &server.ListenConfig{},
+		Database:     &server.DatabaseConfig{},
+		Auth:         &server.AuthenticationConfig{},
+		Mail:         &server.MailConfig{},
+		RateLimit:    &server.RateLimitConfig{},
+		SSO:          &server.SSOConfig{},
+		PasswordHash: &server.PasswordHashConfig{},
+		Reminders:    &server.ReminderConfig{},
+		Webhooks:     &server.WebhookConfig{},
 	}
 	authenticator *handlers.Authenticator
 	config        *viper.Viper
@@ -47,8 +58,65 @@ func initConfig(*cobra.Command, []string) error {
 	serverConfig.Listen.Port = config.GetInt("listen.port")
 	serverConfig.Listen.Host = config.GetString("listen.host")
 	serverConfig.Database.URL = config.GetString("database.url")
+	serverConfig.Listen.PublicURL = config.GetString("listen.public_url")
 	serverConfig.Auth.Key = config.GetString("auth.key")
-	return nil
+	serverConfig.Auth.RequireVerifiedEmail = config.GetBool("auth.require_verified_email")
+	serverConfig.Auth.AccessTokenLifetime = config.GetDuration("auth.access_token_lifetime")
+	serverConfig.Auth.RefreshTokenLifetime = config.GetDuration("auth.refresh_token_lifetime")
+	serverConfig.Auth.Issuer = config.GetString("auth.issuer")
+	serverConfig.Auth.Audience = config.GetString("auth.audience")
+	serverConfig.Auth.Leeway = config.GetDuration("auth.leeway")
+	serverConfig.Auth.RegistrationMode = config.GetString("auth.registration_mode")
+	serverConfig.Mail.Host = config.GetString("mail.host")
+	serverConfig.Mail.Port = config.GetInt("mail.port")
+	serverConfig.Mail.Username = config.GetString("mail.username")
+	serverConfig.Mail.Password = config.GetString("mail.password")
+	serverConfig.Mail.From = config.GetString("mail.from")
+	serverConfig.Mail.ResendInterval = config.GetDuration("mail.resend_interval")
+	serverConfig.RateLimit.RequestsPerMinute = config.GetInt("rate_limit.requests_per_minute")
+	serverConfig.RateLimit.MaxFailedLogins = config.GetInt("rate_limit.max_failed_logins")
+	serverConfig.RateLimit.LockoutDuration = config.GetDuration("rate_limit.lockout_duration")
+	serverConfig.RateLimit.MaxLockoutDuration = config.GetDuration("rate_limit.max_lockout_duration")
+	serverConfig.SSO.Issuer = config.GetString("sso.issuer")
+	serverConfig.SSO.ClientID = config.GetString("sso.client_id")
+	serverConfig.SSO.ClientSecret = config.GetString("sso.client_secret")
+	serverConfig.SSO.AutoProvision = config.GetBool("sso.auto_provision")
+	serverConfig.PasswordHash.Algorithm = config.GetString("password_hash.algorithm")
+	serverConfig.PasswordHash.Argon2Memory = config.GetUint32("password_hash.argon2_memory")
+	serverConfig.PasswordHash.Argon2Time = config.GetUint32("password_hash.argon2_time")
+	serverConfig.PasswordHash.Argon2Threads = uint8(config.GetUint("password_hash.argon2_threads"))
+	serverConfig.PasswordHash.BcryptCost = config.GetInt("password_hash.bcrypt_cost")
+	serverConfig.Reminders.Interval = config.GetDuration("reminders.interval")
+	serverConfig.Reminders.LeadTime = config.GetDuration("reminders.lead_time")
+	serverConfig.Reminders.DigestHour = config.GetInt("reminders.digest_hour")
+	serverConfig.Webhooks.AllowLocalReceivers = config.GetBool("webhooks.allow_local_receivers")
+	// the user commands hash passwords too
+	handlers.UsedPasswordHasher, err = newPasswordHasher(serverConfig.PasswordHash)
+	return err
+}
+
+// newPasswordHasher creates the hasher for new passwords from the configuration.
+func newPasswordHasher(hashConfig *server.PasswordHashConfig) (passhash.Hasher, error) {
+	switch hashConfig.Algorithm {
+	case passhash.AlgorithmArgon2id:
+		if hashConfig.Argon2Time == 0 || hashConfig.Argon2Memory == 0 || hashConfig.Argon2Threads == 0 {
+			return nil, errors.New("argon2 time, memory and threads must be greater than 0")
+		}
+		return &passhash.Argon2idHasher{
+			Time:       hashConfig.Argon2Time,
+			Memory:     hashConfig.Argon2Memory,
+			Threads:    hashConfig.Argon2Threads,
+			SaltLength: passhash.DefaultArgon2id.SaltLength,
+			KeyLength:  passhash.DefaultArgon2id.KeyLength,
+		}, nil
+	case passhash.AlgorithmBcrypt:
+		if hashConfig.BcryptCost < bcrypt.MinCost || hashConfig.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return &passhash.BcryptHasher{Cost: hashConfig.BcryptCost}, nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", hashConfig.Algorithm)
+	}
 }
 
 func must(err error) {
@@ -69,6 +137,26 @@ func init() {
 	must(config.BindPFlag("listen.host", serverCommand.PersistentFlags().Lookup("http-host")))
 	must(config.BindPFlag("database.url", serverCommand.PersistentFlags().Lookup("database")))
 	must(config.BindPFlag("auth.key", serverCommand.PersistentFlags().Lookup("auth-key")))
+	config.SetDefault("auth.access_token_lifetime", handlers.DefaultAccessTokenLifetime)
+	config.SetDefault("auth.refresh_token_lifetime", handlers.DefaultRefreshTokenLifetime)
+	config.SetDefault("auth.issuer", handlers.DefaultTokenIssuer)
+	config.SetDefault("auth.audience", handlers.DefaultTokenAudience)
+	config.SetDefault("auth.leeway", handlers.DefaultTokenLeeway)
+	config.SetDefault("auth.registration_mode", string(handlers.RegistrationOpen))
+	config.SetDefault("mail.port", 25)
+	config.SetDefault("mail.resend_interval", 10*time.Minute)
+	config.SetDefault("rate_limit.requests_per_minute", 10)
+	config.SetDefault("rate_limit.max_failed_logins", handlers.UsedLockoutPolicy.MaxFailures)
+	config.SetDefault("rate_limit.lockout_duration", handlers.UsedLockoutPolicy.Duration)
+	config.SetDefault("rate_limit.max_lockout_duration", handlers.UsedLockoutPolicy.MaxDuration)
+	config.SetDefault("password_hash.algorithm", passhash.AlgorithmArgon2id)
+	config.SetDefault("password_hash.argon2_memory", passhash.DefaultArgon2id.Memory)
+	config.SetDefault("password_hash.argon2_time", passhash.DefaultArgon2id.Time)
+	config.SetDefault("password_hash.argon2_threads", passhash.DefaultArgon2id.Threads)
+	config.SetDefault("password_hash.bcrypt_cost", passhash.DefaultBcrypt.Cost)
+	config.SetDefault("reminders.interval", handlers.DefaultReminderInterval)
+	config.SetDefault("reminders.lead_time", handlers.DefaultReminderLeadTime)
+	config.SetDefault("reminders.digest_hour", handlers.DefaultDigestHour)
 	config.SetConfigName("ruckd")
 	config.AddConfigPath(".")
 	config.AddConfigPath("/etc/ruckd")
@@ -89,71 +177,188 @@ func runServer(*cobra.Command, []string) error {
 
 	securelySeed()
 
-	key, err := loadPrivateKey(serverConfig.Auth.Key)
+	keys, err := loadPrivateKeys(serverConfig.Auth.Key)
 	if err != nil {
 		log.Fatal(err)
 	}
+	// the first key signs new tokens, the others are only used to verify old tokens
+	log.Printf("Signing tokens with key %s, %d keys for verification\n", keys.Keys[0].KeyID, len(keys.Keys))
 	handlers.UsedTokenIssuer = &handlers.JwtTokenIssuer{
-		PrivateKey: key,
+		PrivateKey:          &keys.Keys[0],
+		AccessTokenLifetime: serverConfig.Auth.AccessTokenLifetime,
+		Issuer:              serverConfig.Auth.Issuer,
+		Audience:            serverConfig.Auth.Audience,
 	}
+	handlers.RefreshTokenLifetime = serverConfig.Auth.RefreshTokenLifetime
 
-	var keyset = &jose.JSONWebKeySet{
-		Keys: []jose.JSONWebKey{
-			key.Public(),
-		},
+	handlers.UsedTokenVerifier = &handlers.JwtTokenVerifier{
+		KeySet:   publicKeySet(keys),
+		Issuer:   serverConfig.Auth.Issuer,
+		Audience: serverConfig.Auth.Audience,
+		Leeway:   serverConfig.Auth.Leeway,
 	}
 	authenticator = &handlers.Authenticator{
-		Verifier: &handlers.JwtTokenVerifier{KeySet: keyset},
+		Verifier: handlers.UsedTokenVerifier,
 	}
 
-	client, err := mongo.NewClient(options.Client().ApplyURI(serverConfig.Database.URL))
+	if serverConfig.Mail.IsConfigured() {
+		handlers.UsedMailer = &mail.SMTPMailer{
+			Host:     serverConfig.Mail.Host,
+			Port:     serverConfig.Mail.Port,
+			Username: serverConfig.Mail.Username,
+			Password: serverConfig.Mail.Password,
+			From:     serverConfig.Mail.From,
+		}
+	} else {
+		log.Println("Mail delivery is not configured")
+	}
+	handlers.PublicURL = serverConfig.Listen.PublicURL
+	handlers.RequireVerifiedEmail = serverConfig.Auth.RequireVerifiedEmail
+	handlers.UsedRegistrationMode, err = handlers.ParseRegistrationMode(serverConfig.Auth.RegistrationMode)
 	if err != nil {
 		log.Fatal(err)
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	err = client.Connect(ctx)
+	log.Printf("Registration mode: %s\n", handlers.UsedRegistrationMode)
+	handlers.SetVerificationResendInterval(serverConfig.Mail.ResendInterval)
+	handlers.UsedLockoutPolicy = handlers.LockoutPolicy{
+		MaxFailures: serverConfig.RateLimit.MaxFailedLogins,
+		Duration:    serverConfig.RateLimit.LockoutDuration,
+		MaxDuration: serverConfig.RateLimit.MaxLockoutDuration,
+	}
+	if serverConfig.SSO.Issuer != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		handlers.UsedIdentityProvider, err = oidc.Discover(ctx, serverConfig.SSO.Issuer,
+			serverConfig.SSO.ClientID, serverConfig.SSO.ClientSecret)
+		cancel()
+		if err != nil {
+			log.Fatal(err)
+		}
+		handlers.SSOAutoProvision = serverConfig.SSO.AutoProvision
+		log.Printf("Single sign-on with %s\n", serverConfig.SSO.Issuer)
+	}
+	handlers.ReminderLeadTime = serverConfig.Reminders.LeadTime
+	handlers.DigestHour = serverConfig.Reminders.DigestHour
+	handlers.AllowLocalWebhooks = serverConfig.Webhooks.AllowLocalReceivers
+	if handlers.AllowLocalWebhooks {
+		log.Println("Webhooks to local networks are allowed")
+	}
+	notifiers := notify.Multi{notify.LogNotifier{}, handlers.PushNotifier{}}
+	if handlers.UsedMailer != nil {
+		notifiers = append(notifiers, handlers.MailNotifier{})
+	}
+	handlers.UsedNotifier = notifiers
+	rateLimiter := handlers.NewRateLimiter(serverConfig.RateLimit.RequestsPerMinute)
+
+	db, err := connectDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db := client.Database("ruck")
 	handlers.SetDB(db)
+	go handlers.RunWebhookWorker(context.Background())
+	if serverConfig.Reminders.Interval > 0 {
+		go handlers.RunReminderScheduler(context.Background(), serverConfig.Reminders.Interval)
+	} else {
+		log.Println("Reminders are disabled")
+	}
 
 	addr := serverConfig.Listen.GetServerAddress()
 	log.Printf("Starting server at %s\n", addr)
+	return http.ListenAndServe(addr, handlers.RecoverMiddleWare(newRouter(rateLimiter)))
+}
+
+// newRouter returns the router with all routes of the server. The routes must be described in the
+// OpenAPI document of the handlers package.
+func newRouter(rateLimiter *handlers.RateLimiter) *mux.Router {
 	router := mux.NewRouter()
-	router.HandleFunc("/login", handlers.LoginUser).Methods("POST")
-	router.HandleFunc("/register", handlers.RegisterUser).Methods("POST")
+	router.NotFoundHandler = http.HandlerFunc(handlers.NotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(handlers.MethodNotAllowed)
+	registerAPIRoutes(router.PathPrefix(api.Prefix).Subrouter(), rateLimiter)
+	// routes which are linked from mails, the identity provider or other servers
+	router.HandleFunc("/.well-known/jwks.json", handlers.GetJSONWebKeySet).Methods("GET")
+	router.HandleFunc("/openapi.json", handlers.GetOpenAPIDocument).Methods("GET")
+	router.HandleFunc("/verify-email", handlers.ShowVerifyEmailPage).Methods("GET")
+	router.HandleFunc("/sso/callback", handlers.SSOCallback).Methods("GET")
+	router.HandleFunc("/unsubscribe", handlers.ShowUnsubscribePage).Methods("GET")
+	router.HandleFunc("/unsubscribe", handlers.Unsubscribe).Methods("POST")
+	router.HandleFunc("/calendar/{token:[A-Za-z0-9_-]+}.ics", handlers.GetCalendar).Methods("GET")
+	// the API without version prefix is deprecated
+	legacy := router.NewRoute().Subrouter()
+	legacy.Use(handlers.DeprecatedMiddleWare)
+	registerAPIRoutes(legacy, rateLimiter)
+	return router
+}
+
+// registerAPIRoutes adds the routes of the API to the router.
+func registerAPIRoutes(router *mux.Router, rateLimiter *handlers.RateLimiter) {
+	router.HandleFunc("/version", handlers.GetServerInfo).Methods("GET")
+	router.Handle("/login", rateLimiter.MiddleWare(http.HandlerFunc(handlers.LoginUser))).Methods("POST")
+	router.Handle("/login/2fa", rateLimiter.MiddleWare(http.HandlerFunc(handlers.CompleteTwoFactorLogin))).Methods("POST")
+	router.Handle("/register", rateLimiter.MiddleWare(http.HandlerFunc(handlers.RegisterUser))).Methods("POST")
+	router.HandleFunc("/verify-email", handlers.VerifyEmail).Methods("POST")
+	router.Handle("/verify-email/resend", rateLimiter.MiddleWare(http.HandlerFunc(handlers.ResendVerificationEmail))).Methods("POST")
+	router.Handle("/password/forgot", rateLimiter.MiddleWare(http.HandlerFunc(handlers.ForgotPassword))).Methods("POST")
+	router.HandleFunc("/password/reset", handlers.ResetPassword).Methods("POST")
+	router.HandleFunc("/token/refresh", handlers.RefreshToken).Methods("POST")
+	router.HandleFunc("/sso/login", handlers.StartSSOLogin).Methods("GET")
+	router.Handle("/sso/token", rateLimiter.MiddleWare(http.HandlerFunc(handlers.ExchangeSSOCode))).Methods("POST")
 
-	api := router.MatcherFunc(func(request *http.Request, match *mux.RouteMatch) bool {
+	authenticated := router.MatcherFunc(func(request *http.Request, match *mux.RouteMatch) bool {
 		return "" != request.Header.Get("Authorization")
 	}).Subrouter()
-	api.HandleFunc("/groups", handlers.GetAllGroups).Methods("GET")
-	api.HandleFunc("/groups", handlers.CreateGroup).Methods("POST")
-	api.HandleFunc("/groups/{groupId}", handlers.GetGroup).Methods("GET")
-	api.HandleFunc("/groups/{groupId}", handlers.DeleteGroup).Methods("DELETE")
-	api.HandleFunc("/groups/{groupId}/join", handlers.JoinGroup).Methods("POST")
-	api.HandleFunc("/groups/{groupId}/tasks", handlers.CreateTaskForGroup).Methods("POST")
-	api.HandleFunc("/tasks", handlers.GetAllTasks).Methods("GET")
-	api.HandleFunc("/tasks/{taskId}", handlers.GetTaskById).Methods("GET")
-	api.HandleFunc("/tasks/{taskId}/complete", handlers.CreateTaskExecution).Methods("POST")
-	api.Use(authenticator.MiddleWare)
-
-	return http.ListenAndServe(addr, router)
+	authenticated.HandleFunc("/logout", handlers.Logout).Methods("POST")
+	authenticated.HandleFunc("/account", handlers.GetAccount).Methods("GET")
+	authenticated.HandleFunc("/account", handlers.UpdateAccount).Methods("PATCH")
+	authenticated.HandleFunc("/account", handlers.DeleteAccount).Methods("DELETE")
+	authenticated.HandleFunc("/account/export", handlers.ExportAccount).Methods("GET")
+	authenticated.HandleFunc("/account/password", handlers.ChangePassword).Methods("POST")
+	authenticated.HandleFunc("/account/2fa", handlers.EnrollTwoFactor).Methods("POST")
+	authenticated.HandleFunc("/account/2fa/confirm", handlers.ConfirmTwoFactor).Methods("POST")
+	authenticated.HandleFunc("/account/2fa/disable", handlers.DisableTwoFactor).Methods("POST")
+	authenticated.HandleFunc("/account/notifications", handlers.GetNotificationSettings).Methods("GET")
+	authenticated.HandleFunc("/account/notifications", handlers.UpdateNotificationSettings).Methods("PATCH")
+	authenticated.HandleFunc("/account/push", handlers.GetPushSettings).Methods("GET")
+	authenticated.HandleFunc("/account/push", handlers.SetPushSettings).Methods("PUT")
+	authenticated.HandleFunc("/account/push", handlers.DeletePushSettings).Methods("DELETE")
+	authenticated.HandleFunc("/account/push/test", handlers.TestPush).Methods("POST")
+	authenticated.HandleFunc("/account/calendar", handlers.GetCalendarFeedSettings).Methods("GET")
+	authenticated.HandleFunc("/account/calendar", handlers.RotateCalendarFeed).Methods("POST")
+	authenticated.HandleFunc("/account/calendar", handlers.DeleteCalendarFeed).Methods("DELETE")
+	authenticated.HandleFunc("/account/tokens", handlers.GetPersonalAccessTokens).Methods("GET")
+	authenticated.HandleFunc("/account/tokens", handlers.CreatePersonalAccessToken).Methods("POST")
+	authenticated.HandleFunc("/account/tokens/{tokenId}", handlers.RevokePersonalAccessToken).Methods("DELETE")
+	authenticated.HandleFunc("/groups", handlers.GetAllGroups).Methods("GET")
+	authenticated.HandleFunc("/groups", handlers.CreateGroup).Methods("POST")
+	authenticated.HandleFunc("/groups/{groupId}", handlers.GetGroup).Methods("GET")
+	authenticated.HandleFunc("/groups/{groupId}", handlers.UpdateGroup).Methods("PATCH")
+	authenticated.HandleFunc("/groups/{groupId}", handlers.DeleteGroup).Methods("DELETE")
+	authenticated.HandleFunc("/groups/{groupId}/join", handlers.JoinGroup).Methods("POST")
+	authenticated.HandleFunc("/groups/{groupId}/balance", handlers.GetGroupBalance).Methods("GET")
+	authenticated.HandleFunc("/groups/{groupId}/tasks", handlers.CreateTaskForGroup).Methods("POST")
+	authenticated.HandleFunc("/groups/{groupId}/webhooks", handlers.GetWebhooks).Methods("GET")
+	authenticated.HandleFunc("/groups/{groupId}/webhooks", handlers.CreateWebhook).Methods("POST")
+	authenticated.HandleFunc("/groups/{groupId}/webhooks/{webhookId}", handlers.DeleteWebhook).Methods("DELETE")
+	authenticated.HandleFunc("/groups/{groupId}/webhooks/{webhookId}/deliveries", handlers.GetWebhookDeliveries).Methods("GET")
+	authenticated.HandleFunc("/groups/{groupId}/webhooks/{webhookId}/test", handlers.TestWebhook).Methods("POST")
+	authenticated.HandleFunc("/tasks", handlers.GetAllTasks).Methods("GET")
+	authenticated.HandleFunc("/tasks/{taskId}", handlers.GetTaskById).Methods("GET")
+	authenticated.HandleFunc("/tasks/{taskId}/complete", handlers.CreateTaskExecution).Methods("POST")
+	authenticated.HandleFunc("/tasks/{taskId}/history", handlers.GetTaskHistory).Methods("GET")
+	authenticated.HandleFunc("/tasks/{taskId}/claim", handlers.ClaimTask).Methods("POST")
+	authenticated.HandleFunc("/events", handlers.StreamEvents).Methods("GET")
+	authenticated.Use(authenticator.MiddleWare)
 }
 
-func loadPrivateKey(filename string) (*jose.JSONWebKey, error) {
-	var key jose.JSONWebKey
-	fp, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	b, err := ioutil.ReadAll(fp)
+// connectDatabase connects to the configured MongoDB and returns the ruck database.
+func connectDatabase() (*mongo.Database, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI(serverConfig.Database.URL))
 	if err != nil {
 		return nil, err
 	}
-	err = key.UnmarshalJSON(b)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &key, nil
+	return client.Database("ruck"), nil
 }
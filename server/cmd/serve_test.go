@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/coffeemakr/ruck/api"
+	"github.com/coffeemakr/ruck/server/handlers"
+	"github.com/gorilla/mux"
+)
+
+// pathVariablePattern matches the regular expressions of path variables like {token:[a-z]+}.
+var pathVariablePattern = regexp.MustCompile(`\{(\w+):[^}]+\}`)
+
+var httpMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPut: true, http.MethodPost: true, http.MethodDelete: true,
+	http.MethodOptions: true, http.MethodHead: true, http.MethodPatch: true, http.MethodTrace: true,
+}
+
+// documentedOperations returns the operations of the OpenAPI document as "METHOD path".
+func documentedOperations(t *testing.T) map[string]bool {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handlers.GetOpenAPIDocument(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var document struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	operations := make(map[string]bool)
+	for path, item := range document.Paths {
+		for key := range item {
+			// the path item also contains the parameters, summary and description
+			if method := strings.ToUpper(key); httpMethods[method] {
+				operations[method+" "+path] = true
+			}
+		}
+	}
+	return operations
+}
+
+// routedOperations returns the routes of the router as "METHOD path".
+func routedOperations(t *testing.T, router *mux.Router) map[string]bool {
+	t.Helper()
+	operations := make(map[string]bool)
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			// subrouters without a path
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// path prefixes of subrouters
+			return nil
+		}
+		for _, method := range methods {
+			operations[method+" "+pathVariablePattern.ReplaceAllString(template, "{$1}")] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return operations
+}
+
+func TestRoutesMatchOpenAPIDocument(t *testing.T) {
+	authenticator = &handlers.Authenticator{}
+	documented := documentedOperations(t)
+	routed := routedOperations(t, newRouter(handlers.NewRateLimiter(60)))
+
+	var undocumented, missing []string
+	for operation := range routed {
+		if documented[operation] {
+			continue
+		}
+		// the deprecated routes without version prefix are described by the versioned ones
+		parts := strings.SplitN(operation, " ", 2)
+		if documented[parts[0]+" "+api.Prefix+parts[1]] && routed[parts[0]+" "+api.Prefix+parts[1]] {
+			continue
+		}
+		undocumented = append(undocumented, operation)
+	}
+	for operation := range documented {
+		if !routed[operation] {
+			missing = append(missing, operation)
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(missing)
+	for _, operation := range undocumented {
+		t.Errorf("route %s is not in the OpenAPI document", operation)
+	}
+	for _, operation := range missing {
+		t.Errorf("operation %s of the OpenAPI document has no route", operation)
+	}
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/handlers"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	userCommand = &cobra.Command{
+		Use:               "user",
+		Short:             "Manage the users in the configured database",
+		PersistentPreRunE: initUserCommand,
+	}
+	userListCommand = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		RunE:    runUserList,
+		Args:    cobra.NoArgs,
+	}
+	userCreateCommand = &cobra.Command{
+		Use:  "create NAME",
+		RunE: runUserCreate,
+		Args: cobra.ExactArgs(1),
+	}
+	userDisableCommand = &cobra.Command{
+		Use:  "disable NAME",
+		RunE: runUserDisable,
+		Args: cobra.ExactArgs(1),
+	}
+	userEnableCommand = &cobra.Command{
+		Use:  "enable NAME",
+		RunE: runUserEnable,
+		Args: cobra.ExactArgs(1),
+	}
+	userDeleteCommand = &cobra.Command{
+		Use:  "delete NAME",
+		RunE: runUserDelete,
+		Args: cobra.ExactArgs(1),
+	}
+	userUnlockCommand = &cobra.Command{
+		Use:   "unlock NAME",
+		Short: "Remove the lockout after failed logins",
+		RunE:  runUserUnlock,
+		Args:  cobra.ExactArgs(1),
+	}
+	userDisableTwoFactorCommand = &cobra.Command{
+		Use:   "disable-2fa NAME",
+		Short: "Disable the two-factor authentication of a user who lost the device",
+		RunE:  runUserDisableTwoFactor,
+		Args:  cobra.ExactArgs(1),
+	}
+	userResetPasswordCommand = &cobra.Command{
+		Use:   "reset-password NAME",
+		Short: "Set a new password or create a reset token for the user",
+		RunE:  runUserResetPassword,
+		Args:  cobra.ExactArgs(1),
+	}
+	userCreateEmail        string
+	userCreateVerified     bool
+	userDeleteForce        bool
+	userResetPasswordToken bool
+)
+
+func init() {
+	userCreateCommand.Flags().StringVar(&userCreateEmail, "email", "", "The email address of the user")
+	userCreateCommand.Flags().BoolVar(&userCreateVerified, "verified", false, "Mark the email address as verified")
+	userDeleteCommand.Flags().BoolVarP(&userDeleteForce, "force", "f", false, "Don't ask for confirmation")
+	userResetPasswordCommand.Flags().BoolVar(&userResetPasswordToken, "token", false,
+		"Print a single-use reset token for the user instead of setting the password")
+	userCommand.AddCommand(userListCommand, userCreateCommand, userDisableCommand, userEnableCommand,
+		userDeleteCommand, userUnlockCommand, userDisableTwoFactorCommand, userResetPasswordCommand)
+}
+
+func initUserCommand(cmd *cobra.Command, args []string) error {
+	if err := initConfig(cmd, args); err != nil {
+		return err
+	}
+	db, err := connectDatabase()
+	if err != nil {
+		return err
+	}
+	handlers.SetDB(db)
+	return nil
+}
+
+func readNewPassword() ([]byte, error) {
+	fmt.Print("Password              : ")
+	password, err := terminal.ReadPassword(syscall.Stdin)
+	fmt.Println()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Print("Password Confirmation : ")
+	confirmation, err := terminal.ReadPassword(syscall.Stdin)
+	fmt.Println()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(password, confirmation) {
+		return nil, errors.New("passwords don't match")
+	}
+	return password, nil
+}
+
+func runUserList(*cobra.Command, []string) error {
+	ctx := context.Background()
+	users, err := handlers.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	loginFailures, err := handlers.GetLoginFailures(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	fmt.Printf("%-20s %-30s %-9s %-9s %-8s %s\n", "NAME", "EMAIL", "VERIFIED", "DISABLED", "FAILURES", "LOCKED UNTIL")
+	for _, user := range users {
+		var failureCount int
+		lockedUntil := "-"
+		if failures, ok := loginFailures[user.Name]; ok {
+			failureCount = failures.Failures
+			if failures.IsLocked(now) {
+				lockedUntil = failures.LockedUntil.Format(time.RFC3339)
+			}
+		}
+		fmt.Printf("%-20s %-30s %-9t %-9t %-8d %s\n", user.Name, user.EmailAddress, user.EmailVerified,
+			user.IsDisabled, failureCount, lockedUntil)
+	}
+	return nil
+}
+
+func runUserUnlock(cmd *cobra.Command, args []string) error {
+	return handlers.UnlockUser(context.Background(), args[0])
+}
+
+func runUserCreate(cmd *cobra.Command, args []string) error {
+	password, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+	user, err := handlers.CreateUser(context.Background(), &ruck.RegistrationRequest{
+		Name:                 args[0],
+		Email:                userCreateEmail,
+		Password:             password,
+		PasswordConfirmation: password,
+	}, userCreateVerified)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("User %s created.\n", user.Name)
+	return nil
+}
+
+func runUserDisable(cmd *cobra.Command, args []string) error {
+	return handlers.SetUserDisabled(context.Background(), args[0], true)
+}
+
+func runUserEnable(cmd *cobra.Command, args []string) error {
+	return handlers.SetUserDisabled(context.Background(), args[0], false)
+}
+
+func runUserDelete(cmd *cobra.Command, args []string) error {
+	if !userDeleteForce {
+		answer, err := askYesOrNo(fmt.Sprintf("Delete user %s?", args[0]))
+		if err != nil {
+			return err
+		}
+		if !answer {
+			return errors.New("user abort")
+		}
+	}
+	return handlers.DeleteUser(context.Background(), args[0])
+}
+
+func runUserDisableTwoFactor(cmd *cobra.Command, args []string) error {
+	return handlers.DisableTwoFactorForUser(context.Background(), args[0])
+}
+
+func runUserResetPassword(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	if userResetPasswordToken {
+		token, err := handlers.CreatePasswordResetToken(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reset token: %s\nThe user can set a new password with:\n\n    ruck account reset-password %s\n", token, token)
+		return nil
+	}
+	password, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+	return handlers.SetPassword(ctx, args[0], password)
+}
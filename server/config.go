@@ -1,10 +1,16 @@
 package server
 
-import "strconv"
+import (
+	"strconv"
+	"time"
+)
 
 type ListenConfig struct {
 	Port int    `json:"port,omitempty" yaml:"port,omitempty"`
 	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+	// PublicURL is the URL under which the server is reachable by users.
+	// It is used to build links, e.g. in verification mails.
+	PublicURL string `json:"public_url,omitempty" yaml:"public_url,omitempty"`
 }
 
 func (c *ListenConfig) GetServerAddress() string {
@@ -18,10 +24,87 @@ type DatabaseConfig struct {
 
 type AuthenticationConfig struct {
 	Key string `json:"key" yaml:"key,omitempty"`
+	// RequireVerifiedEmail blocks the login of users that did not verify their email address.
+	RequireVerifiedEmail bool `json:"require_verified_email,omitempty" yaml:"require_verified_email,omitempty"`
+	// AccessTokenLifetime is the time an access token is valid. Clients use the refresh token afterwards.
+	AccessTokenLifetime  time.Duration `json:"access_token_lifetime,omitempty" yaml:"access_token_lifetime,omitempty"`
+	RefreshTokenLifetime time.Duration `json:"refresh_token_lifetime,omitempty" yaml:"refresh_token_lifetime,omitempty"`
+	// Issuer and Audience are set in issued tokens and required in received tokens.
+	Issuer   string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	Audience string `json:"audience,omitempty" yaml:"audience,omitempty"`
+	// Leeway is the allowed clock skew when checking the expiry of tokens.
+	Leeway time.Duration `json:"leeway,omitempty" yaml:"leeway,omitempty"`
+	// RegistrationMode is open, invite (registration code or group ID required) or closed.
+	RegistrationMode string `json:"registration_mode,omitempty" yaml:"registration_mode,omitempty"`
+}
+
+type MailConfig struct {
+	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
+	Port     int    `json:"port,omitempty" yaml:"port,omitempty"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	From     string `json:"from,omitempty" yaml:"from,omitempty"`
+	// ResendInterval is the minimal time between two verification mails for the same user.
+	ResendInterval time.Duration `json:"resend_interval,omitempty" yaml:"resend_interval,omitempty"`
+}
+
+// IsConfigured returns true if enough information is set to send mails.
+func (c *MailConfig) IsConfigured() bool {
+	return c != nil && c.Host != "" && c.From != ""
+}
+
+type RateLimitConfig struct {
+	// RequestsPerMinute is the number of login and registration requests allowed per IP address.
+	RequestsPerMinute int `json:"requests_per_minute" yaml:"requests_per_minute"`
+	// MaxFailedLogins is the number of failed logins after which an account is locked.
+	MaxFailedLogins int `json:"max_failed_logins" yaml:"max_failed_logins"`
+	// LockoutDuration is the duration of the first lockout, every further failure doubles it.
+	LockoutDuration    time.Duration `json:"lockout_duration" yaml:"lockout_duration"`
+	MaxLockoutDuration time.Duration `json:"max_lockout_duration" yaml:"max_lockout_duration"`
+}
+
+type SSOConfig struct {
+	// Issuer is the URL of the OpenID provider, SSO is disabled if it is empty.
+	Issuer       string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	ClientID     string `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	// AutoProvision creates a user at the first login if no user with the verified email address exists.
+	AutoProvision bool `json:"auto_provision,omitempty" yaml:"auto_provision,omitempty"`
+}
+
+type PasswordHashConfig struct {
+	// Algorithm is argon2id or bcrypt. Existing hashes of the other algorithm are still accepted.
+	Algorithm string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+	// Argon2Memory is the memory used to hash a password in KiB.
+	Argon2Memory  uint32 `json:"argon2_memory,omitempty" yaml:"argon2_memory,omitempty"`
+	Argon2Time    uint32 `json:"argon2_time,omitempty" yaml:"argon2_time,omitempty"`
+	Argon2Threads uint8  `json:"argon2_threads,omitempty" yaml:"argon2_threads,omitempty"`
+	BcryptCost    int    `json:"bcrypt_cost,omitempty" yaml:"bcrypt_cost,omitempty"`
+}
+
+type ReminderConfig struct {
+	// Interval is the time between two scans for due tasks, reminders are disabled if it is zero.
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// LeadTime is the time before the due date at which the assignee is reminded, if the group
+	// doesn't set its own.
+	LeadTime time.Duration `json:"lead_time,omitempty" yaml:"lead_time,omitempty"`
+	// DigestHour is the hour of the day in the time zone of the user at which digest mails are sent.
+	DigestHour int `json:"digest_hour,omitempty" yaml:"digest_hour,omitempty"`
+}
+
+type WebhookConfig struct {
+	// AllowLocalReceivers allows webhooks to the server itself and to private networks.
+	AllowLocalReceivers bool `json:"allow_local_receivers,omitempty" yaml:"allow_local_receivers,omitempty"`
 }
 
 type Configuration struct {
-	Listen   *ListenConfig         `json:"listen,omitempty" yaml:",omitempty"`
-	Database *DatabaseConfig       `json:"database,omitempty" yaml:",omitempty"`
-	Auth     *AuthenticationConfig `json:"auth,omitempty" yaml:",omitempty"`
+	Listen       *ListenConfig         `json:"listen,omitempty" yaml:",omitempty"`
+	Database     *DatabaseConfig       `json:"database,omitempty" yaml:",omitempty"`
+	Auth         *AuthenticationConfig `json:"auth,omitempty" yaml:",omitempty"`
+	Mail         *MailConfig           `json:"mail,omitempty" yaml:",omitempty"`
+	RateLimit    *RateLimitConfig      `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"`
+	SSO          *SSOConfig            `json:"sso,omitempty" yaml:"sso,omitempty"`
+	PasswordHash *PasswordHashConfig   `json:"password_hash,omitempty" yaml:"password_hash,omitempty"`
+	Reminders    *ReminderConfig       `json:"reminders,omitempty" yaml:"reminders,omitempty"`
+	Webhooks     *WebhookConfig        `json:"webhooks,omitempty" yaml:"webhooks,omitempty"`
 }
@@ -0,0 +1,84 @@
+// Package events distributes events of the handlers to the event streams of the users.
+package events
+
+import (
+	"log"
+	"sync"
+
+	"github.com/coffeemakr/ruck/api"
+)
+
+// subscriptionBuffer is the number of events a subscriber may fall behind before it is dropped.
+const subscriptionBuffer = 64
+
+// Bus is a publish-subscribe bus within the server process.
+type Bus struct {
+	mutex         sync.Mutex
+	subscriptions map[*Subscription]struct{}
+}
+
+// Subscription receives the events of a user. Events is closed if the subscriber is too slow or
+// the subscription is closed.
+type Subscription struct {
+	UserName string
+	Events   <-chan *api.Event
+	events   chan *api.Event
+	bus      *Bus
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe returns a subscription for the events of the user. It must be closed after use.
+func (b *Bus) Subscribe(userName string) *Subscription {
+	events := make(chan *api.Event, subscriptionBuffer)
+	subscription := &Subscription{
+		UserName: userName,
+		Events:   events,
+		events:   events,
+		bus:      b,
+	}
+	b.mutex.Lock()
+	b.subscriptions[subscription] = struct{}{}
+	b.mutex.Unlock()
+	return subscription
+}
+
+// Publish sends the event to all subscriptions of the recipients. It never blocks.
+func (b *Bus) Publish(event *api.Event, recipients []string) {
+	names := make(map[string]bool, len(recipients))
+	for _, name := range recipients {
+		names[name] = true
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for subscription := range b.subscriptions {
+		if !names[subscription.UserName] {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			log.Printf("Dropping event subscription of %s, it is too slow\n", subscription.UserName)
+			b.remove(subscription)
+		}
+	}
+}
+
+// remove must be called with the mutex locked.
+func (b *Bus) remove(subscription *Subscription) {
+	if _, ok := b.subscriptions[subscription]; ok {
+		delete(b.subscriptions, subscription)
+		close(subscription.events)
+	}
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.bus.mutex.Lock()
+	s.bus.remove(s)
+	s.bus.mutex.Unlock()
+}
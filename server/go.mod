@@ -3,7 +3,6 @@ module github.com/coffeemakr/ruck/server
 go 1.14
 
 require (
-	github.com/coffeemakr/go-http-error v1.5.0
 	github.com/coffeemakr/ruck v0.0.0
 	github.com/fsnotify/fsnotify v1.4.9 // indirect
 	github.com/gorilla/mux v1.7.4
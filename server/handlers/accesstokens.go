@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// personalAccessTokenPrefix distinguishes personal access tokens from JWTs.
+const personalAccessTokenPrefix = "ruck_pat_"
+
+const maxAccessTokenNameLength = 100
+
+var (
+	HttpErrAccessTokenNotFound = NewErrorType(http.StatusNotFound, ruck.ErrorCodeAccessTokenNotFound, "Access token not found")
+	HttpErrInvalidScope        = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidScope, "Invalid scope")
+	HttpErrInvalidTokenName    = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidTokenName, "Invalid token name")
+	ErrNoSuchAccessToken       = errors.New("no such access token")
+)
+
+// accessTokenModel is the stored form of a personal access token.
+type accessTokenModel struct {
+	ID         string          `bson:"id"`
+	Name       string          `bson:"name"`
+	UserName   string          `bson:"username"`
+	Scope      ruck.TokenScope `bson:"scope"`
+	Hash       string          `bson:"hash"`
+	CreatedAt  time.Time       `bson:"createdat"`
+	LastUsedAt time.Time       `bson:"lastusedat"`
+}
+
+func (m *accessTokenModel) toPersonalAccessToken() *ruck.PersonalAccessToken {
+	return &ruck.PersonalAccessToken{
+		ID:         m.ID,
+		Name:       m.Name,
+		Scope:      m.Scope,
+		CreatedAt:  m.CreatedAt,
+		LastUsedAt: m.LastUsedAt,
+	}
+}
+
+func getTokenId(r *http.Request) string {
+	var vars = mux.Vars(r)
+	tokenId, ok := vars["tokenId"]
+	if !ok || tokenId == "" {
+		panic("Can't read token id")
+	}
+	return tokenId
+}
+
+func createPersonalAccessToken(ctx context.Context, userName string, request *ruck.PersonalAccessTokenRequest) (*ruck.PersonalAccessToken, error) {
+	secret, err := generateSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	token := personalAccessTokenPrefix + secret
+	model := accessTokenModel{
+		ID:        generateId(),
+		Name:      request.Name,
+		UserName:  userName,
+		Scope:     request.Scope,
+		Hash:      hashSecretToken(token),
+		CreatedAt: time.Now(),
+	}
+	if _, err := accessTokenCollection.InsertOne(ctx, &model); err != nil {
+		return nil, fmt.Errorf("failed to store access token: %s", err)
+	}
+	result := model.toPersonalAccessToken()
+	result.Token = token
+	return result, nil
+}
+
+func getPersonalAccessTokens(ctx context.Context, userName string) ([]*ruck.PersonalAccessToken, error) {
+	results := []*ruck.PersonalAccessToken{}
+	cursor, err := accessTokenCollection.Find(ctx, bson.M{"username": userName},
+		options.Find().SetSort(bson.M{"createdat": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var model accessTokenModel
+		if err := cursor.Decode(&model); err != nil {
+			return nil, err
+		}
+		results = append(results, model.toPersonalAccessToken())
+	}
+	return results, cursor.Err()
+}
+
+func deletePersonalAccessToken(ctx context.Context, userName string, tokenId string) error {
+	result, err := accessTokenCollection.DeleteOne(ctx, bson.M{"id": tokenId, "username": userName})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNoSuchAccessToken
+	}
+	return nil
+}
+
+// verifyPersonalAccessToken looks up the token and records its usage.
+func verifyPersonalAccessToken(ctx context.Context, token string) (*ruck.DecodedToken, error) {
+	var model accessTokenModel
+	err := accessTokenCollection.FindOneAndUpdate(ctx, bson.M{
+		"hash": hashSecretToken(token),
+	}, bson.M{
+		"$set": bson.M{"lastusedat": time.Now()},
+	}).Decode(&model)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			err = fmt.Errorf("%w: unknown personal access token", ErrTokenInvalid)
+		}
+		return nil, err
+	}
+	return &ruck.DecodedToken{
+		ID:       model.ID,
+		UserName: model.UserName,
+		Scope:    model.Scope,
+		IssuedAt: model.CreatedAt,
+	}, nil
+}
+
+func CreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	var request ruck.PersonalAccessTokenRequest
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || len(request.Name) > maxAccessTokenNameLength {
+		HttpErrInvalidTokenName.Causef("invalid token name '%s'", request.Name).Write(w, r)
+		return
+	}
+	if !request.Scope.IsValidForPersonalAccessToken() {
+		HttpErrInvalidScope.Causef("invalid scope '%s'", request.Scope).Write(w, r)
+		return
+	}
+	token, err := createPersonalAccessToken(r.Context(), userName, &request)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	log.Printf("User %s created access token %s with scope %s\n", userName, token.ID, token.Scope)
+	mustWriteJsonWithStatus(w, http.StatusCreated, token)
+}
+
+func GetPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	tokens, err := getPersonalAccessTokens(r.Context(), userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, tokens)
+}
+
+func RevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	tokenId := getTokenId(r)
+	err = deletePersonalAccessToken(r.Context(), userName, tokenId)
+	switch err {
+	case nil:
+		log.Printf("User %s revoked access token %s\n", userName, tokenId)
+		w.WriteHeader(http.StatusNoContent)
+	case ErrNoSuchAccessToken:
+		HttpErrAccessTokenNotFound.Cause(err).Write(w, r)
+	default:
+		HttpErrInternal.Cause(err).Write(w, r)
+	}
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"github.com/coffeemakr/ruck/server/passhash"
+	"go.mongodb.org/mongo-driver/bson"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxDisplayNameLength = 100
+
+var (
+	HttpErrInvalidDisplayName = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidDisplayName, "Invalid display name")
+	HttpErrInvalidTimeZone    = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidTimeZone, "Unknown time zone")
+	HttpErrInvalidLanguage    = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidLanguage, "Invalid language tag")
+	HttpErrInvalidEmail       = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidEmail, "Invalid email address")
+
+	languageTagPattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+)
+
+// GetAccount returns the user of the request.
+func GetAccount(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	user, err := getUserForName(r.Context(), userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, user)
+}
+
+// validateEmailAddress returns the error to send to the client if the address can't be used for mails.
+func validateEmailAddress(email string) *Error {
+	if !strings.Contains(email, "@") || strings.ContainsAny(email, " \r\n") {
+		return HttpErrInvalidEmail.Causef("invalid address %q", email)
+	}
+	return nil
+}
+
+// validateAccountUpdate returns the fields to set or writes an error.
+func validateAccountUpdate(w http.ResponseWriter, r *http.Request, request *ruck.AccountUpdateRequest) (bson.M, bool) {
+	update := bson.M{}
+	if request.DisplayName != nil {
+		displayName := strings.TrimSpace(*request.DisplayName)
+		if utf8.RuneCountInString(displayName) > maxDisplayNameLength || strings.ContainsAny(displayName, "\r\n\t") {
+			HttpErrInvalidDisplayName.CauseString("invalid display name").Write(w, r)
+			return nil, false
+		}
+		update["displayname"] = displayName
+	}
+	if request.TimeZone != nil {
+		if *request.TimeZone != "" {
+			if _, err := time.LoadLocation(*request.TimeZone); err != nil {
+				HttpErrInvalidTimeZone.Cause(err).Write(w, r)
+				return nil, false
+			}
+		}
+		update["timezone"] = *request.TimeZone
+	}
+	if request.Language != nil {
+		if *request.Language != "" && !languageTagPattern.MatchString(*request.Language) {
+			HttpErrInvalidLanguage.Causef("invalid tag %q", *request.Language).Write(w, r)
+			return nil, false
+		}
+		update["language"] = *request.Language
+	}
+	if request.Email != nil {
+		email := strings.TrimSpace(*request.Email)
+		if httpErr := validateEmailAddress(email); httpErr != nil {
+			httpErr.Write(w, r)
+			return nil, false
+		}
+		update["emailaddress"] = email
+		update["emailverified"] = false
+	}
+	return update, true
+}
+
+// UpdateAccount changes the profile of the user. A changed email address must be verified again.
+func UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.AccountUpdateRequest
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	user, err := getUserForName(ctx, userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if request.Email != nil && strings.TrimSpace(*request.Email) == user.EmailAddress {
+		// unchanged, keep the verification
+		request.Email = nil
+	}
+	update, ok := validateAccountUpdate(w, r, &request)
+	if !ok {
+		return
+	}
+	if len(update) != 0 {
+		if _, err := usersCollection.UpdateOne(ctx, bson.M{userFieldName: userName}, bson.M{"$set": update}); err != nil {
+			HttpErrInternal.Cause(err).Write(w, r)
+			return
+		}
+	}
+	user, err = getUserForName(ctx, userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if request.Email != nil && UsedMailer != nil {
+		if err := sendVerificationMail(user); err != nil {
+			log.Printf("Failed to send verification mail to %s: %s\n", user.Name, err)
+		}
+	}
+	writeResponse(w, r, user)
+}
+
+func getExecutionsOfUser(ctx context.Context, userName string) ([]*ruck.TaskExecution, error) {
+	cursor, err := taskExecutionCollection.Find(ctx, bson.M{"executor_id": userName})
+	if err != nil {
+		return nil, err
+	}
+	executions := make([]*ruck.TaskExecution, 0)
+	if err := cursor.All(ctx, &executions); err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+// ExportAccount returns all data of the user, including the groups, tasks and task executions.
+func ExportAccount(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var export ruck.AccountExport
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if export.User, err = getUserForName(ctx, userName); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if export.Groups, err = getGroups(ctx, userName); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if export.Tasks, err = getTasksForUser(ctx, userName); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if export.Executions, err = getExecutionsOfUser(ctx, userName); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if export.NotificationSettings, err = getNotificationSettings(ctx, userName); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if pushSettings, err := getPushSettings(ctx, userName); err == nil {
+		export.PushSettings = withoutToken(pushSettings)
+	} else if err != ErrPushNotConfigured {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	// empty lists instead of null
+	if export.Groups == nil {
+		export.Groups = []*ruck.Group{}
+	}
+	if export.Tasks == nil {
+		export.Tasks = []*ruck.Task{}
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="ruck-export.json"`)
+	writeResponse(w, r, &export)
+}
+
+// DeleteAccount deletes the user of the request. Users with a password have to confirm it.
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.AccountDeletionRequest
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	user, err := getUserWithPasswordForName(ctx, userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	// users of single sign-on have no password
+	if len(user.PasswordHash) != 0 {
+		matches, err := passhash.Verify(user.PasswordHash, request.Password)
+		if err != nil {
+			HttpErrInternal.Cause(err).Write(w, r)
+			return
+		}
+		if !matches {
+			HttpErrWrongPassword.Causef("%s tried to delete the account", userName).Write(w, r)
+			return
+		}
+	}
+	if err := DeleteUser(ctx, userName); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeUserFromGroups removes the user from all groups. The user is removed from the queues of the
+// tasks, tasks of the user are assigned to the member whose turn is next and groups without members
+// are deleted with their tasks.
+func removeUserFromGroups(ctx context.Context, userName string) error {
+	groups, err := getGroups(ctx, userName)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if len(removeString(group.MemberNames, userName)) == 0 {
+			if _, err := deleteGroupWithTasks(ctx, group.ID); err != nil {
+				return err
+			}
+			if err := deleteWebhooksOfGroup(ctx, group.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := removeUserFromQueues(ctx, group, userName); err != nil {
+			return err
+		}
+	}
+	_, err = groupsCollection.UpdateMany(ctx, bson.M{memberNamesField: userName}, bson.M{
+		"$pull": bson.M{memberNamesField: userName},
+	})
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		group.MemberNames = removeString(group.MemberNames, userName)
+		if len(group.MemberNames) != 0 {
+			publishGroupEvent(api.EventMemberLeft, group, userName, userName)
+		}
+	}
+	return nil
+}
+
+// removeUserFromQueues removes the user from the queues of the tasks of the group, which still
+// contains the user. The tasks of the user are assigned to the next member in the queue.
+func removeUserFromQueues(ctx context.Context, group *ruck.Group, userName string) error {
+	tasks, err := getTasksOfGroup(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+	// the changed tasks are published to the remaining members
+	remaining := *group
+	remaining.MemberNames = removeString(group.MemberNames, userName)
+	for _, task := range tasks {
+		if task.AssigneeName != userName && !stringArrayContain(task.Queue, userName) {
+			continue
+		}
+		task.Group = group
+		assigneeName := task.AssigneeName
+		if assigneeName == userName {
+			assigneeName = task.NextInQueue(userName)
+		}
+		queue := removeString(task.RotationQueue(), userName)
+		_, err = taskCollection.UpdateOne(ctx, bson.M{"id": task.ID}, bson.M{
+			"$set": bson.M{
+				"assigneename": assigneeName,
+				"queue":        queue,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		task.AssigneeName = assigneeName
+		task.Queue = queue
+		task.Group = &remaining
+		publishTaskEvent(api.EventTaskUpdated, task, &remaining, userName)
+	}
+	return nil
+}
+
+// deleteGroupWithTasks deletes the group and returns the deleted tasks.
+func deleteGroupWithTasks(ctx context.Context, groupId string) ([]*ruck.Task, error) {
+	cursor, err := taskCollection.Find(ctx, bson.M{"groupid": groupId})
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*ruck.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+	taskIds := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		taskIds = append(taskIds, task.ID)
+	}
+	if _, err := taskExecutionCollection.DeleteMany(ctx, bson.M{"task_id": bson.M{"$in": taskIds}}); err != nil {
+		return nil, err
+	}
+	if _, err := taskHistoryCollection.DeleteMany(ctx, bson.M{"groupid": groupId}); err != nil {
+		return nil, err
+	}
+	if _, err := taskCollection.DeleteMany(ctx, bson.M{"groupid": groupId}); err != nil {
+		return nil, err
+	}
+	if _, err = groupsCollection.DeleteOne(ctx, bson.M{"id": groupId}); err != nil {
+		return nil, err
+	}
+	log.Printf("Deleted group %s with %d tasks\n", groupId, len(tasks))
+	return tasks, nil
+}
+
+// anonymizeExecutions replaces the name of the user in the task history.
+func anonymizeExecutions(ctx context.Context, userName string) error {
+	_, err := taskExecutionCollection.UpdateMany(ctx, bson.M{"executor_id": userName}, bson.M{
+		"$set": bson.M{"executor_id": ruck.DeletedUserName},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = taskCollection.UpdateMany(ctx, bson.M{"lastexecution.executor_id": userName}, bson.M{
+		"$set": bson.M{"lastexecution.executor_id": ruck.DeletedUserName},
+	})
+	if err != nil {
+		return err
+	}
+	for _, field := range []string{"actor", "assigneename", "previousassigneename"} {
+		_, err = taskHistoryCollection.UpdateMany(ctx, bson.M{field: userName}, bson.M{
+			"$set": bson.M{field: ruck.DeletedUserName},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"github.com/coffeemakr/ruck"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+)
+
+// The functions in this file are used by the administration commands of ruckd.
+// They don't check any permissions.
+
+// ListUsers returns all users sorted by name.
+func ListUsers(ctx context.Context) ([]*ruck.User, error) {
+	var users []*ruck.User
+	cursor, err := usersCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{userFieldName: 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var user ruck.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, err
+		}
+		user.PasswordHash = nil
+		users = append(users, &user)
+	}
+	return users, cursor.Err()
+}
+
+// CreateUser creates a new user. The email address is marked as verified if emailVerified is set.
+func CreateUser(ctx context.Context, registration *ruck.RegistrationRequest, emailVerified bool) (*ruck.User, error) {
+	user, err := registerUser(ctx, registration)
+	if err != nil {
+		return nil, err
+	}
+	if emailVerified {
+		if err := setEmailVerified(ctx, user.Name, user.EmailAddress); err != nil {
+			return nil, err
+		}
+		user.EmailVerified = true
+	}
+	return user, nil
+}
+
+// SetUserDisabled disables or enables a user. Disabled users can't log in and their tokens are rejected.
+func SetUserDisabled(ctx context.Context, userName string, disabled bool) error {
+	result, err := usersCollection.UpdateOne(ctx, bson.M{userFieldName: userName}, bson.M{
+		"$set": bson.M{"isdisabled": disabled},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNoSucUser
+	}
+	log.Printf("Set disabled state of user %s to %t\n", userName, disabled)
+	return nil
+}
+
+// DeleteUser deletes the user, removes it from all groups and anonymizes its task history. The user
+// is deleted last, so that the deletion can be repeated if the cleanup fails.
+func DeleteUser(ctx context.Context, userName string) error {
+	if _, err := getUserForName(ctx, userName); err != nil {
+		return err
+	}
+	if err := removeUserFromGroups(ctx, userName); err != nil {
+		return err
+	}
+	if err := anonymizeExecutions(ctx, userName); err != nil {
+		return err
+	}
+	err := deleteRefreshTokensOfUser(ctx, userName)
+	if err != nil {
+		return err
+	}
+	for _, collection := range []*mongo.Collection{
+		passwordResetCollection,
+		accessTokenCollection,
+		twoFactorCollection,
+		ssoIdentityCollection,
+		ssoCodeCollection,
+		loginFailureCollection,
+		notificationSettingsCollection,
+		digestCollection,
+		pushSettingsCollection,
+		calendarFeedCollection,
+	} {
+		_, err = collection.DeleteMany(ctx, bson.M{"username": userName})
+		if err != nil {
+			return err
+		}
+	}
+	// webhooks of groups without members were deleted with the groups
+	_, err = webhookCollection.DeleteMany(ctx, bson.M{"createdby": userName})
+	if err != nil {
+		return err
+	}
+	result, err := usersCollection.DeleteOne(ctx, bson.M{userFieldName: userName})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNoSucUser
+	}
+	log.Printf("Deleted user %s\n", userName)
+	return nil
+}
+
+// SetPassword sets the password of the user without knowing the current one.
+func SetPassword(ctx context.Context, userName string, password []byte) error {
+	return setPassword(ctx, userName, password)
+}
+
+// DisableTwoFactorForUser removes the two-factor authentication of the user, e.g. if the user lost the
+// device and the recovery codes.
+func DisableTwoFactorForUser(ctx context.Context, userName string) error {
+	result, err := twoFactorCollection.DeleteOne(ctx, bson.M{"username": userName})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNoSucUser
+	}
+	log.Printf("Disabled two-factor authentication of user %s\n", userName)
+	return nil
+}
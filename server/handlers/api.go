@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"net/http"
+)
+
+// ContextLegacyAPI is set for requests to the deprecated routes without version prefix.
+const ContextLegacyAPI ContextKey = "legacyAPI"
+
+// DeprecatedMiddleWare marks requests to the routes without version prefix. They use the models of
+// the ruck package as request and response bodies and point to their successor.
+func DeprecatedMiddleWare(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Add("Link", "<"+api.Prefix+r.URL.Path+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ContextLegacyAPI, true)))
+	})
+}
+
+func isLegacyRequest(r *http.Request) bool {
+	legacy, _ := r.Context().Value(ContextLegacyAPI).(bool)
+	return legacy
+}
+
+// decodeRequest reads the body into a model of the ruck package. Requests to the versioned API are
+// decoded into the type of the api package first.
+func decodeRequest(r *http.Request, target interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	if isLegacyRequest(r) {
+		return decoder.Decode(target)
+	}
+	switch target := target.(type) {
+	case *ruck.Credentials:
+		var request api.LoginRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		*target = *request.Model()
+	case *ruck.RegistrationRequest:
+		var request api.RegistrationRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		*target = *request.Model()
+	case *ruck.PasswordChangeRequest:
+		var request api.PasswordChangeRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		*target = *request.Model()
+	case *ruck.PasswordForgottenRequest:
+		var request api.PasswordForgottenRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		target.Name = request.Name
+	case *ruck.PasswordResetRequest:
+		var request api.PasswordResetRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		*target = *request.Model()
+	case *ruck.AccountUpdateRequest:
+		var request api.AccountUpdateRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		*target = *request.Model()
+	case *ruck.AccountDeletionRequest:
+		var request api.AccountDeletionRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		target.Password = []byte(request.Password)
+	case *ruck.Group:
+		var request api.GroupRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		*target = ruck.Group{Name: request.Name}
+	case *ruck.TaskRequest:
+		var request api.TaskRequest
+		if err := decoder.Decode(&request); err != nil {
+			return err
+		}
+		*target = *request.Model()
+	default:
+		// the other request types are part of the API as they are
+		return decoder.Decode(target)
+	}
+	return nil
+}
+
+// toAPIType converts models of the ruck package to the types of the api package.
+func toAPIType(value interface{}) interface{} {
+	switch value := value.(type) {
+	case *ruck.User:
+		return api.NewUser(value)
+	case *ruck.AuthenticationResult:
+		return api.NewAuthenticationResult(value)
+	case *ruck.AccountExport:
+		return api.NewAccountExport(value)
+	case *ruck.Group:
+		return api.NewGroup(value)
+	case []*ruck.Group:
+		return api.NewGroups(value)
+	case *ruck.Task:
+		return api.NewTask(value)
+	case []*ruck.Task:
+		return api.NewTasks(value)
+	case *ruck.TaskExecution:
+		return api.NewTaskExecution(value)
+	default:
+		return value
+	}
+}
+
+// writeResponse writes a model of the ruck package in the format of the requested API version.
+func writeResponse(w http.ResponseWriter, r *http.Request, value interface{}) {
+	writeResponseWithStatus(w, r, http.StatusOK, value)
+}
+
+func writeResponseWithStatus(w http.ResponseWriter, r *http.Request, statusCode int, value interface{}) {
+	if !isLegacyRequest(r) {
+		value = toAPIType(value)
+	}
+	mustWriteJsonWithStatus(w, statusCode, value)
+}
+
+// GetServerInfo returns the version and the enabled features of the server.
+func GetServerInfo(w http.ResponseWriter, r *http.Request) {
+	capabilities := []string{api.CapabilityTwoFactor, api.CapabilityPersonalAccessTokens}
+	if UsedIdentityProvider != nil {
+		capabilities = append(capabilities, api.CapabilitySSO)
+	}
+	switch UsedRegistrationMode {
+	case RegistrationOpen:
+		capabilities = append(capabilities, api.CapabilityRegistration)
+	case RegistrationInviteOnly:
+		capabilities = append(capabilities, api.CapabilityRegistration, api.CapabilityInviteOnly)
+	}
+	if UsedMailer != nil {
+		capabilities = append(capabilities, api.CapabilityEmailVerification)
+	}
+	mustWriteJson(w, &api.ServerInfo{
+		Version:      ruck.Version,
+		APIVersions:  []string{api.Version},
+		Capabilities: capabilities,
+	})
+}
@@ -3,16 +3,20 @@ package handlers
 import (
 	"context"
 	"errors"
-	http_error "github.com/coffeemakr/go-http-error"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"github.com/gorilla/mux"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
 	bearerTokenPrefix                  = "Bearer "
 	httpHeaderAuthorization            = "Authorization"
 	ContextUserName         ContextKey = "userID"
+	ContextToken            ContextKey = "token"
 )
 
 type ContextKey string
@@ -29,32 +33,136 @@ func (a Authenticator) MiddleWare(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get(httpHeaderAuthorization)
 		if authHeader == "" {
-			http_error.ErrUnauthorized.
+			HttpErrUnauthorized.
 				CauseString("missing 'Authorization' header").
 				Write(w, r)
 			return
 		}
 		if !strings.HasPrefix(authHeader, bearerTokenPrefix) {
-			http_error.ErrUnauthorized.
+			HttpErrUnauthorized.
 				Causef("missing '%s' prefix in auth header: %s", bearerTokenPrefix, authHeader).
 				Write(w, r)
 			return
 		}
 		// remove prefix
 		authHeader = authHeader[len(bearerTokenPrefix):]
-		decodedToken, err := a.Verifier.VerifyToken(authHeader)
+		var decodedToken *ruck.DecodedToken
+		var err error
+		if strings.HasPrefix(authHeader, personalAccessTokenPrefix) {
+			decodedToken, err = verifyPersonalAccessToken(r.Context(), authHeader)
+		} else {
+			decodedToken, err = a.Verifier.VerifyToken(authHeader)
+		}
 		if err != nil {
-			http_error.ErrBadRequest.Cause(err).Write(w, r)
+			if errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenInvalid) {
+				writeTokenError(w, r, err)
+			} else {
+				HttpErrInternal.Cause(err).Write(w, r)
+			}
 			return
 		}
 		log.Printf("Got user ID: '%s'\n", decodedToken.UserName)
+		if err := checkTokenStillValid(r.Context(), decodedToken); err != nil {
+			if err == ErrNoSucUser || err == ErrTokenRevoked || err == ErrUserDisabled {
+				writeTokenError(w, r, err)
+			} else {
+				HttpErrInternal.Cause(err).Write(w, r)
+			}
+			return
+		}
+		if !scopeAllows(decodedToken.Scope, r) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+			HttpErrInsufficientScope.Causef("scope %s of %s", decodedToken.Scope, decodedToken.UserName).Write(w, r)
+			return
+		}
 		ctx := context.WithValue(r.Context(), ContextUserName, decodedToken.UserName)
+		ctx = context.WithValue(ctx, ContextToken, decodedToken)
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
 	})
 }
 
-var ErrNoUserName = errors.New("no username in request")
+var (
+	ErrNoUserName       = errors.New("no username in request")
+	ErrTokenRevoked     = errors.New("token was revoked")
+	HttpErrInvalidToken = NewErrorType(http.StatusUnauthorized, ruck.ErrorCodeInvalidToken, "Invalid token")
+	HttpErrTokenExpired = NewErrorType(http.StatusUnauthorized, ruck.ErrorCodeTokenExpired, "Token expired")
+	// HttpErrInsufficientScope is returned if the scope of a token doesn't allow the request
+	HttpErrInsufficientScope = NewErrorType(http.StatusForbidden, ruck.ErrorCodeInsufficientScope, "Insufficient token scope")
+)
+
+// writeTokenError writes a 401 response with a WWW-Authenticate header as described in RFC 6750.
+func writeTokenError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrTokenExpired) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="The token expired"`)
+		HttpErrTokenExpired.Cause(err).Write(w, r)
+	} else {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		HttpErrInvalidToken.Cause(err).Write(w, r)
+	}
+}
+
+// checkTokenStillValid checks if the user of a token still exists, is enabled and the token wasn't revoked.
+func checkTokenStillValid(ctx context.Context, token *ruck.DecodedToken) error {
+	user, err := getUserForName(ctx, token.UserName)
+	if err != nil {
+		return err
+	}
+	if user.IsDisabled {
+		return ErrUserDisabled
+	}
+	if token.Scope != ruck.ScopeSession {
+		// personal access tokens stay valid until they are revoked
+		return nil
+	}
+	// issued at has only a precision of seconds
+	if token.IssuedAt.Before(user.PasswordChangedAt.Truncate(time.Second)) {
+		return ErrTokenRevoked
+	}
+	revoked, err := isAccessTokenRevoked(ctx, token.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// scopeAllows checks if a token with the scope may be used for the request.
+// Only session tokens can be used to manage the account.
+func scopeAllows(scope ruck.TokenScope, r *http.Request) bool {
+	var pathTemplate string
+	if route := mux.CurrentRoute(r); route != nil {
+		pathTemplate, _ = route.GetPathTemplate()
+	}
+	pathTemplate = strings.TrimPrefix(pathTemplate, api.Prefix)
+	if scope == ruck.ScopeSession {
+		return true
+	}
+	if strings.HasPrefix(pathTemplate, "/account") || pathTemplate == "/logout" {
+		return false
+	}
+	switch scope {
+	case ruck.ScopeFull:
+		return true
+	case ruck.ScopeComplete:
+		return r.Method == http.MethodGet || pathTemplate == "/tasks/{taskId}/complete"
+	case ruck.ScopeRead:
+		return r.Method == http.MethodGet
+	default:
+		return false
+	}
+}
+
+// GetTokenFromRequest returns the verified access token of an authenticated request.
+func GetTokenFromRequest(r *http.Request) (*ruck.DecodedToken, error) {
+	token, ok := r.Context().Value(ContextToken).(*ruck.DecodedToken)
+	if !ok || token == nil {
+		return nil, ErrNoUserName
+	}
+	return token, nil
+}
 
 func GetUserNameFromRequest(r *http.Request) (string, error) {
 	name, ok := r.Context().Value(ContextUserName).(string)
@@ -1,52 +1,189 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"github.com/coffeemakr/ruck"
 	"github.com/square/go-jose/v3"
 	"github.com/square/go-jose/v3/jwt"
+	"net/http"
 	"time"
 )
 
-var UsedTokenIssuer *JwtTokenIssuer
+const (
+	DefaultAccessTokenLifetime = 15 * time.Minute
+	DefaultTokenIssuer         = "ruckd"
+	DefaultTokenAudience       = "ruck"
+	DefaultTokenLeeway         = 30 * time.Second
+	emailVerificationAudience  = "ruck-email-verification"
+	emailVerificationValidity  = 48 * time.Hour
+	twoFactorAudience          = "ruck-two-factor"
+	twoFactorValidity          = 5 * time.Minute
+	unsubscribeAudience        = "ruck-unsubscribe"
+	unsubscribeValidity        = 90 * 24 * time.Hour
+)
+
+var (
+	UsedTokenIssuer   *JwtTokenIssuer
+	UsedTokenVerifier *JwtTokenVerifier
+
+	ErrTokenExpired = errors.New("token is expired")
+	ErrTokenInvalid = errors.New("token is invalid")
+)
 
 type JwtTokenIssuer struct {
-	PrivateKey *jose.JSONWebKey
+	PrivateKey          *jose.JSONWebKey
+	AccessTokenLifetime time.Duration
+	// Issuer and Audience are set in the access tokens and default to DefaultTokenIssuer
+	// and DefaultTokenAudience.
+	Issuer   string
+	Audience string
 }
 
 type JwtTokenVerifier struct {
-	KeySet *jose.JSONWebKeySet
+	KeySet   *jose.JSONWebKeySet
+	Issuer   string
+	Audience string
+	// Leeway is the allowed clock skew for the time based claims.
+	Leeway time.Duration
 }
 
-func (v *JwtTokenVerifier) VerifyToken(rawToken string) (*ruck.DecodedToken, error) {
-	var claims jwt.Claims
-	var result ruck.DecodedToken
+// emailClaims are the private claims of email verification tokens.
+type emailClaims struct {
+	Email string `json:"email"`
+}
+
+// unsubscribeClaims are the private claims of the tokens in unsubscribe links.
+type unsubscribeClaims struct {
+	Notification string `json:"notification"`
+}
+
+func defaultString(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func (v *JwtTokenVerifier) leeway() time.Duration {
+	if v.Leeway == 0 {
+		return DefaultTokenLeeway
+	}
+	return v.Leeway
+}
+
+// validateClaims checks the signature and the registered claims of the token.
+// The claims are decoded into the passed values.
+func (v *JwtTokenVerifier) validateClaims(rawToken string, audience string, claims *jwt.Claims, extra ...interface{}) error {
 	token, err := jwt.ParseSigned(rawToken)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%w: %s", ErrTokenInvalid, err)
+	}
+	err = token.Claims(v.KeySet, append([]interface{}{claims}, extra...)...)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrTokenInvalid, err)
+	}
+	err = claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:   defaultString(v.Issuer, DefaultTokenIssuer),
+		Audience: jwt.Audience{audience},
+		Time:     time.Now(),
+	}, v.leeway())
+	switch err {
+	case nil:
+		return nil
+	case jwt.ErrExpired:
+		return ErrTokenExpired
+	default:
+		return fmt.Errorf("%w: %s", ErrTokenInvalid, err)
 	}
+}
 
-	err = token.Claims(v.KeySet, &claims)
+// VerifyToken verifies an access token. The returned error wraps either ErrTokenExpired
+// or ErrTokenInvalid.
+func (v *JwtTokenVerifier) VerifyToken(rawToken string) (*ruck.DecodedToken, error) {
+	var claims jwt.Claims
+	var result ruck.DecodedToken
+	err := v.validateClaims(rawToken, defaultString(v.Audience, DefaultTokenAudience), &claims)
 	if err != nil {
 		return nil, err
 	}
+	result.ID = claims.ID
 	result.UserName = claims.Subject
+	result.Scope = ruck.ScopeSession
+	if claims.IssuedAt != nil {
+		result.IssuedAt = claims.IssuedAt.Time()
+	}
+	if claims.Expiry != nil {
+		result.Expiry = claims.Expiry.Time()
+	}
 	return &result, nil
 }
 
+// VerifyEmailVerificationToken checks a token issued by IssueEmailVerificationToken and returns
+// the user name and the email address it was issued for.
+func (v *JwtTokenVerifier) VerifyEmailVerificationToken(rawToken string) (userName string, email string, err error) {
+	var claims jwt.Claims
+	var extraClaims emailClaims
+	err = v.validateClaims(rawToken, emailVerificationAudience, &claims, &extraClaims)
+	if err != nil {
+		return
+	}
+	return claims.Subject, extraClaims.Email, nil
+}
+
+// VerifyTwoFactorToken checks a token issued by IssueTwoFactorToken and returns the user name.
+func (v *JwtTokenVerifier) VerifyTwoFactorToken(rawToken string) (string, error) {
+	var claims jwt.Claims
+	err := v.validateClaims(rawToken, twoFactorAudience, &claims)
+	if err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+// VerifyUnsubscribeToken checks a token issued by IssueUnsubscribeToken and returns the user name
+// and the notification to disable.
+func (v *JwtTokenVerifier) VerifyUnsubscribeToken(rawToken string) (userName string, notification string, err error) {
+	var claims jwt.Claims
+	var extraClaims unsubscribeClaims
+	err = v.validateClaims(rawToken, unsubscribeAudience, &claims, &extraClaims)
+	if err != nil {
+		return
+	}
+	return claims.Subject, extraClaims.Notification, nil
+}
+
+func (i JwtTokenIssuer) signer() (jose.Signer, error) {
+	algorithm := jose.SignatureAlgorithm(i.PrivateKey.Algorithm)
+	if algorithm == "" {
+		algorithm = jose.RS256
+	}
+	return jose.NewSigner(jose.SigningKey{Algorithm: algorithm, Key: i.PrivateKey}, nil)
+}
+
+// IssueToken issues a short-lived access token for the user name of the decoded token.
+// The ID, IssuedAt and Expiry fields of the decoded token are set to the values of the issued token.
 func (i JwtTokenIssuer) IssueToken(decodedToken *ruck.DecodedToken) (string, error) {
-	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: i.PrivateKey}, nil)
+	signer, err := i.signer()
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
-	// TODO: set expiry lower && refresh
-	issuedAt := time.Now()
-	expiry := issuedAt.AddDate(0, 1, 0)
+	lifetime := i.AccessTokenLifetime
+	if lifetime == 0 {
+		lifetime = DefaultAccessTokenLifetime
+	}
+	decodedToken.ID = RandStringRunes(16)
+	decodedToken.IssuedAt = time.Now()
+	decodedToken.Expiry = decodedToken.IssuedAt.Add(lifetime)
 	claims := jwt.Claims{
-		ID:       RandStringRunes(16),
-		Subject:  decodedToken.UserName,
-		IssuedAt: jwt.NewNumericDate(time.Now()),
-		Expiry:   jwt.NewNumericDate(expiry),
+		ID:        decodedToken.ID,
+		Issuer:    defaultString(i.Issuer, DefaultTokenIssuer),
+		Subject:   decodedToken.UserName,
+		Audience:  jwt.Audience{defaultString(i.Audience, DefaultTokenAudience)},
+		IssuedAt:  jwt.NewNumericDate(decodedToken.IssuedAt),
+		NotBefore: jwt.NewNumericDate(decodedToken.IssuedAt),
+		Expiry:    jwt.NewNumericDate(decodedToken.Expiry),
 	}
 
 	raw, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
@@ -55,3 +192,69 @@ func (i JwtTokenIssuer) IssueToken(decodedToken *ruck.DecodedToken) (string, err
 	}
 	return raw, err
 }
+
+// IssueEmailVerificationToken creates a signed token which proofs that the user has access to
+// the given email address.
+func (i JwtTokenIssuer) IssueEmailVerificationToken(user *ruck.User) (string, error) {
+	signer, err := i.signer()
+	if err != nil {
+		return "", err
+	}
+	issuedAt := time.Now()
+	claims := jwt.Claims{
+		ID:        RandStringRunes(16),
+		Issuer:    defaultString(i.Issuer, DefaultTokenIssuer),
+		Subject:   user.Name,
+		Audience:  jwt.Audience{emailVerificationAudience},
+		IssuedAt:  jwt.NewNumericDate(issuedAt),
+		NotBefore: jwt.NewNumericDate(issuedAt),
+		Expiry:    jwt.NewNumericDate(issuedAt.Add(emailVerificationValidity)),
+	}
+	return jwt.Signed(signer).Claims(claims).Claims(&emailClaims{Email: user.EmailAddress}).CompactSerialize()
+}
+
+// IssueTwoFactorToken creates a short-lived token which proofs that the user entered the correct
+// password and only the one-time code is missing.
+func (i JwtTokenIssuer) IssueTwoFactorToken(userName string) (string, error) {
+	signer, err := i.signer()
+	if err != nil {
+		return "", err
+	}
+	issuedAt := time.Now()
+	claims := jwt.Claims{
+		ID:        RandStringRunes(16),
+		Issuer:    defaultString(i.Issuer, DefaultTokenIssuer),
+		Subject:   userName,
+		Audience:  jwt.Audience{twoFactorAudience},
+		IssuedAt:  jwt.NewNumericDate(issuedAt),
+		NotBefore: jwt.NewNumericDate(issuedAt),
+		Expiry:    jwt.NewNumericDate(issuedAt.Add(twoFactorValidity)),
+	}
+	return jwt.Signed(signer).Claims(claims).CompactSerialize()
+}
+
+// IssueUnsubscribeToken creates a token for the unsubscribe link in notification mails. It allows
+// to disable the notification without logging in.
+func (i JwtTokenIssuer) IssueUnsubscribeToken(userName string, notification string) (string, error) {
+	signer, err := i.signer()
+	if err != nil {
+		return "", err
+	}
+	issuedAt := time.Now()
+	claims := jwt.Claims{
+		ID:        RandStringRunes(16),
+		Issuer:    defaultString(i.Issuer, DefaultTokenIssuer),
+		Subject:   userName,
+		Audience:  jwt.Audience{unsubscribeAudience},
+		IssuedAt:  jwt.NewNumericDate(issuedAt),
+		NotBefore: jwt.NewNumericDate(issuedAt),
+		Expiry:    jwt.NewNumericDate(issuedAt.Add(unsubscribeValidity)),
+	}
+	return jwt.Signed(signer).Claims(claims).Claims(&unsubscribeClaims{Notification: notification}).CompactSerialize()
+}
+
+// GetJSONWebKeySet publishes the public keys used to verify tokens.
+func GetJSONWebKeySet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	mustWriteJson(w, UsedTokenVerifier.KeySet)
+}
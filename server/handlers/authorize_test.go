@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/square/go-jose/v3/jwt"
+)
+
+// signClaims mints a token with the key of the issuer and arbitrary claims.
+func signClaims(t *testing.T, issuer *JwtTokenIssuer, claims jwt.Claims) string {
+	t.Helper()
+	signer, err := issuer.signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// accessClaims returns the claims of an access token which is valid from notBefore until expiry.
+func accessClaims(notBefore, expiry time.Time) jwt.Claims {
+	return jwt.Claims{
+		ID:        "test",
+		Issuer:    DefaultTokenIssuer,
+		Subject:   "alice",
+		Audience:  jwt.Audience{DefaultTokenAudience},
+		IssuedAt:  jwt.NewNumericDate(notBefore),
+		NotBefore: jwt.NewNumericDate(notBefore),
+		Expiry:    jwt.NewNumericDate(expiry),
+	}
+}
+
+func issueTestToken(t *testing.T, issuer *JwtTokenIssuer) string {
+	t.Helper()
+	raw, err := issuer.IssueToken(&ruck.DecodedToken{UserName: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestAuthenticatorRejectsTokens(t *testing.T) {
+	issuer, verifier := setUpTestTokens(t)
+	now := time.Now()
+	otherIssuer := &JwtTokenIssuer{PrivateKey: issuer.PrivateKey, Issuer: "someone-else"}
+	otherAudience := &JwtTokenIssuer{PrivateKey: issuer.PrivateKey, Audience: "another-service"}
+	otherKey := &JwtTokenIssuer{PrivateKey: newTestKey(t, "test")}
+	expired := &JwtTokenIssuer{PrivateKey: issuer.PrivateKey, AccessTokenLifetime: -time.Hour}
+	emailToken, err := issuer.IssueEmailVerificationToken(&ruck.User{Name: "alice", EmailAddress: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := issueTestToken(t, issuer)
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		code  string
+	}{
+		{"expired", issueTestToken(t, expired), ruck.ErrorCodeTokenExpired},
+		{"expired beyond leeway", signClaims(t, issuer, accessClaims(now.Add(-time.Hour), now.Add(-time.Minute))), ruck.ErrorCodeTokenExpired},
+		{"not yet valid", signClaims(t, issuer, accessClaims(now.Add(time.Minute), now.Add(time.Hour))), ruck.ErrorCodeInvalidToken},
+		{"wrong issuer", issueTestToken(t, otherIssuer), ruck.ErrorCodeInvalidToken},
+		{"wrong audience", issueTestToken(t, otherAudience), ruck.ErrorCodeInvalidToken},
+		{"email verification token", emailToken, ruck.ErrorCodeInvalidToken},
+		{"bad signature", issueTestToken(t, otherKey), ruck.ErrorCodeInvalidToken},
+		{"tampered payload", parts[0] + "." + parts[1] + "x." + parts[2], ruck.ErrorCodeInvalidToken},
+		{"malformed", "not-a-token", ruck.ErrorCodeInvalidToken},
+		{"empty", "", ruck.ErrorCodeInvalidToken},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := Authenticator{Verifier: verifier}.MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("the request must not reach the handler")
+			}))
+			request := httptest.NewRequest(http.MethodGet, "/api/v1/groups", nil)
+			request.Header.Set(httpHeaderAuthorization, bearerTokenPrefix+test.token)
+			recorder := httptest.NewRecorder()
+
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status 401, got %d: %s", recorder.Code, recorder.Body)
+			}
+			if !strings.HasPrefix(recorder.Header().Get("WWW-Authenticate"), `Bearer error="invalid_token"`) {
+				t.Errorf("unexpected WWW-Authenticate header %q", recorder.Header().Get("WWW-Authenticate"))
+			}
+			var response ruck.ErrorResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Code != test.code {
+				t.Errorf("expected code %s, got %s", test.code, response.Code)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenAllowsClockSkew(t *testing.T) {
+	issuer, verifier := setUpTestTokens(t)
+	now := time.Now()
+	tests := []struct {
+		name   string
+		leeway time.Duration
+		claims jwt.Claims
+		err    error
+	}{
+		{"valid", 0, accessClaims(now, now.Add(time.Minute)), nil},
+		{"expired within default leeway", 0, accessClaims(now.Add(-time.Hour), now.Add(-10*time.Second)), nil},
+		{"not yet valid within default leeway", 0, accessClaims(now.Add(10*time.Second), now.Add(time.Hour)), nil},
+		{"expired within configured leeway", 2 * time.Minute, accessClaims(now.Add(-time.Hour), now.Add(-time.Minute)), nil},
+		{"expired beyond configured leeway", time.Second, accessClaims(now.Add(-time.Hour), now.Add(-10*time.Second)), ErrTokenExpired},
+		{"not yet valid beyond configured leeway", time.Second, accessClaims(now.Add(10*time.Second), now.Add(time.Hour)), ErrTokenInvalid},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verifier.Leeway = test.leeway
+			decoded, err := verifier.VerifyToken(signClaims(t, issuer, test.claims))
+			if test.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decoded.UserName != "alice" || decoded.Scope != ruck.ScopeSession {
+					t.Errorf("unexpected token %+v", decoded)
+				}
+			} else if err == nil || !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"github.com/coffeemakr/ruck/server/ical"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// calendarHorizon is the time after which no occurrences are included in the feed.
+	calendarHorizon = 180 * 24 * time.Hour
+	// maxCalendarOccurrences limits the occurrences of each task, e.g. of daily tasks.
+	maxCalendarOccurrences   = 100
+	calendarRefreshInterval  = time.Hour
+	calendarFeedPathTemplate = "/calendar/%s.ics"
+)
+
+var (
+	HttpErrCalendarFeedNotFound = NewErrorType(http.StatusNotFound, ruck.ErrorCodeCalendarFeedNotFound, "Calendar feed not found")
+	ErrNoSuchCalendarFeed       = errors.New("no such calendar feed")
+)
+
+// calendarFeedModel is the stored form of the feed of a user. The token is stored in plain text, so
+// that the URL can be shown again. It only grants read access to the feed.
+type calendarFeedModel struct {
+	UserName  string    `bson:"username"`
+	Token     string    `bson:"token"`
+	CreatedAt time.Time `bson:"createdat"`
+}
+
+func (m *calendarFeedModel) toCalendarFeed() *api.CalendarFeed {
+	path := fmt.Sprintf(calendarFeedPathTemplate, m.Token)
+	return &api.CalendarFeed{
+		URL:       buildPublicURL(path, nil),
+		MineURL:   buildPublicURL(path, url.Values{"mine": {"true"}}),
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+func getCalendarFeed(ctx context.Context, filter bson.M) (*calendarFeedModel, error) {
+	var model calendarFeedModel
+	err := calendarFeedCollection.FindOne(ctx, filter).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNoSuchCalendarFeed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// rotateCalendarFeed replaces the token of the feed of the user, which invalidates the previous URLs.
+func rotateCalendarFeed(ctx context.Context, userName string) (*calendarFeedModel, error) {
+	token, err := generateSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	model := &calendarFeedModel{
+		UserName:  userName,
+		Token:     token,
+		CreatedAt: time.Now(),
+	}
+	_, err = calendarFeedCollection.ReplaceOne(ctx, bson.M{"username": userName}, model, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func deleteCalendarFeed(ctx context.Context, userName string) error {
+	result, err := calendarFeedCollection.DeleteOne(ctx, bson.M{"username": userName})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNoSuchCalendarFeed
+	}
+	return nil
+}
+
+// taskOccurrence is a due date of a task and the member who is expected to do it.
+type taskOccurrence struct {
+	DueDate      time.Time
+	AssigneeName string
+}
+
+// taskOccurrences returns the due dates of the task until the deadline starting with the current one.
+// The assignees of later occurrences assume that every assignee completes the task.
+func taskOccurrences(task *ruck.Task, until time.Time) []taskOccurrence {
+	occurrences := []taskOccurrence{{DueDate: task.DueDate, AssigneeName: task.AssigneeName}}
+	if !task.Interval.IsValid() || task.Group == nil || len(task.Group.MemberNames) == 0 {
+		return occurrences
+	}
+	// the rotation is simulated on a copy
+	expected := *task
+	for len(occurrences) < maxCalendarOccurrences {
+		expected.DueDate = task.Interval.Next(expected.DueDate)
+		if expected.DueDate.After(until) {
+			break
+		}
+		// nobody knows who completes an open occurrence, the member whose turn it is is the best guess
+		executorName := expected.AssigneeName
+		if executorName == "" {
+			executorName = expected.RotationQueue()[0]
+		}
+		expected.Rotate(executorName)
+		occurrences = append(occurrences, taskOccurrence{DueDate: expected.DueDate, AssigneeName: expected.AssigneeName})
+	}
+	return occurrences
+}
+
+// newTaskCalendar returns the occurrences of the tasks as events. If mine is set only the
+// occurrences assigned to the user are included.
+func newTaskCalendar(userName string, tasks []*ruck.Task, mine bool, now time.Time) *ical.Calendar {
+	calendar := &ical.Calendar{
+		ProdID:          "-//ruck//ruck " + ruck.Version + "//EN",
+		Name:            "ruck",
+		RefreshInterval: calendarRefreshInterval,
+	}
+	if mine {
+		calendar.Name = "ruck: " + userName
+	}
+	until := now.Add(calendarHorizon)
+	for _, task := range tasks {
+		groupName := ""
+		if task.Group != nil {
+			groupName = task.Group.Name
+		}
+		// the units of the API are spelled correctly
+		interval := api.NewInterval(task.Interval)
+		for i, occurrence := range taskOccurrences(task, until) {
+			if mine && occurrence.AssigneeName != userName {
+				continue
+			}
+			assignee := occurrence.AssigneeName
+			if assignee == "" {
+				assignee = "anyone"
+			}
+			summary := fmt.Sprintf("%s (%s)", task.Name, assignee)
+			if i > 0 {
+				assignee += " (expected)"
+			}
+			calendar.Events = append(calendar.Events, &ical.Event{
+				UID:     fmt.Sprintf("%s-%d@ruck", task.ID, occurrence.DueDate.Unix()),
+				Start:   occurrence.DueDate,
+				Summary: summary,
+				Description: fmt.Sprintf("Group: %s\nAssigned to: %s\nRepeats every %d %s", groupName, assignee,
+					interval.Amount, interval.Unit),
+				Categories: []string{groupName},
+			})
+		}
+	}
+	sort.SliceStable(calendar.Events, func(i, j int) bool {
+		return calendar.Events[i].Start.Before(calendar.Events[j].Start)
+	})
+	return calendar
+}
+
+func GetCalendarFeedSettings(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	feed, err := getCalendarFeed(r.Context(), bson.M{"username": userName})
+	switch err {
+	case ErrNoSuchCalendarFeed:
+		HttpErrCalendarFeedNotFound.Cause(err).Write(w, r)
+	case nil:
+		writeResponse(w, r, feed.toCalendarFeed())
+	default:
+		HttpErrInternal.Cause(err).Write(w, r)
+	}
+}
+
+// RotateCalendarFeed creates the feed of the user or replaces its URLs.
+func RotateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	feed, err := rotateCalendarFeed(r.Context(), userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponseWithStatus(w, r, http.StatusCreated, feed.toCalendarFeed())
+}
+
+func DeleteCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	err = deleteCalendarFeed(r.Context(), userName)
+	switch err {
+	case ErrNoSuchCalendarFeed:
+		HttpErrCalendarFeedNotFound.Cause(err).Write(w, r)
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		HttpErrInternal.Cause(err).Write(w, r)
+	}
+}
+
+// GetCalendar renders the tasks of the groups of the owner of the token as iCalendar. The query
+// parameter mine restricts it to the occurrences assigned to the owner.
+func GetCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	feed, err := getCalendarFeed(ctx, bson.M{"token": mux.Vars(r)["token"]})
+	switch err {
+	case ErrNoSuchCalendarFeed:
+		HttpErrCalendarFeedNotFound.Cause(err).Write(w, r)
+		return
+	case nil:
+	default:
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	// the feed of a disabled user must not reveal the tasks any longer
+	user, err := getUserForName(ctx, feed.UserName)
+	switch {
+	case err == ErrNoSucUser:
+		HttpErrCalendarFeedNotFound.Causef("owner %s of the feed was deleted", feed.UserName).Write(w, r)
+		return
+	case err != nil:
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	case user.IsDisabled:
+		HttpErrCalendarFeedNotFound.Causef("owner %s of the feed is disabled", feed.UserName).Write(w, r)
+		return
+	}
+	mine, _ := strconv.ParseBool(r.URL.Query().Get("mine"))
+	tasks, err := getTasksForUser(ctx, feed.UserName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	now := time.Now()
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="ruck.ics"`)
+	if err := newTaskCalendar(feed.UserName, tasks, mine, now).Encode(w, now); err != nil {
+		log.Printf("Failed to write calendar of %s: %s\n", feed.UserName, err)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+)
+
+func TestTaskOccurrences(t *testing.T) {
+	due := time.Date(2020, 5, 4, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		assignee  string
+		queue     []string
+		policy    ruck.CoverPolicy
+		assignees []string
+	}{
+		{"assigned", "b", nil, ruck.CoverKeepTurn, []string{"b", "c", "a", "b"}},
+		{"claimed", "c", []string{"a", "b", "c"}, ruck.CoverSkipTurn, []string{"c", "b", "a", "c"}},
+		// the member whose turn it is is expected to complete the open occurrence
+		{"open", "", nil, ruck.CoverSkipTurn, []string{"", "b", "c", "a"}},
+		{"open with stored queue", "", []string{"c", "a", "b"}, ruck.CoverSwapTurns, []string{"", "a", "b", "c"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := &ruck.Task{
+				Interval:     ruck.Interval{Unit: ruck.Weeks, Amount: 1},
+				Group:        &ruck.Group{MemberNames: []string{"a", "b", "c"}, CoverPolicy: test.policy},
+				AssigneeName: test.assignee,
+				Queue:        test.queue,
+				DueDate:      due,
+			}
+			occurrences := taskOccurrences(task, due.AddDate(0, 0, 21))
+			var assignees []string
+			for i, occurrence := range occurrences {
+				if expected := due.AddDate(0, 0, 7*i); !occurrence.DueDate.Equal(expected) {
+					t.Errorf("occurrence %d is due %s, expected %s", i, occurrence.DueDate, expected)
+				}
+				assignees = append(assignees, occurrence.AssigneeName)
+			}
+			if !reflect.DeepEqual(assignees, test.assignees) {
+				t.Errorf("expected assignees %q, got %q", test.assignees, assignees)
+			}
+			if task.AssigneeName != test.assignee || !reflect.DeepEqual(task.Queue, test.queue) {
+				t.Errorf("the task was changed: %s %v", task.AssigneeName, task.Queue)
+			}
+		})
+	}
+}
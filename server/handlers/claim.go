@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"go.mongodb.org/mongo-driver/bson"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	HttpErrInvalidClaimPolicy = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidClaimPolicy, "Invalid claim policy")
+	HttpErrInvalidCoverPolicy = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidCoverPolicy, "Invalid cover policy")
+	HttpErrTaskNotClaimable   = NewErrorType(http.StatusConflict, ruck.ErrorCodeTaskNotClaimable, "Task cannot be claimed")
+)
+
+// checkClaimable returns the error to send to the client if the claim policy of the group doesn't
+// allow claiming the task.
+func checkClaimable(task *ruck.Task, now time.Time) *Error {
+	if task.AssigneeName == "" {
+		return nil
+	}
+	switch task.Group.ClaimPolicy {
+	case ruck.ClaimAny:
+		return nil
+	case ruck.ClaimOverdue:
+		if !now.Before(task.DueDate) {
+			return nil
+		}
+		return HttpErrTaskNotClaimable.Causef("the task is assigned to %s and not overdue", task.AssigneeName)
+	default:
+		return HttpErrTaskNotClaimable.Causef("the task is assigned to %s", task.AssigneeName)
+	}
+}
+
+// ClaimTask assigns the task to the user if the claim policy of the group allows it. The turn in the
+// queue of the task doesn't change until the task is completed.
+func ClaimTask(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	ctx := r.Context()
+	task, err := getTaskByIdIncludingGroup(ctx, getTaskId(r))
+	switch {
+	case err == ErrNoSuchTask || err == nil && !stringArrayContain(task.Group.MemberNames, userName):
+		HttpErrTaskNotFound.Cause(ErrNoSuchTask).Write(w, r)
+		return
+	case err != nil:
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if task.AssigneeName == userName {
+		writeResponse(w, r, task)
+		return
+	}
+	if httpErr := checkClaimable(task, time.Now()); httpErr != nil {
+		httpErr.Write(w, r)
+		return
+	}
+	previousAssignee := task.AssigneeName
+	// the queue is stored, so that the cover policy of the group applies when the user completes it
+	task.Queue = task.RotationQueue()
+	result, err := taskCollection.UpdateOne(ctx, bson.M{"id": task.ID, "assigneename": previousAssignee}, bson.M{
+		"$set": bson.M{"assigneename": userName, "queue": task.Queue},
+	})
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if result.ModifiedCount == 0 {
+		HttpErrTaskNotClaimable.CauseString("the task was changed in the meantime").Write(w, r)
+		return
+	}
+	task.AssigneeName = userName
+	task.Assignee = nil
+	// the escalation must not take the overdue task away from the user again
+	if _, err := claimEscalation(ctx, task, escalationAct); err != nil {
+		log.Printf("Failed to record claim of task %s for the escalation: %s\n", task.ID, err)
+	}
+	entry := &api.TaskHistoryEntry{
+		TaskID:               task.ID,
+		GroupID:              task.Group.ID,
+		Type:                 api.HistoryClaimed,
+		Actor:                userName,
+		AssigneeName:         userName,
+		PreviousAssigneeName: previousAssignee,
+		DueDate:              task.DueDate,
+	}
+	recordTaskHistory(ctx, entry)
+	publishTaskHistoryEvent(api.EventTaskClaimed, task, entry)
+	writeResponse(w, r, task)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/mail"
+	"go.mongodb.org/mongo-driver/bson"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// UsedMailer sends mails to users. Mails are disabled if it is nil.
+	UsedMailer mail.Mailer
+	// PublicURL is the base URL used for links sent to users.
+	PublicURL string
+	// RequireVerifiedEmail blocks logins until the email address is verified.
+	RequireVerifiedEmail bool
+
+	verificationMailLimiter = &intervalLimiter{Interval: 10 * time.Minute}
+
+	HttpErrInvalidVerificationToken = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidVerificationToken, "Invalid verification token")
+	HttpErrEmailAlreadyVerified     = NewErrorType(http.StatusConflict, ruck.ErrorCodeEmailAlreadyVerified, "Email address already verified")
+	HttpErrNoEmailAddress           = NewErrorType(http.StatusConflict, ruck.ErrorCodeNoEmailAddress, "The account has no email address")
+	HttpErrEmailNotVerified         = NewErrorType(http.StatusForbidden, ruck.ErrorCodeEmailNotVerified, "Email address not verified")
+	HttpErrTooManyRequests          = NewErrorType(http.StatusTooManyRequests, ruck.ErrorCodeTooManyRequests, "Too many requests")
+	HttpErrMailNotConfigured        = NewErrorType(http.StatusServiceUnavailable, ruck.ErrorCodeMailNotConfigured, "Mail delivery is not configured")
+	ErrMailNotConfigured            = errors.New("mailer not configured")
+)
+
+const verificationMailText = `Hello %s
+
+Please confirm your email address for ruck by opening the following link:
+
+%s
+
+The link is valid for %d hours. If you did not create an account, you can ignore this mail.
+`
+
+var verifyEmailPage = template.Must(template.New("verifyEmail").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Verify email address</title></head>
+<body>
+<form method="post" action="verify-email">
+<input type="hidden" name="token" value="{{.}}">
+<button type="submit">Verify email address</button>
+</form>
+</body>
+</html>
+`))
+
+// SetVerificationResendInterval sets the minimal time between two verification mails to the same user.
+func SetVerificationResendInterval(interval time.Duration) {
+	verificationMailLimiter.Interval = interval
+}
+
+func buildPublicURL(path string, query url.Values) string {
+	link := strings.TrimSuffix(PublicURL, "/") + path
+	if len(query) != 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
+
+func sendVerificationMail(user *ruck.User) error {
+	if UsedMailer == nil {
+		return ErrMailNotConfigured
+	}
+	token, err := UsedTokenIssuer.IssueEmailVerificationToken(user)
+	if err != nil {
+		return fmt.Errorf("failed to issue verification token: %s", err)
+	}
+	link := buildPublicURL("/verify-email", url.Values{"token": {token}})
+	return UsedMailer.Send(&mail.Message{
+		To:      user.EmailAddress,
+		Subject: "Verify your email address",
+		Text:    fmt.Sprintf(verificationMailText, user.Name, link, int(emailVerificationValidity.Hours())),
+	})
+}
+
+func setEmailVerified(ctx context.Context, userName string, email string) error {
+	// the address must still be the same, otherwise the token is outdated
+	result, err := usersCollection.UpdateOne(ctx, bson.M{
+		userFieldName:  userName,
+		"emailaddress": email,
+	}, bson.M{
+		"$set": bson.M{"emailverified": true},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNoSucUser
+	}
+	return nil
+}
+
+func readVerificationToken(r *http.Request) (string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var body struct {
+			Token string `json:"token"`
+		}
+		if err := decodeRequest(r, &body); err != nil {
+			return "", err
+		}
+		return body.Token, nil
+	}
+	return r.FormValue("token"), nil
+}
+
+// ShowVerifyEmailPage renders a confirmation form for the link in the verification mail.
+// The verification itself requires a POST request so that link scanners don't verify addresses.
+func ShowVerifyEmailPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := verifyEmailPage.Execute(w, r.URL.Query().Get("token")); err != nil {
+		log.Printf("Failed to render verification page: %s", err)
+	}
+}
+
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rawToken, err := readVerificationToken(r)
+	if err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	userName, email, err := UsedTokenVerifier.VerifyEmailVerificationToken(rawToken)
+	if err != nil {
+		HttpErrInvalidVerificationToken.Cause(err).Write(w, r)
+		return
+	}
+	err = setEmailVerified(ctx, userName, email)
+	switch err {
+	case nil:
+		log.Printf("Verified email address of user %s\n", userName)
+	case ErrNoSucUser:
+		HttpErrInvalidVerificationToken.Causef("email of %s changed", userName).Write(w, r)
+		return
+	default:
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	user, err := getUserForName(ctx, userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, user)
+}
+
+// ResendVerificationEmail sends a new verification mail. The credentials are required instead
+// of a token because users may not be able to log in before they are verified.
+func ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	var credentials ruck.Credentials
+	if err := decodeRequest(r, &credentials); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	if UsedMailer == nil {
+		HttpErrMailNotConfigured.Cause(ErrMailNotConfigured).Write(w, r)
+		return
+	}
+	user, err := authenticateUser(r.Context(), &credentials)
+	if err != nil {
+		writeAuthenticationError(w, r, err)
+		return
+	}
+	if user.EmailAddress == "" {
+		HttpErrNoEmailAddress.Causef("%s requested a verification mail", user.Name).Write(w, r)
+		return
+	}
+	if user.EmailVerified {
+		HttpErrEmailAlreadyVerified.Causef("%s is already verified", user.EmailAddress).Write(w, r)
+		return
+	}
+	if !verificationMailLimiter.Allow(user.Name) {
+		HttpErrTooManyRequests.Causef("verification mail for %s sent recently", user.Name).Write(w, r)
+		return
+	}
+	if err := sendVerificationMail(user); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/mail"
+	"github.com/coffeemakr/ruck/server/mail/mailtest"
+)
+
+// setUpTestMailer sends the mails of the handlers to a fake SMTP server until the test ends.
+func setUpTestMailer(t *testing.T) *mailtest.Server {
+	t.Helper()
+	server := mailtest.NewServer()
+	previousMailer, previousURL := UsedMailer, PublicURL
+	UsedMailer = &mail.SMTPMailer{Host: server.Host, Port: server.Port, From: "ruck@example.com"}
+	PublicURL = "https://ruck.example.com/"
+	t.Cleanup(func() {
+		server.Close()
+		UsedMailer, PublicURL = previousMailer, previousURL
+	})
+	return server
+}
+
+func receiveMail(t *testing.T, server *mailtest.Server) *mailtest.Mail {
+	t.Helper()
+	received, err := server.Receive(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return received
+}
+
+func TestSendVerificationMail(t *testing.T) {
+	_, verifier := setUpTestTokens(t)
+	server := setUpTestMailer(t)
+	user := &ruck.User{Name: "alice", EmailAddress: "alice@example.com"}
+
+	if err := sendVerificationMail(user); err != nil {
+		t.Fatal(err)
+	}
+	received := receiveMail(t, server)
+	if received.From != "ruck@example.com" || len(received.To) != 1 || received.To[0] != "alice@example.com" {
+		t.Errorf("unexpected envelope from %s to %v", received.From, received.To)
+	}
+	if !strings.Contains(received.Data, "Subject: Verify your email address\n") {
+		t.Errorf("subject missing:\n%s", received.Data)
+	}
+	var link *url.URL
+	for _, field := range strings.Fields(received.Data) {
+		if strings.HasPrefix(field, "https://ruck.example.com/verify-email?") {
+			parsed, err := url.Parse(field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			link = parsed
+		}
+	}
+	if link == nil {
+		t.Fatalf("link missing:\n%s", received.Data)
+	}
+	userName, email, err := verifier.VerifyEmailVerificationToken(link.Query().Get("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userName != "alice" || email != "alice@example.com" {
+		t.Errorf("token for %s <%s>", userName, email)
+	}
+}
+
+func TestSendVerificationMailWithoutAddress(t *testing.T) {
+	setUpTestTokens(t)
+	setUpTestMailer(t)
+	if err := sendVerificationMail(&ruck.User{Name: "bob"}); err != mail.ErrNoRecipient {
+		t.Errorf("expected ErrNoRecipient, got %v", err)
+	}
+}
+
+func TestPasswordResetMail(t *testing.T) {
+	server := setUpTestMailer(t)
+	user := &ruck.User{Name: "alice", EmailAddress: "alice@example.com"}
+
+	if err := UsedMailer.Send(newPasswordResetMail(user, "secret-token")); err != nil {
+		t.Fatal(err)
+	}
+	received := receiveMail(t, server)
+	if len(received.To) != 1 || received.To[0] != "alice@example.com" {
+		t.Errorf("unexpected recipients %v", received.To)
+	}
+	for _, expected := range []string{
+		"Subject: Reset your password\n",
+		"Hello alice",
+		"ruck account reset-password secret-token",
+		"valid for 60 minutes",
+	} {
+		if !strings.Contains(received.Data, expected) {
+			t.Errorf("%q missing:\n%s", expected, received.Data)
+		}
+	}
+}
+
+func TestValidateEmailAddress(t *testing.T) {
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"alice@example.com", true},
+		{"a@b", true},
+		{"", false},
+		{"alice", false},
+		{"alice @example.com", false},
+		{"alice@example.com\r\nBcc: eve@example.com", false},
+	}
+	for _, test := range tests {
+		err := validateEmailAddress(test.email)
+		if (err == nil) != test.valid {
+			t.Errorf("validateEmailAddress(%q) = %v", test.email, err)
+		}
+		if err != nil && err.Type != HttpErrInvalidEmail {
+			t.Errorf("validateEmailAddress(%q) has type %s", test.email, err.Type.Code)
+		}
+	}
+}
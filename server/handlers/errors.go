@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// ErrorType is an error the API returns. Its code is part of the API and must not change.
+type ErrorType struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+// Error is a single occurrence of an ErrorType. The cause is logged but not sent to the client.
+type Error struct {
+	ID    string
+	Type  *ErrorType
+	Cause error
+}
+
+var (
+	HttpErrInternal         = NewErrorType(http.StatusInternalServerError, ruck.ErrorCodeInternal, "Internal server error")
+	HttpErrBadRequest       = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeBadRequest, "Bad request")
+	HttpErrUnauthorized     = NewErrorType(http.StatusUnauthorized, ruck.ErrorCodeUnauthorized, "Authentication required")
+	HttpErrNotFound         = NewErrorType(http.StatusNotFound, ruck.ErrorCodeNotFound, "Not found")
+	HttpErrMethodNotAllowed = NewErrorType(http.StatusMethodNotAllowed, ruck.ErrorCodeMethodNotAllowed, "Method not allowed")
+)
+
+func NewErrorType(statusCode int, code string, description string) *ErrorType {
+	return &ErrorType{
+		StatusCode:  statusCode,
+		Code:        code,
+		Description: description,
+	}
+}
+
+func (t *ErrorType) Cause(cause error) *Error {
+	return &Error{
+		ID:    RandStringRunes(32),
+		Type:  t,
+		Cause: cause,
+	}
+}
+
+func (t *ErrorType) Causef(format string, a ...interface{}) *Error {
+	return t.Cause(fmt.Errorf(format, a...))
+}
+
+func (t *ErrorType) CauseString(cause string) *Error {
+	return t.Cause(errors.New(cause))
+}
+
+// Write logs the error and writes it as ruck.ErrorResponse.
+func (e *Error) Write(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Error %s (%s, status %d) for %s %s: %s\n", e.ID, e.Type.Code, e.Type.StatusCode, r.Method,
+		r.URL.Path, e.Cause)
+	mustWriteJsonWithStatus(w, e.Type.StatusCode, &ruck.ErrorResponse{
+		Error:       true,
+		Code:        e.Type.Code,
+		Description: e.Type.Description,
+		ID:          e.ID,
+	})
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type.Code, e.Cause)
+}
+
+// RecoverMiddleWare converts panics of the handlers to internal server errors.
+func RecoverMiddleWare(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				log.Printf("Panic in handler: %v\n%s", recovered, debug.Stack())
+				HttpErrInternal.Causef("panic: %v", recovered).Write(w, r)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NotFound is used for requests which don't match any route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	HttpErrNotFound.CauseString("no route").Write(w, r)
+}
+
+// MethodNotAllowed is used for requests which match a route with another method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	HttpErrMethodNotAllowed.CauseString("no route for method").Write(w, r)
+}
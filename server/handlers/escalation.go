@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"github.com/coffeemakr/ruck/server/notify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxEscalationDelay limits the times after the due date of the escalation policies.
+	maxEscalationDelay   = 30 * 24 * time.Hour
+	maxEscalationPenalty = 100
+)
+
+// Steps of the escalation of an overdue task, each is executed once per due date.
+const (
+	escalationNotify = "notify"
+	escalationAct    = "act"
+)
+
+var HttpErrInvalidEscalation = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidEscalation, "Invalid escalation policy")
+
+// validateEscalationPolicy checks the policy and returns the error to send to the client.
+func validateEscalationPolicy(policy *ruck.EscalationPolicy) *Error {
+	if policy.NotifyGroupAfter < 0 || policy.NotifyGroupAfter > maxEscalationDelay ||
+		policy.ActAfter < 0 || policy.ActAfter > maxEscalationDelay {
+		return HttpErrInvalidEscalation.Causef("delays have to be between 0 and %s", maxEscalationDelay)
+	}
+	if !policy.Action.IsValid() {
+		return HttpErrInvalidEscalation.Causef("invalid action '%s'", policy.Action)
+	}
+	if (policy.Action == ruck.EscalationNone) != (policy.ActAfter == 0) {
+		return HttpErrInvalidEscalation.CauseString("the action and its delay have to be set together")
+	}
+	if policy.Penalty < 0 || policy.Penalty > maxEscalationPenalty {
+		return HttpErrInvalidEscalation.Causef("penalty has to be between 0 and %d", maxEscalationPenalty)
+	}
+	if policy.Penalty > 0 && policy.Action == ruck.EscalationNone {
+		return HttpErrInvalidEscalation.CauseString("a penalty requires an action")
+	}
+	return nil
+}
+
+// claimEscalation records the step and returns false if it has already been executed for the due
+// date of the task.
+func claimEscalation(ctx context.Context, task *ruck.Task, step string) (bool, error) {
+	filter := bson.M{"taskid": task.ID, "step": step, "duedate": task.DueDate}
+	result, err := escalationCollection.UpdateOne(ctx, filter, bson.M{
+		"$setOnInsert": bson.M{"executedat": time.Now()},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount == 1, nil
+}
+
+// notifyGroupOfEscalation tells all members of the group that the task is still overdue. Disabled
+// members aren't notified.
+func notifyGroupOfEscalation(ctx context.Context, task *ruck.Task) {
+	entry := &api.TaskHistoryEntry{
+		TaskID:       task.ID,
+		GroupID:      task.Group.ID,
+		Type:         api.HistoryEscalated,
+		AssigneeName: task.AssigneeName,
+		DueDate:      task.DueDate,
+	}
+	recordTaskHistory(ctx, entry)
+	publishTaskHistoryEvent(api.EventTaskEscalated, task, entry)
+	if UsedNotifier == nil {
+		return
+	}
+	for _, memberName := range task.Group.MemberNames {
+		member, err := getUserForName(ctx, memberName)
+		if err != nil {
+			log.Printf("Failed to load member %s of group %s: %s\n", memberName, task.Group.ID, err)
+			continue
+		}
+		if member.IsDisabled {
+			continue
+		}
+		err = UsedNotifier.Notify(ctx, &notify.Reminder{Kind: notify.ReminderEscalated, Task: task, Recipient: member})
+		if err != nil {
+			log.Printf("Failed to notify %s about escalation of task %s: %s\n", memberName, task.ID, err)
+		}
+	}
+}
+
+// actOnOverdueTask reassigns the task or removes its assignee as configured by the policy of the
+// group and records the penalty of the previous assignee.
+func actOnOverdueTask(ctx context.Context, task *ruck.Task) error {
+	policy := task.Group.Escalation
+	previousAssignee := task.AssigneeName
+	entry := &api.TaskHistoryEntry{
+		TaskID:               task.ID,
+		GroupID:              task.Group.ID,
+		PreviousAssigneeName: previousAssignee,
+		DueDate:              task.DueDate,
+	}
+	if len(task.Group.MemberNames) == 0 {
+		return nil
+	}
+	switch policy.Action {
+	case ruck.EscalationReassign:
+		entry.Type = api.HistoryReassigned
+		entry.AssigneeName = task.NextInQueue(previousAssignee)
+	case ruck.EscalationOpen:
+		entry.Type = api.HistoryOpened
+	default:
+		return nil
+	}
+	if entry.AssigneeName == previousAssignee {
+		// the only member or already open
+		return nil
+	}
+	// the task isn't changed if it was completed or changed in the meantime. The queue is stored,
+	// so that the turn stays with the previous assignee.
+	result, err := taskCollection.UpdateOne(ctx, bson.M{
+		"id":           task.ID,
+		"assigneename": previousAssignee,
+		"duedate":      task.DueDate,
+	}, bson.M{"$set": bson.M{"assigneename": entry.AssigneeName, "queue": task.RotationQueue()}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return nil
+	}
+	if previousAssignee != "" {
+		entry.Penalty = policy.Penalty
+	}
+	task.AssigneeName = entry.AssigneeName
+	task.Assignee = nil
+	log.Printf("Escalated task %s: %s from %s to %s\n", task.ID, entry.Type, previousAssignee, entry.AssigneeName)
+	recordTaskHistory(ctx, entry)
+	publishTaskHistoryEvent(api.EventTaskEscalated, task, entry)
+	if entry.Type == api.HistoryReassigned {
+		notifyAssignee(ctx, notify.ReminderAssigned, task)
+	}
+	return nil
+}
+
+// escalateOverdueTasks executes the steps of the escalation policies which became due since the
+// last run. The action is executed first, so that the notification shows the new assignee.
+func escalateOverdueTasks(ctx context.Context) {
+	now := time.Now()
+	tasks, err := getTasksDueBefore(ctx, now)
+	if err != nil {
+		log.Printf("Failed to get overdue tasks: %s\n", err)
+		return
+	}
+	for _, task := range tasks {
+		policy := task.Group.Escalation
+		overdue := now.Sub(task.DueDate)
+		if policy.Action != ruck.EscalationNone && overdue >= policy.ActAfter {
+			if claimed, err := claimEscalation(ctx, task, escalationAct); err != nil {
+				log.Printf("Failed to record escalation of task %s: %s\n", task.ID, err)
+			} else if claimed {
+				if err := actOnOverdueTask(ctx, task); err != nil {
+					log.Printf("Failed to escalate task %s: %s\n", task.ID, err)
+				}
+			}
+		}
+		if policy.NotifyGroupAfter > 0 && overdue >= policy.NotifyGroupAfter {
+			if claimed, err := claimEscalation(ctx, task, escalationNotify); err != nil {
+				log.Printf("Failed to record escalation of task %s: %s\n", task.ID, err)
+			} else if claimed {
+				notifyGroupOfEscalation(ctx, task)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+)
+
+func TestValidateEscalationPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ruck.EscalationPolicy
+		valid  bool
+	}{
+		{"disabled", ruck.EscalationPolicy{}, true},
+		{"only notify", ruck.EscalationPolicy{NotifyGroupAfter: time.Hour}, true},
+		{"reassign", ruck.EscalationPolicy{ActAfter: 24 * time.Hour, Action: ruck.EscalationReassign}, true},
+		{"open with penalty", ruck.EscalationPolicy{NotifyGroupAfter: time.Hour, ActAfter: 24 * time.Hour,
+			Action: ruck.EscalationOpen, Penalty: 2}, true},
+		{"maximal delays and penalty", ruck.EscalationPolicy{NotifyGroupAfter: maxEscalationDelay, ActAfter: maxEscalationDelay,
+			Action: ruck.EscalationReassign, Penalty: maxEscalationPenalty}, true},
+		{"action without delay", ruck.EscalationPolicy{Action: ruck.EscalationReassign}, false},
+		{"delay without action", ruck.EscalationPolicy{ActAfter: time.Hour}, false},
+		{"penalty without action", ruck.EscalationPolicy{Penalty: 1}, false},
+		{"penalty with only notify", ruck.EscalationPolicy{NotifyGroupAfter: time.Hour, Penalty: 1}, false},
+		{"unknown action", ruck.EscalationPolicy{ActAfter: time.Hour, Action: "delete"}, false},
+		{"negative notify delay", ruck.EscalationPolicy{NotifyGroupAfter: -time.Minute}, false},
+		{"negative action delay", ruck.EscalationPolicy{ActAfter: -time.Minute, Action: ruck.EscalationOpen}, false},
+		{"notify delay too long", ruck.EscalationPolicy{NotifyGroupAfter: maxEscalationDelay + time.Minute}, false},
+		{"action delay too long", ruck.EscalationPolicy{ActAfter: maxEscalationDelay + time.Minute, Action: ruck.EscalationOpen}, false},
+		{"negative penalty", ruck.EscalationPolicy{ActAfter: time.Hour, Action: ruck.EscalationOpen, Penalty: -1}, false},
+		{"penalty too high", ruck.EscalationPolicy{ActAfter: time.Hour, Action: ruck.EscalationOpen, Penalty: maxEscalationPenalty + 1}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			httpErr := validateEscalationPolicy(&test.policy)
+			if (httpErr == nil) != test.valid {
+				t.Errorf("expected valid %t, got %v", test.valid, httpErr)
+			}
+			if httpErr != nil && httpErr.Type != HttpErrInvalidEscalation {
+				t.Errorf("unexpected error type %s", httpErr.Type.Code)
+			}
+		})
+	}
+}
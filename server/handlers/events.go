@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"github.com/coffeemakr/ruck/server/events"
+	"log"
+	"net/http"
+	"time"
+)
+
+// eventKeepAliveInterval is the interval of comments sent on idle event streams, so that proxies
+// don't close the connection.
+const eventKeepAliveInterval = 30 * time.Second
+
+// UsedEventBus distributes the events of the handlers to the event streams.
+var UsedEventBus = events.NewBus()
+
+func newEvent(eventType api.EventType, groupId string, actor string) *api.Event {
+	return &api.Event{
+		ID:      generateId(),
+		Type:    eventType,
+		Time:    time.Now(),
+		GroupID: groupId,
+		Actor:   actor,
+	}
+}
+
+// publishEvent sends the event to the event streams of the recipients and to the webhooks of the group.
+func publishEvent(event *api.Event, recipients []string) {
+	UsedEventBus.Publish(event, recipients)
+	enqueueWebhookDeliveries(event)
+}
+
+// publishTaskEvent sends the event to all members of the group of the task.
+func publishTaskEvent(eventType api.EventType, task *ruck.Task, group *ruck.Group, actor string) {
+	event := newEvent(eventType, group.ID, actor)
+	event.Task = api.NewTask(task)
+	publishEvent(event, group.MemberNames)
+}
+
+func publishTaskCompleted(execution *ruck.TaskExecution, task *ruck.Task, group *ruck.Group) {
+	event := newEvent(api.EventTaskCompleted, group.ID, execution.ExecutorName)
+	event.Task = api.NewTask(task)
+	event.Execution = api.NewTaskExecution(&ruck.TaskExecution{
+		ExecutorName: execution.ExecutorName,
+		Time:         execution.Time,
+		TaskId:       execution.TaskId,
+	})
+	publishEvent(event, group.MemberNames)
+}
+
+// publishTaskHistoryEvent sends the event with the history entry to all members of the group of the task.
+func publishTaskHistoryEvent(eventType api.EventType, task *ruck.Task, entry *api.TaskHistoryEntry) {
+	event := newEvent(eventType, task.Group.ID, entry.Actor)
+	event.Task = api.NewTask(task)
+	event.History = entry
+	publishEvent(event, task.Group.MemberNames)
+}
+
+// publishGroupEvent sends the event to the members of the group and the user who joined or left it.
+func publishGroupEvent(eventType api.EventType, group *ruck.Group, actor string, memberName string) {
+	event := newEvent(eventType, group.ID, actor)
+	event.Group = api.NewGroup(group)
+	event.MemberName = memberName
+	recipients := group.MemberNames
+	if memberName != "" {
+		recipients = append([]string{memberName}, recipients...)
+	}
+	publishEvent(event, recipients)
+}
+
+// StreamEvents sends the events of the groups of the user as server-sent events. The stream ends
+// when the token of the request expires.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	token, err := GetTokenFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		HttpErrInternal.CauseString("response writer can't flush").Write(w, r)
+		return
+	}
+	subscription := UsedEventBus.Subscribe(token.UserName)
+	defer subscription.Close()
+
+	var expired <-chan time.Time
+	if !token.Expiry.IsZero() {
+		expiryTimer := time.NewTimer(time.Until(token.Expiry))
+		defer expiryTimer.Stop()
+		expired = expiryTimer.C
+	}
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// disable buffering of nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-expired:
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// the client was too slow and has to reconnect
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to encode event %s: %s\n", event.ID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -2,21 +2,24 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	http_error "github.com/coffeemakr/go-http-error"
 	"github.com/coffeemakr/ruck"
-	"github.com/gorilla/mux"
+	"github.com/coffeemakr/ruck/api"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
 var (
-	HttpErrGroupNotFound = http_error.NewHttpErrorType(http.StatusNotFound, "Group not found")
-	ErrGroupNotFound     = errors.New("group not found")
+	HttpErrGroupNotFound    = NewErrorType(http.StatusNotFound, ruck.ErrorCodeGroupNotFound, "Group not found")
+	HttpErrInvalidGroupName = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidGroupName, "Invalid group name")
+	HttpErrInvalidLeadTime  = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidLeadTime, "Invalid reminder lead time")
+	ErrGroupNotFound        = errors.New("group not found")
 )
 
 const memberNamesField = "membernames"
@@ -28,7 +31,7 @@ func CreateGroup(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 	var group ruck.Group
-	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+	if err := decodeRequest(r, &group); err != nil {
 		ErrInvalidJsonBody.Cause(err).Write(w, r)
 		return
 	}
@@ -36,13 +39,10 @@ func CreateGroup(w http.ResponseWriter, r *http.Request) {
 	group.ID = generateId()
 	err = createGroup(ctx, &group)
 	if err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
+		HttpErrInternal.Cause(err).Write(w, r)
 		return
 	}
-	err = writeJson(w, group)
-	if err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
-	}
+	writeResponse(w, r, &group)
 }
 
 func GetAllGroups(w http.ResponseWriter, r *http.Request) {
@@ -54,13 +54,10 @@ func GetAllGroups(w http.ResponseWriter, r *http.Request) {
 
 	groups, err := getGroups(ctx, userName)
 	if err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
-		return
-	}
-	if err := writeJson(w, groups); err != nil {
-		http_error.ErrInternalServerError.Causef("Can't write group: %s", err).Write(w, r)
+		HttpErrInternal.Cause(err).Write(w, r)
 		return
 	}
+	writeResponse(w, r, groups)
 }
 
 func GetGroup(w http.ResponseWriter, r *http.Request) {
@@ -69,36 +66,131 @@ func GetGroup(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		panic(err)
 	}
-	var vars = mux.Vars(r)
-	groupId, ok := vars["groupId"]
-	if !ok {
-		panic("Can't read group id")
-	}
+	groupId := getGroupId(r)
 	group, err := getGroupForUser(ctx, groupId, userName)
+	if err == ErrGroupNotFound {
+		HttpErrGroupNotFound.Cause(err).Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, group)
+}
+
+// UpdateGroup changes the name or the reminder lead time of the group.
+func UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	var request api.GroupUpdateRequest
+	userName, err := GetUserNameFromRequest(r)
 	if err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
+		panic(err)
+	}
+	group := groupOfRequest(w, r)
+	if group == nil {
 		return
 	}
-	if err := writeJson(w, group); err != nil {
-		http_error.ErrInternalServerError.Causef("Can't write group: %s", err).Write(w, r)
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
 		return
 	}
+	update := bson.M{}
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			HttpErrInvalidGroupName.CauseString("empty name").Write(w, r)
+			return
+		}
+		group.Name = name
+		update["name"] = name
+	}
+	if request.ReminderLeadMinutes != nil {
+		leadTime := time.Duration(*request.ReminderLeadMinutes) * time.Minute
+		if leadTime < 0 || leadTime > MaxReminderLeadTime {
+			HttpErrInvalidLeadTime.Causef("lead time of %d minutes", *request.ReminderLeadMinutes).Write(w, r)
+			return
+		}
+		group.ReminderLeadTime = leadTime
+		update["reminderleadtime"] = leadTime
+	}
+	if request.Escalation != nil {
+		policy := request.Escalation.Model()
+		if httpErr := validateEscalationPolicy(&policy); httpErr != nil {
+			httpErr.Write(w, r)
+			return
+		}
+		group.Escalation = policy
+		update["escalation"] = policy
+	}
+	if request.ClaimPolicy != nil {
+		if !request.ClaimPolicy.IsValid() {
+			HttpErrInvalidClaimPolicy.Causef("invalid claim policy '%s'", *request.ClaimPolicy).Write(w, r)
+			return
+		}
+		group.ClaimPolicy = *request.ClaimPolicy
+		update["claimpolicy"] = group.ClaimPolicy
+	}
+	if request.CoverPolicy != nil {
+		if !request.CoverPolicy.IsValid() {
+			HttpErrInvalidCoverPolicy.Causef("invalid cover policy '%s'", *request.CoverPolicy).Write(w, r)
+			return
+		}
+		group.CoverPolicy = *request.CoverPolicy
+		update["coverpolicy"] = group.CoverPolicy
+	}
+	if len(update) != 0 {
+		if _, err := groupsCollection.UpdateOne(r.Context(), bson.M{"id": group.ID}, bson.M{"$set": update}); err != nil {
+			HttpErrInternal.Cause(err).Write(w, r)
+			return
+		}
+	}
+	// the policies change when the tasks are reminded, escalated, claimed and rotated
+	if request.ReminderLeadMinutes != nil || request.Escalation != nil || request.ClaimPolicy != nil || request.CoverPolicy != nil {
+		publishTasksOfGroupUpdated(r.Context(), group, userName)
+	}
+	writeResponse(w, r, group)
+}
+
+// publishTasksOfGroupUpdated publishes all tasks of the group as updated. The group has already
+// been changed, so errors are only logged.
+func publishTasksOfGroupUpdated(ctx context.Context, group *ruck.Group, actor string) {
+	tasks, err := getTasksOfGroup(ctx, group.ID)
+	if err != nil {
+		log.Printf("Failed to publish the tasks of group %s: %s\n", group.ID, err)
+		return
+	}
+	for _, task := range tasks {
+		task.Group = group
+		publishTaskEvent(api.EventTaskUpdated, task, group, actor)
+	}
 }
 
 func DeleteGroup(w http.ResponseWriter, r *http.Request) {
 	var ctx = r.Context()
 	var userName, err = GetUserNameFromRequest(r)
 	if err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
 	}
-	var vars = mux.Vars(r)
-	groupId, ok := vars["groupId"]
-	if !ok {
-		panic("Can't read group id")
+	groupId := getGroupId(r)
+	group, err := getGroupForUser(ctx, groupId, userName)
+	if err == ErrGroupNotFound {
+		HttpErrGroupNotFound.Cause(err).Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
 	}
-	err = deleteGroupForUser(ctx, groupId, userName)
+	tasks, err := deleteGroupWithTasks(ctx, group.ID)
 	if err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	for _, task := range tasks {
+		publishTaskEvent(api.EventTaskDeleted, task, group, userName)
+	}
+	publishGroupEvent(api.EventGroupDeleted, group, userName, "")
+	if err := deleteWebhooksOfGroup(ctx, group.ID); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -108,44 +200,38 @@ func JoinGroup(w http.ResponseWriter, r *http.Request) {
 	var ctx = r.Context()
 	var userName, err = GetUserNameFromRequest(r)
 	if err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
-	}
-	var vars = mux.Vars(r)
-	groupId, ok := vars["groupId"]
-	if !ok {
-		panic("Can't read group id")
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
 	}
+	groupId := getGroupId(r)
 	err = joinGroup(ctx, userName, groupId)
-	if err != nil {
+	if err == ErrGroupNotFound {
 		HttpErrGroupNotFound.Cause(err).Write(w, r)
 		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
 func joinGroup(ctx context.Context, userName string, groupId string) error {
-	result, err := groupsCollection.UpdateOne(ctx, bson.M{
+	var group ruck.Group
+	err := groupsCollection.FindOneAndUpdate(ctx, bson.M{
 		"id": bson.M{"$eq": groupId},
 	}, bson.M{
 		"$addToSet": bson.M{memberNamesField: userName},
-	})
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&group)
+	if err == mongo.ErrNoDocuments {
+		return ErrGroupNotFound
+	}
 	if err != nil {
 		return fmt.Errorf("joining group failed: %s", err)
 	}
-	if result.MatchedCount == 0 {
-		return ErrGroupNotFound
-	}
+	publishGroupEvent(api.EventMemberJoined, &group, userName, userName)
 	return nil
 }
 
-func deleteGroupForUser(ctx context.Context, groupId string, userName string) error {
-	_, err := groupsCollection.DeleteOne(ctx, bson.M{
-		"id":             bson.D{{"$eq", groupId}},
-		memberNamesField: bson.D{{"$in", []string{userName}}},
-	})
-	return err
-}
-
 func createGroup(ctx context.Context, group *ruck.Group) error {
 	_, err := groupsCollection.InsertOne(ctx, group)
 	if err != nil {
@@ -170,6 +256,24 @@ func getGroupForUser(ctx context.Context, groupId string, userName string) (*ruc
 	return &group, nil
 }
 
+// groupOfRequest returns the group of the URL if the user is a member. Otherwise the error is
+// written and nil is returned.
+func groupOfRequest(w http.ResponseWriter, r *http.Request) *ruck.Group {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	group, err := getGroupForUser(r.Context(), getGroupId(r), userName)
+	if err == ErrGroupNotFound {
+		HttpErrGroupNotFound.Cause(err).Write(w, r)
+		return nil
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return nil
+	}
+	return group
+}
+
 func getGroups(ctx context.Context, userName string) ([]*ruck.Group, error) {
 	var results []*ruck.Group
 	cursor, err := groupsCollection.Find(ctx, bson.M{
@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	http_error "github.com/coffeemakr/go-http-error"
+	"github.com/coffeemakr/ruck"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -14,11 +14,30 @@ import (
 )
 
 var (
-	taskCollection          *mongo.Collection
-	usersCollection         *mongo.Collection
-	groupsCollection        *mongo.Collection
-	taskExecutionCollection *mongo.Collection
-	ErrInvalidJsonBody      = http_error.ErrBadRequest.WithDescription("Invalid JSON body")
+	taskCollection                 *mongo.Collection
+	usersCollection                *mongo.Collection
+	groupsCollection               *mongo.Collection
+	taskExecutionCollection        *mongo.Collection
+	passwordResetCollection        *mongo.Collection
+	refreshTokenCollection         *mongo.Collection
+	revokedTokenCollection         *mongo.Collection
+	accessTokenCollection          *mongo.Collection
+	loginFailureCollection         *mongo.Collection
+	twoFactorCollection            *mongo.Collection
+	ssoLoginCollection             *mongo.Collection
+	ssoCodeCollection              *mongo.Collection
+	ssoIdentityCollection          *mongo.Collection
+	registrationCodeCollection     *mongo.Collection
+	webhookCollection              *mongo.Collection
+	webhookDeliveryCollection      *mongo.Collection
+	reminderCollection             *mongo.Collection
+	notificationSettingsCollection *mongo.Collection
+	digestCollection               *mongo.Collection
+	pushSettingsCollection         *mongo.Collection
+	calendarFeedCollection         *mongo.Collection
+	taskHistoryCollection          *mongo.Collection
+	escalationCollection           *mongo.Collection
+	ErrInvalidJsonBody             = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidJSON, "Invalid JSON body")
 )
 
 func SetDB(db *mongo.Database) {
@@ -26,6 +45,25 @@ func SetDB(db *mongo.Database) {
 	usersCollection = db.Collection("users")
 	groupsCollection = db.Collection("groups")
 	taskExecutionCollection = db.Collection("task_executions")
+	passwordResetCollection = db.Collection("password_reset_tokens")
+	refreshTokenCollection = db.Collection("refresh_tokens")
+	revokedTokenCollection = db.Collection("revoked_tokens")
+	accessTokenCollection = db.Collection("access_tokens")
+	loginFailureCollection = db.Collection("login_failures")
+	twoFactorCollection = db.Collection("two_factor")
+	ssoLoginCollection = db.Collection("sso_logins")
+	ssoCodeCollection = db.Collection("sso_codes")
+	ssoIdentityCollection = db.Collection("sso_identities")
+	registrationCodeCollection = db.Collection("registration_codes")
+	webhookCollection = db.Collection("webhooks")
+	webhookDeliveryCollection = db.Collection("webhook_deliveries")
+	reminderCollection = db.Collection("reminders")
+	notificationSettingsCollection = db.Collection("notification_settings")
+	digestCollection = db.Collection("digests")
+	pushSettingsCollection = db.Collection("push_settings")
+	calendarFeedCollection = db.Collection("calendar_feeds")
+	taskHistoryCollection = db.Collection("task_history")
+	escalationCollection = db.Collection("escalations")
 	_, err := usersCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
 		Keys: bson.M{
 			"name": 1,
@@ -36,15 +74,189 @@ func SetDB(db *mongo.Database) {
 	if err != nil {
 		log.Fatal("create", err)
 	}
+
+	_, err = accessTokenCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"hash": 1},
+		Options: options.Index().SetName("access_token_hash").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = refreshTokenCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"hash": 1},
+		Options: options.Index().SetName("refresh_token_hash").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = ssoIdentityCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "issuer", Value: 1}, {Key: "subject", Value: 1}},
+		Options: options.Index().SetName("sso_identity_subject").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = webhookCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"groupid": 1},
+		Options: options.Index().SetName("webhook_group"),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = webhookDeliveryCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "status", Value: 1}, {Key: "nextattempt", Value: 1}},
+		Options: options.Index().SetName("webhook_delivery_due"),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = webhookDeliveryCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"createdat": 1},
+		Options: options.Index().SetName("webhook_delivery_retention").SetExpireAfterSeconds(int32(webhookDeliveryRetention.Seconds())),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = reminderCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "taskid", Value: 1}, {Key: "kind", Value: 1}, {Key: "duedate", Value: 1}},
+		Options: options.Index().SetName("reminder_occurrence").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = reminderCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"duedate": 1},
+		Options: options.Index().SetName("reminder_retention").SetExpireAfterSeconds(int32(reminderRetention.Seconds())),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = notificationSettingsCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"username": 1},
+		Options: options.Index().SetName("notification_settings_user").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = pushSettingsCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"username": 1},
+		Options: options.Index().SetName("push_settings_user").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = calendarFeedCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"username": 1},
+		Options: options.Index().SetName("calendar_feed_user").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = calendarFeedCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"token": 1},
+		Options: options.Index().SetName("calendar_feed_token").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = taskHistoryCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "taskid", Value: 1}, {Key: "time", Value: -1}},
+		Options: options.Index().SetName("task_history_task"),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = taskHistoryCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"groupid": 1},
+		Options: options.Index().SetName("task_history_group"),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = escalationCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "taskid", Value: 1}, {Key: "step", Value: 1}, {Key: "duedate", Value: 1}},
+		Options: options.Index().SetName("escalation_occurrence").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = escalationCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"duedate": 1},
+		Options: options.Index().SetName("escalation_retention").SetExpireAfterSeconds(int32(reminderRetention.Seconds())),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = digestCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}, {Key: "notification", Value: 1}, {Key: "period", Value: 1}},
+		Options: options.Index().SetName("digest_period").SetUnique(true),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = digestCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"sentat": 1},
+		Options: options.Index().SetName("digest_retention").SetExpireAfterSeconds(int32(digestRetention.Seconds())),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	_, err = loginFailureCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"lastfailure": 1},
+		Options: options.Index().SetName("login_failure_retention").SetExpireAfterSeconds(int32(loginFailureRetention.Seconds())),
+	})
+	if err != nil {
+		log.Fatal("create", err)
+	}
+
+	// remove expired tokens automatically
+	for name, collection := range map[string]*mongo.Collection{
+		"reset_token_expiry":       passwordResetCollection,
+		"refresh_token_expiry":     refreshTokenCollection,
+		"revoked_token_expiry":     revokedTokenCollection,
+		"sso_login_expiry":         ssoLoginCollection,
+		"sso_code_expiry":          ssoCodeCollection,
+		"registration_code_expiry": registrationCodeCollection,
+	} {
+		_, err = collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+			Keys:    bson.M{"expiry": 1},
+			Options: options.Index().SetName(name).SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			log.Fatal("create", err)
+		}
+	}
 }
 
-func writeJson(w http.ResponseWriter, value interface{}) (err error) {
+func setJsonHeaders(w http.ResponseWriter) {
 	// prevent browsers from displaying the JSON as HTML
 	w.Header().Set("Content-Type", "application/json")
 	// disable loading of any sources
 	w.Header().Set("Content-Security-Policy", "default-src 'none'")
 	// disable content type sniffing, for CORB and disallow usage in script or style tags
 	w.Header().Set("X-Content-Type-Options", "nosniff")
+}
+
+func writeJson(w http.ResponseWriter, value interface{}) (err error) {
+	setJsonHeaders(w)
 	err = json.NewEncoder(w).Encode(value)
 	if err != nil {
 		err = fmt.Errorf("failed to write response: %s", err)
@@ -60,6 +272,15 @@ func mustWriteJson(w http.ResponseWriter, value interface{}) {
 	}
 }
 
+// mustWriteJsonWithStatus writes the value with a status code other than 200.
+func mustWriteJsonWithStatus(w http.ResponseWriter, statusCode int, value interface{}) {
+	setJsonHeaders(w)
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("failed to write response: %s\n", err)
+	}
+}
+
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-")
 
 func RandStringRunes(n int) string {
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/square/go-jose/v3"
+)
+
+// newTestKey generates an ES256 signing key, which is much faster than an RSA key.
+func newTestKey(t *testing.T, keyId string) *jose.JSONWebKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &jose.JSONWebKey{Key: key, KeyID: keyId, Algorithm: string(jose.ES256), Use: "sig"}
+}
+
+// setUpTestTokens sets UsedTokenIssuer and UsedTokenVerifier to a new key until the test ends.
+func setUpTestTokens(t *testing.T) (*JwtTokenIssuer, *JwtTokenVerifier) {
+	t.Helper()
+	key := newTestKey(t, "test")
+	issuer := &JwtTokenIssuer{PrivateKey: key}
+	verifier := &JwtTokenVerifier{KeySet: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.Public()}}}
+	previousIssuer, previousVerifier := UsedTokenIssuer, UsedTokenVerifier
+	UsedTokenIssuer, UsedTokenVerifier = issuer, verifier
+	t.Cleanup(func() {
+		UsedTokenIssuer, UsedTokenVerifier = previousIssuer, previousVerifier
+	})
+	return issuer, verifier
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+const maxListedHistoryEntries = 100
+
+// recordTaskHistory stores the entry. Failures are only logged, because the change of the task was
+// already stored.
+func recordTaskHistory(ctx context.Context, entry *api.TaskHistoryEntry) {
+	entry.ID = generateId()
+	entry.Time = time.Now()
+	if _, err := taskHistoryCollection.InsertOne(ctx, entry); err != nil {
+		log.Printf("Failed to record %s of task %s: %s\n", entry.Type, entry.TaskID, err)
+	}
+}
+
+// getTaskHistory returns the latest entries of the task, the newest first.
+func getTaskHistory(ctx context.Context, taskId string) ([]*api.TaskHistoryEntry, error) {
+	cursor, err := taskHistoryCollection.Find(ctx, bson.M{"taskid": taskId},
+		options.Find().SetSort(bson.D{{Key: "time", Value: -1}}).SetLimit(maxListedHistoryEntries))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*api.TaskHistoryEntry, 0)
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// sumByMember runs the aggregation which groups by member name and returns the sums.
+func sumByMember(ctx context.Context, collection *mongo.Collection, match bson.M, nameField string, value interface{}) (map[string]int, error) {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": nameField, "sum": bson.M{"$sum": value}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		Name string `bson:"_id"`
+		Sum  int    `bson:"sum"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	sums := make(map[string]int, len(results))
+	for _, result := range results {
+		sums[result.Name] = result.Sum
+	}
+	return sums, nil
+}
+
+// getGroupBalance counts the completed tasks and the penalties of the members of the group.
+func getGroupBalance(ctx context.Context, group *ruck.Group) ([]*api.MemberBalance, error) {
+	cursor, err := taskCollection.Find(ctx, bson.M{"groupid": group.ID})
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*ruck.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+	taskIds := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		taskIds = append(taskIds, task.ID)
+	}
+	completed, err := sumByMember(ctx, taskExecutionCollection, bson.M{"task_id": bson.M{"$in": taskIds}}, "$executor_id", 1)
+	if err != nil {
+		return nil, err
+	}
+	penalties, err := sumByMember(ctx, taskHistoryCollection, bson.M{"groupid": group.ID, "penalty": bson.M{"$gt": 0}},
+		"$previousassigneename", "$penalty")
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]*api.MemberBalance, 0, len(group.MemberNames))
+	for _, memberName := range group.MemberNames {
+		balances = append(balances, &api.MemberBalance{
+			MemberName: memberName,
+			Completed:  completed[memberName],
+			Penalties:  penalties[memberName],
+			Balance:    completed[memberName] - penalties[memberName],
+		})
+	}
+	return balances, nil
+}
+
+// GetTaskHistory returns the completions, reassignments and escalations of the task.
+func GetTaskHistory(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	ctx := r.Context()
+	task, err := getTaskByIdIncludingGroup(ctx, getTaskId(r))
+	switch {
+	case err == ErrNoSuchTask || err == nil && !stringArrayContain(task.Group.MemberNames, userName):
+		HttpErrTaskNotFound.Cause(ErrNoSuchTask).Write(w, r)
+		return
+	case err != nil:
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	entries, err := getTaskHistory(ctx, task.ID)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, entries)
+}
+
+// GetGroupBalance returns how many tasks each member completed and the penalties of overdue tasks.
+func GetGroupBalance(w http.ResponseWriter, r *http.Request) {
+	group := groupOfRequest(w, r)
+	if group == nil {
+		return
+	}
+	balances, err := getGroupBalance(r.Context(), group)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, balances)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// loginFailureRetention is the time after the last failure until the failures are forgotten.
+const loginFailureRetention = 24 * time.Hour
+
+// LockoutPolicy defines when accounts are locked after failed logins.
+type LockoutPolicy struct {
+	// MaxFailures is the number of failed logins after which the account is locked.
+	MaxFailures int
+	// Duration is the duration of the first lockout. It doubles with every further failure.
+	Duration time.Duration
+	// MaxDuration limits the duration of a lockout.
+	MaxDuration time.Duration
+}
+
+var UsedLockoutPolicy = LockoutPolicy{
+	MaxFailures: 5,
+	Duration:    time.Minute,
+	MaxDuration: time.Hour,
+}
+
+// authenticationStep distinguishes the failures of the password and the one-time code.
+type authenticationStep string
+
+const (
+	passwordStep  = authenticationStep("password")
+	twoFactorStep = authenticationStep("2fa")
+)
+
+// LoginFailures is the stored number of failed logins of an account.
+type LoginFailures struct {
+	UserName    string             `bson:"username"`
+	Step        authenticationStep `bson:"step"`
+	Failures    int                `bson:"failures"`
+	LastFailure time.Time          `bson:"lastfailure"`
+	LockedUntil time.Time          `bson:"lockeduntil"`
+}
+
+// IsLocked returns true if the account is locked at the given time.
+func (f *LoginFailures) IsLocked(now time.Time) bool {
+	return f != nil && now.Before(f.LockedUntil)
+}
+
+// AccountLockedError is returned if the account is temporarily locked.
+type AccountLockedError struct {
+	UserName string
+	Until    time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account %s is locked until %s", e.UserName, e.Until.Format(time.RFC3339))
+}
+
+var HttpErrAccountLocked = NewErrorType(http.StatusTooManyRequests, ruck.ErrorCodeAccountLocked, "Too many failed logins, try again later")
+
+// lockoutDuration returns the lockout duration after the given number of failures.
+func (p *LockoutPolicy) lockoutDuration(failures int) time.Duration {
+	if p.MaxFailures <= 0 || failures < p.MaxFailures {
+		return 0
+	}
+	duration := p.Duration
+	for i := p.MaxFailures; i < failures && duration < p.MaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > p.MaxDuration {
+		duration = p.MaxDuration
+	}
+	return duration
+}
+
+func getLoginFailures(ctx context.Context, userName string, step authenticationStep) (*LoginFailures, error) {
+	var failures LoginFailures
+	err := loginFailureCollection.FindOne(ctx, bson.M{"username": userName, "step": step}).Decode(&failures)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &failures, nil
+}
+
+func recordLoginFailure(ctx context.Context, userName string, step authenticationStep) error {
+	var failures LoginFailures
+	now := time.Now()
+	filter := bson.M{"username": userName, "step": step}
+	err := loginFailureCollection.FindOneAndUpdate(ctx, filter, bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"lastfailure": now},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&failures)
+	if err != nil {
+		return err
+	}
+	duration := UsedLockoutPolicy.lockoutDuration(failures.Failures)
+	if duration == 0 {
+		return nil
+	}
+	log.Printf("Locking account %s for %s after %d failed logins (%s)\n", userName, duration, failures.Failures, step)
+	_, err = loginFailureCollection.UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{"lockeduntil": now.Add(duration)},
+	})
+	return err
+}
+
+func resetLoginFailures(ctx context.Context, userName string, step authenticationStep) error {
+	_, err := loginFailureCollection.DeleteOne(ctx, bson.M{"username": userName, "step": step})
+	return err
+}
+
+// checkNotLocked returns an AccountLockedError if the step is locked for the user.
+func checkNotLocked(ctx context.Context, userName string, step authenticationStep) (*LoginFailures, error) {
+	failures, err := getLoginFailures(ctx, userName, step)
+	if err != nil {
+		return nil, err
+	}
+	if failures.IsLocked(time.Now()) {
+		return nil, &AccountLockedError{UserName: userName, Until: failures.LockedUntil}
+	}
+	return failures, nil
+}
+
+// authenticateUser checks the credentials unless the account is locked and records failures.
+// Accounts which don't exist are treated like existing ones to not leak their existence.
+func authenticateUser(ctx context.Context, credentials *ruck.Credentials) (*ruck.User, error) {
+	failures, err := checkNotLocked(ctx, credentials.Name, passwordStep)
+	if err != nil {
+		return nil, err
+	}
+	user, err := getUserForCredentials(ctx, credentials)
+	switch err {
+	case nil:
+		if failures != nil {
+			err = resetLoginFailures(ctx, credentials.Name, passwordStep)
+		}
+		return user, err
+	case ErrNoSucUser:
+		if err := recordLoginFailure(ctx, credentials.Name, passwordStep); err != nil {
+			return nil, err
+		}
+		return nil, ErrNoSucUser
+	default:
+		return nil, err
+	}
+}
+
+// writeAccountLockedError writes the error with a Retry-After header.
+func writeAccountLockedError(w http.ResponseWriter, r *http.Request, err *AccountLockedError) {
+	seconds := int(time.Until(err.Until).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	HttpErrAccountLocked.Cause(err).Write(w, r)
+}
+
+// writeAuthenticationError writes the response for an error returned by authenticateUser.
+func writeAuthenticationError(w http.ResponseWriter, r *http.Request, err error) {
+	var lockedErr *AccountLockedError
+	switch {
+	case err == ErrNoSucUser:
+		HttpErrInvalidCredentials.Cause(err).Write(w, r)
+	case err == ErrUserDisabled:
+		HttpErrUserDisabled.Cause(err).Write(w, r)
+	case errors.As(err, &lockedErr):
+		writeAccountLockedError(w, r, lockedErr)
+	default:
+		HttpErrInternal.Causef("Failed to get user: %s", err).Write(w, r)
+	}
+}
+
+// GetLoginFailures returns the stored login failures of all accounts.
+// The failures of the password and the one-time code are combined.
+func GetLoginFailures(ctx context.Context) (map[string]*LoginFailures, error) {
+	results := make(map[string]*LoginFailures)
+	cursor, err := loginFailureCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var failures LoginFailures
+		if err := cursor.Decode(&failures); err != nil {
+			return nil, err
+		}
+		if combined, ok := results[failures.UserName]; ok {
+			combined.Failures += failures.Failures
+			if failures.LockedUntil.After(combined.LockedUntil) {
+				combined.LockedUntil = failures.LockedUntil
+			}
+			if failures.LastFailure.After(combined.LastFailure) {
+				combined.LastFailure = failures.LastFailure
+			}
+		} else {
+			results[failures.UserName] = &failures
+		}
+	}
+	return results, cursor.Err()
+}
+
+// UnlockUser removes the lockout and the failed logins of the user.
+func UnlockUser(ctx context.Context, userName string) error {
+	_, err := loginFailureCollection.DeleteMany(ctx, bson.M{"username": userName})
+	return err
+}
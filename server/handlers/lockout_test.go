@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	policy := LockoutPolicy{MaxFailures: 3, Duration: time.Minute, MaxDuration: 10 * time.Minute}
+	tests := []struct {
+		name     string
+		policy   LockoutPolicy
+		failures int
+		expected time.Duration
+	}{
+		{"no failures", policy, 0, 0},
+		{"below the limit", policy, 2, 0},
+		{"at the limit", policy, 3, time.Minute},
+		{"doubles after the limit", policy, 4, 2 * time.Minute},
+		{"doubles again", policy, 6, 8 * time.Minute},
+		{"capped", policy, 7, 10 * time.Minute},
+		{"stays capped", policy, 1000, 10 * time.Minute},
+		{"first lockout capped", LockoutPolicy{MaxFailures: 1, Duration: time.Hour, MaxDuration: time.Minute}, 1, time.Minute},
+		{"disabled", LockoutPolicy{MaxFailures: 0, Duration: time.Minute, MaxDuration: time.Hour}, 100, 0},
+		{"default policy", UsedLockoutPolicy, 5, time.Minute},
+		{"default policy capped", UsedLockoutPolicy, 12, time.Hour},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if duration := test.policy.lockoutDuration(test.failures); duration != test.expected {
+				t.Errorf("lockoutDuration(%d) = %s, expected %s", test.failures, duration, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoginFailuresIsLocked(t *testing.T) {
+	now := time.Date(2020, 5, 4, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		failures *LoginFailures
+		locked   bool
+	}{
+		{"no failures", nil, false},
+		{"never locked", &LoginFailures{Failures: 2}, false},
+		{"locked", &LoginFailures{Failures: 5, LockedUntil: now.Add(time.Second)}, true},
+		{"lock ends", &LoginFailures{Failures: 5, LockedUntil: now}, false},
+		{"lock ended", &LoginFailures{Failures: 5, LockedUntil: now.Add(-time.Minute)}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if locked := test.failures.IsLocked(now); locked != test.locked {
+				t.Errorf("expected locked %t, got %t", test.locked, locked)
+			}
+		})
+	}
+}
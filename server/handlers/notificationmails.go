@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bytes"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/mail"
+	htmltemplate "html/template"
+	"net/url"
+	texttemplate "text/template"
+	"time"
+)
+
+const mailTimeFormat = "Mon, 2 Jan 2006 15:04"
+
+// notificationMail is the data of the notification mail templates.
+type notificationMail struct {
+	Name           string
+	UnsubscribeURL string
+	// Task is set for reminders
+	Task *mailTask
+	// Tasks is set for daily digests
+	Tasks []*mailTask
+	// Groups is set for weekly summaries
+	Groups []*mailGroupSummary
+}
+
+type mailTask struct {
+	Name      string
+	GroupName string
+	Due       string
+	Overdue   bool
+}
+
+type mailExecution struct {
+	TaskName     string
+	ExecutorName string
+	Time         string
+}
+
+type mailGroupSummary struct {
+	Name      string
+	Completed []*mailExecution
+	Upcoming  []*mailTask
+}
+
+var notificationTextTemplates = texttemplate.Must(texttemplate.New("notifications").Parse(`
+{{- define "footer"}}
+--
+You receive this mail because of your notification settings in ruck.
+Unsubscribe: {{.UnsubscribeURL}}
+{{end}}
+
+{{- define "task"}}{{.Name}} ({{.GroupName}}), {{if .Overdue}}was due{{else}}due{{end}} {{.Due}}{{end}}
+
+{{- define "reminder"}}Hello {{.Name}}
+
+{{with .Task}}{{if .Overdue}}The task "{{.Name}}" of the group {{.GroupName}} is overdue, it was due {{.Due}}.
+{{- else}}The task "{{.Name}}" of the group {{.GroupName}} is due {{.Due}}.{{end}}{{end}}
+It is assigned to you.
+{{template "footer" .}}{{end}}
+
+{{- define "daily_digest"}}Hello {{.Name}}
+
+These tasks are assigned to you and due today:
+{{range .Tasks}}
+- {{template "task" .}}{{end}}
+{{template "footer" .}}{{end}}
+
+{{- define "weekly_summary"}}Hello {{.Name}}
+
+This is the summary of the last week in your groups.
+{{range .Groups}}
+{{.Name}}
+
+Completed:{{range .Completed}}
+- {{.TaskName}} by {{.ExecutorName}}, {{.Time}}{{else}} nothing{{end}}
+
+Upcoming:{{range .Upcoming}}
+- {{template "task" .}}{{else}} nothing{{end}}
+{{end}}{{template "footer" .}}{{end}}
+`))
+
+var notificationHTMLTemplates = htmltemplate.Must(htmltemplate.New("notifications").Parse(`
+{{- define "header"}}<!DOCTYPE html>
+<html>
+<body>
+<p>Hello {{.Name}}</p>
+{{end}}
+
+{{- define "footer"}}<p style="color: #666; font-size: small">
+You receive this mail because of your notification settings in ruck.
+<a href="{{.UnsubscribeURL}}">Unsubscribe</a>
+</p>
+</body>
+</html>
+{{end}}
+
+{{- define "task"}}<li><b>{{.Name}}</b> ({{.GroupName}}),
+{{if .Overdue}}<span style="color: #c00">was due {{.Due}}</span>{{else}}due {{.Due}}{{end}}</li>
+{{end}}
+
+{{- define "reminder"}}{{template "header" .}}
+{{with .Task}}<p>The task <b>{{.Name}}</b> of the group {{.GroupName}}
+{{if .Overdue}}is overdue, it was due {{.Due}}.{{else}}is due {{.Due}}.{{end}}
+It is assigned to you.</p>
+{{end}}{{template "footer" .}}{{end}}
+
+{{- define "daily_digest"}}{{template "header" .}}
+<p>These tasks are assigned to you and due today:</p>
+<ul>
+{{range .Tasks}}{{template "task" .}}{{end}}</ul>
+{{template "footer" .}}{{end}}
+
+{{- define "weekly_summary"}}{{template "header" .}}
+<p>This is the summary of the last week in your groups.</p>
+{{range .Groups}}<h3>{{.Name}}</h3>
+<p>Completed:</p>
+<ul>
+{{range .Completed}}<li><b>{{.TaskName}}</b> by {{.ExecutorName}}, {{.Time}}</li>
+{{else}}<li>nothing</li>
+{{end}}</ul>
+<p>Upcoming:</p>
+<ul>
+{{range .Upcoming}}{{template "task" .}}{{else}}<li>nothing</li>
+{{end}}</ul>
+{{end}}{{template "footer" .}}{{end}}
+`))
+
+// userLocation returns the time zone of the user or the one of the server if the user didn't set one.
+func userLocation(user *ruck.User) *time.Location {
+	if user.TimeZone != "" {
+		if location, err := time.LoadLocation(user.TimeZone); err == nil {
+			return location
+		}
+	}
+	return time.Local
+}
+
+func newMailTask(task *ruck.Task, location *time.Location, now time.Time) *mailTask {
+	result := &mailTask{
+		Name:    task.Name,
+		Due:     task.DueDate.In(location).Format(mailTimeFormat),
+		Overdue: !now.Before(task.DueDate),
+	}
+	if task.Group != nil {
+		result.GroupName = task.Group.Name
+	}
+	return result
+}
+
+// canMailUser returns true if notification mails can be sent to the user. Disabled users don't
+// receive any.
+func canMailUser(user *ruck.User) bool {
+	return UsedMailer != nil && user.EmailAddress != "" && user.EmailVerified && !user.IsDisabled
+}
+
+// sendNotificationMail renders the template with the name of the notification and sends it with an
+// unsubscribe link for the notification.
+func sendNotificationMail(user *ruck.User, notification string, subject string, data *notificationMail) error {
+	token, err := UsedTokenIssuer.IssueUnsubscribeToken(user.Name, notification)
+	if err != nil {
+		return err
+	}
+	data.Name = user.Name
+	if user.DisplayName != "" {
+		data.Name = user.DisplayName
+	}
+	data.UnsubscribeURL = buildPublicURL("/unsubscribe", url.Values{"token": {token}})
+	templateName := notification
+	if notification == notificationReminders {
+		templateName = "reminder"
+	}
+	var text, html bytes.Buffer
+	if err := notificationTextTemplates.ExecuteTemplate(&text, templateName, data); err != nil {
+		return err
+	}
+	if err := notificationHTMLTemplates.ExecuteTemplate(&html, templateName, data); err != nil {
+		return err
+	}
+	return UsedMailer.Send(&mail.Message{
+		To:      user.EmailAddress,
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + data.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/notify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Notifications which can be disabled with the unsubscribe link of their mails.
+const (
+	notificationReminders     = "reminders"
+	notificationDailyDigest   = "daily_digest"
+	notificationWeeklySummary = "weekly_summary"
+)
+
+const (
+	DefaultDigestHour = 7
+	// digestRetention is the time for which sent digests are remembered.
+	digestRetention = 60 * 24 * time.Hour
+	summaryPeriod   = 7 * 24 * time.Hour
+)
+
+var (
+	// DigestHour is the hour of the day in the time zone of the user at which digests are sent.
+	DigestHour = DefaultDigestHour
+
+	HttpErrInvalidUnsubscribeToken = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidUnsubscribeToken, "Invalid unsubscribe link")
+
+	// notificationFields are the fields of the stored settings of the notifications.
+	notificationFields = map[string]string{
+		notificationReminders:     "reminders",
+		notificationDailyDigest:   "dailydigest",
+		notificationWeeklySummary: "weeklysummary",
+	}
+	notificationDescriptions = map[string]string{
+		notificationReminders:     "reminders of due tasks",
+		notificationDailyDigest:   "the daily digest",
+		notificationWeeklySummary: "the weekly summary",
+	}
+)
+
+var unsubscribedPage = template.Must(template.New("unsubscribed").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Unsubscribed</title></head>
+<body>
+<p>You won't receive {{.}} anymore. You can enable it again with <code>ruck account notifications</code>.</p>
+</body>
+</html>
+`))
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Unsubscribe</title></head>
+<body>
+<form method="post" action="unsubscribe">
+<input type="hidden" name="token" value="{{.}}">
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>
+`))
+
+// notificationSettingsModel is the stored form of the settings. Users without stored settings use
+// ruck.DefaultNotificationSettings.
+type notificationSettingsModel struct {
+	UserName string                    `bson:"username"`
+	Settings ruck.NotificationSettings `bson:",inline"`
+}
+
+func getNotificationSettings(ctx context.Context, userName string) (*ruck.NotificationSettings, error) {
+	var model notificationSettingsModel
+	err := notificationSettingsCollection.FindOne(ctx, bson.M{"username": userName}).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		settings := ruck.DefaultNotificationSettings
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model.Settings, nil
+}
+
+// updateNotificationSettings sets the fields of the update. The other fields keep their values,
+// which are the defaults if the user didn't store settings yet.
+func updateNotificationSettings(ctx context.Context, userName string, update bson.M) (*ruck.NotificationSettings, error) {
+	if len(update) == 0 {
+		return getNotificationSettings(ctx, userName)
+	}
+	defaults := bson.M{}
+	for field, value := range map[string]bool{
+		"reminders":     ruck.DefaultNotificationSettings.Reminders,
+		"dailydigest":   ruck.DefaultNotificationSettings.DailyDigest,
+		"weeklysummary": ruck.DefaultNotificationSettings.WeeklySummary,
+	} {
+		if _, ok := update[field]; !ok {
+			defaults[field] = value
+		}
+	}
+	changes := bson.M{"$set": update}
+	if len(defaults) != 0 {
+		changes["$setOnInsert"] = defaults
+	}
+	var model notificationSettingsModel
+	err := notificationSettingsCollection.FindOneAndUpdate(ctx, bson.M{"username": userName}, changes,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&model)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Settings, nil
+}
+
+func GetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	settings, err := getNotificationSettings(r.Context(), userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, settings)
+}
+
+// UpdateNotificationSettings changes the notification settings of the user.
+func UpdateNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	var request ruck.NotificationSettingsUpdateRequest
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	update := bson.M{}
+	if request.Reminders != nil {
+		update["reminders"] = *request.Reminders
+	}
+	if request.DailyDigest != nil {
+		update["dailydigest"] = *request.DailyDigest
+	}
+	if request.WeeklySummary != nil {
+		update["weeklysummary"] = *request.WeeklySummary
+	}
+	settings, err := updateNotificationSettings(r.Context(), userName, update)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, settings)
+}
+
+// ShowUnsubscribePage renders a confirmation form for the unsubscribe link of notification mails,
+// so that link scanners don't unsubscribe users.
+func ShowUnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := unsubscribePage.Execute(w, r.URL.Query().Get("token")); err != nil {
+		log.Printf("Failed to render unsubscribe page: %s", err)
+	}
+}
+
+// Unsubscribe disables the notification of the token. It handles the confirmation form and
+// one-click unsubscribe requests of mail clients (RFC 8058).
+func Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userName, notification, err := UsedTokenVerifier.VerifyUnsubscribeToken(r.FormValue("token"))
+	if err != nil {
+		HttpErrInvalidUnsubscribeToken.Cause(err).Write(w, r)
+		return
+	}
+	field, ok := notificationFields[notification]
+	if !ok {
+		HttpErrInvalidUnsubscribeToken.Causef("unknown notification %q", notification).Write(w, r)
+		return
+	}
+	if _, err := getUserForName(r.Context(), userName); err == ErrNoSucUser {
+		HttpErrInvalidUnsubscribeToken.Causef("user %s was deleted", userName).Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if _, err := updateNotificationSettings(r.Context(), userName, bson.M{field: false}); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	log.Printf("User %s unsubscribed from %s\n", userName, notification)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := unsubscribedPage.Execute(w, notificationDescriptions[notification]); err != nil {
+		log.Printf("Failed to render unsubscribe page: %s", err)
+	}
+}
+
+// MailNotifier sends reminders by mail to assignees who enabled them. Assignments and escalations
+// aren't mailed.
+type MailNotifier struct{}
+
+func (MailNotifier) Notify(ctx context.Context, reminder *notify.Reminder) error {
+	user := reminder.Recipient
+	if user == nil || !canMailUser(user) {
+		return nil
+	}
+	if reminder.Kind != notify.ReminderDueSoon && reminder.Kind != notify.ReminderOverdue {
+		return nil
+	}
+	settings, err := getNotificationSettings(ctx, user.Name)
+	if err != nil {
+		return err
+	}
+	if !settings.Reminders {
+		return nil
+	}
+	task := newMailTask(reminder.Task, userLocation(user), time.Now())
+	subject := fmt.Sprintf("Reminder: %s is due", task.Name)
+	if reminder.Kind == notify.ReminderOverdue {
+		subject = fmt.Sprintf("%s is overdue", task.Name)
+	}
+	return sendNotificationMail(user, notificationReminders, subject, &notificationMail{Task: task})
+}
+
+// claimDigest records the digest and returns false if it has already been sent for the period.
+var claimDigest = func(ctx context.Context, userName string, notification string, period string) (bool, error) {
+	filter := bson.M{"username": userName, "notification": notification, "period": period}
+	result, err := digestCollection.UpdateOne(ctx, filter, bson.M{
+		"$setOnInsert": bson.M{"sentat": time.Now()},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount == 1, nil
+}
+
+// sendDailyDigest lists the tasks of the user which are due until the end of the day.
+func sendDailyDigest(ctx context.Context, user *ruck.User, now time.Time) error {
+	tasks, err := getTasksForUser(ctx, user.Name)
+	if err != nil {
+		return err
+	}
+	data := newDailyDigest(user, tasks, now)
+	if len(data.Tasks) == 0 {
+		return nil
+	}
+	subject := fmt.Sprintf("Your tasks for %s", now.In(userLocation(user)).Format("Mon, 2 Jan"))
+	return sendNotificationMail(user, notificationDailyDigest, subject, data)
+}
+
+// newDailyDigest returns the tasks assigned to the user which are due until the end of the day in
+// the time zone of the user.
+func newDailyDigest(user *ruck.User, tasks []*ruck.Task, now time.Time) *notificationMail {
+	location := userLocation(user)
+	local := now.In(location)
+	endOfDay := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, location)
+	sort.Sort(ruck.ByDueDate(tasks))
+	var data notificationMail
+	for _, task := range tasks {
+		if task.AssigneeName == user.Name && task.DueDate.Before(endOfDay) {
+			data.Tasks = append(data.Tasks, newMailTask(task, location, now))
+		}
+	}
+	return &data
+}
+
+// sendWeeklySummary lists the tasks completed in the last week and the tasks due in the next week
+// for every group of the user.
+func sendWeeklySummary(ctx context.Context, user *ruck.User, now time.Time) error {
+	location := userLocation(user)
+	groups, err := getGroups(ctx, user.Name)
+	if err != nil {
+		return err
+	}
+	var data notificationMail
+	for _, group := range groups {
+		var tasks []*ruck.Task
+		cursor, err := taskCollection.Find(ctx, bson.M{"groupid": group.ID}, options.Find().SetSort(bson.M{"duedate": 1}))
+		if err != nil {
+			return err
+		}
+		if err := cursor.All(ctx, &tasks); err != nil {
+			return err
+		}
+		summary := &mailGroupSummary{Name: group.Name}
+		taskNames := make(map[string]string, len(tasks))
+		taskIds := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			taskNames[task.ID] = task.Name
+			taskIds = append(taskIds, task.ID)
+			if task.DueDate.Before(now.Add(summaryPeriod)) {
+				task.Group = group
+				summary.Upcoming = append(summary.Upcoming, newMailTask(task, location, now))
+			}
+		}
+		var executions []*ruck.TaskExecution
+		cursor, err = taskExecutionCollection.Find(ctx, bson.M{
+			"task_id": bson.M{"$in": taskIds},
+			"time":    bson.M{"$gte": now.Add(-summaryPeriod)},
+		}, options.Find().SetSort(bson.M{"time": 1}))
+		if err != nil {
+			return err
+		}
+		if err := cursor.All(ctx, &executions); err != nil {
+			return err
+		}
+		for _, execution := range executions {
+			summary.Completed = append(summary.Completed, &mailExecution{
+				TaskName:     taskNames[execution.TaskId],
+				ExecutorName: execution.ExecutorName,
+				Time:         execution.Time.In(location).Format(mailTimeFormat),
+			})
+		}
+		data.Groups = append(data.Groups, summary)
+	}
+	if len(data.Groups) == 0 {
+		return nil
+	}
+	year, week := now.In(location).ISOWeek()
+	subject := fmt.Sprintf("Weekly summary, week %d of %d", week, year)
+	return sendNotificationMail(user, notificationWeeklySummary, subject, &data)
+}
+
+// sendDigest sends the digest once per period.
+func sendDigest(ctx context.Context, user *ruck.User, notification string, period string,
+	send func(context.Context, *ruck.User, time.Time) error, now time.Time) {
+	claimed, err := claimDigest(ctx, user.Name, notification, period)
+	if err != nil {
+		log.Printf("Failed to record %s of %s: %s\n", notification, user.Name, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	if err := send(ctx, user, now); err != nil {
+		log.Printf("Failed to send %s to %s: %s\n", notification, user.Name, err)
+	}
+}
+
+// dueDigest is a digest which is due and the period for which it is sent.
+type dueDigest struct {
+	notification string
+	period       string
+	send         func(context.Context, *ruck.User, time.Time) error
+}
+
+// dueDigests returns the enabled digests which are due at DigestHour in the time zone of the user.
+// Weekly summaries are due on Mondays. Digests which are due are returned until the end of their
+// period, claimDigest prevents sending them twice.
+func dueDigests(settings *ruck.NotificationSettings, location *time.Location, now time.Time) []dueDigest {
+	local := now.In(location)
+	beforeDigestHour := local.Hour() < DigestHour
+	var digests []dueDigest
+	if settings.DailyDigest && !beforeDigestHour {
+		digests = append(digests, dueDigest{notificationDailyDigest, local.Format("2006-01-02"), sendDailyDigest})
+	}
+	// the summary of the week is sent once the digest hour of Monday passed
+	if settings.WeeklySummary && !(local.Weekday() == time.Monday && beforeDigestHour) {
+		year, week := local.ISOWeek()
+		digests = append(digests, dueDigest{notificationWeeklySummary, fmt.Sprintf("%d-W%02d", year, week), sendWeeklySummary})
+	}
+	return digests
+}
+
+// sendDueDigests sends the daily digests and weekly summaries which are due.
+func sendDueDigests(ctx context.Context) {
+	if UsedMailer == nil {
+		return
+	}
+	cursor, err := notificationSettingsCollection.Find(ctx, bson.M{"$or": []bson.M{
+		{"dailydigest": true},
+		{"weeklysummary": true},
+	}})
+	if err != nil {
+		log.Printf("Failed to get notification settings: %s\n", err)
+		return
+	}
+	var models []*notificationSettingsModel
+	if err := cursor.All(ctx, &models); err != nil {
+		log.Printf("Failed to get notification settings: %s\n", err)
+		return
+	}
+	now := time.Now()
+	for _, model := range models {
+		user, err := getUserForName(ctx, model.UserName)
+		if err != nil {
+			log.Printf("Failed to load user %s for digests: %s\n", model.UserName, err)
+			continue
+		}
+		if !canMailUser(user) {
+			continue
+		}
+		for _, digest := range dueDigests(&model.Settings, userLocation(user), now) {
+			sendDigest(ctx, user, digest.notification, digest.period, digest.send, now)
+		}
+	}
+}
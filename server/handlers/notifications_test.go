@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+)
+
+func TestCanMailUser(t *testing.T) {
+	setUpTestMailer(t)
+	tests := []struct {
+		name     string
+		user     ruck.User
+		canMail  bool
+		noMailer bool
+	}{
+		{"verified", ruck.User{Name: "alice", EmailAddress: "alice@example.com", EmailVerified: true}, true, false},
+		{"without mailer", ruck.User{Name: "alice", EmailAddress: "alice@example.com", EmailVerified: true}, false, true},
+		{"without address", ruck.User{Name: "alice", EmailVerified: true}, false, false},
+		{"unverified", ruck.User{Name: "alice", EmailAddress: "alice@example.com"}, false, false},
+		{"disabled", ruck.User{Name: "alice", EmailAddress: "alice@example.com", EmailVerified: true, IsDisabled: true}, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.noMailer {
+				mailer := UsedMailer
+				UsedMailer = nil
+				defer func() {
+					UsedMailer = mailer
+				}()
+			}
+			if canMail := canMailUser(&test.user); canMail != test.canMail {
+				t.Errorf("expected %t, got %t", test.canMail, canMail)
+			}
+		})
+	}
+}
+
+func TestDueDigests(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := &ruck.NotificationSettings{DailyDigest: true, WeeklySummary: true}
+	// 2021-03-01 is a Monday in week 9
+	monday := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		settings *ruck.NotificationSettings
+		location *time.Location
+		now      time.Time
+		expected []string
+	}{
+		{"Monday before the digest hour", all, time.UTC, monday.Add(6 * time.Hour), nil},
+		{"Monday at the digest hour", all, time.UTC, monday.Add(7 * time.Hour),
+			[]string{"daily_digest 2021-03-01", "weekly_summary 2021-W09"}},
+		{"Tuesday before the digest hour", all, time.UTC, monday.Add(30 * time.Hour),
+			[]string{"weekly_summary 2021-W09"}},
+		{"Tuesday after the digest hour", all, time.UTC, monday.Add(32 * time.Hour),
+			[]string{"daily_digest 2021-03-02", "weekly_summary 2021-W09"}},
+		{"Sunday late", all, time.UTC, monday.Add(-time.Hour),
+			[]string{"daily_digest 2021-02-28", "weekly_summary 2021-W08"}},
+		{"digest hour in the time zone of the user", all, zurich, monday.Add(6*time.Hour + 30*time.Minute),
+			[]string{"daily_digest 2021-03-01", "weekly_summary 2021-W09"}},
+		{"only the daily digest", &ruck.NotificationSettings{DailyDigest: true}, time.UTC, monday.Add(8 * time.Hour),
+			[]string{"daily_digest 2021-03-01"}},
+		{"only the weekly summary", &ruck.NotificationSettings{WeeklySummary: true}, time.UTC, monday.Add(8 * time.Hour),
+			[]string{"weekly_summary 2021-W09"}},
+		{"disabled", &ruck.NotificationSettings{Reminders: true}, time.UTC, monday.Add(8 * time.Hour), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var digests []string
+			for _, digest := range dueDigests(test.settings, test.location, test.now) {
+				digests = append(digests, digest.notification+" "+digest.period)
+			}
+			if strings.Join(digests, ", ") != strings.Join(test.expected, ", ") {
+				t.Errorf("expected %v, got %v", test.expected, digests)
+			}
+		})
+	}
+}
+
+// recordDigestClaims replaces the digest records until the test ends.
+func recordDigestClaims(t *testing.T) map[string]bool {
+	claimed := map[string]bool{}
+	previous := claimDigest
+	claimDigest = func(ctx context.Context, userName string, notification string, period string) (bool, error) {
+		key := userName + " " + notification + " " + period
+		if claimed[key] {
+			return false, nil
+		}
+		claimed[key] = true
+		return true, nil
+	}
+	t.Cleanup(func() {
+		claimDigest = previous
+	})
+	return claimed
+}
+
+func TestSendDigestOncePerPeriod(t *testing.T) {
+	recordDigestClaims(t)
+	var sent []string
+	send := func(ctx context.Context, user *ruck.User, now time.Time) error {
+		sent = append(sent, user.Name)
+		return nil
+	}
+	alice, bob := &ruck.User{Name: "alice"}, &ruck.User{Name: "bob"}
+	now := time.Now()
+	// the scheduler runs several times during the period
+	sendDigest(context.Background(), alice, notificationDailyDigest, "2021-03-01", send, now)
+	sendDigest(context.Background(), alice, notificationDailyDigest, "2021-03-01", send, now)
+	sendDigest(context.Background(), bob, notificationDailyDigest, "2021-03-01", send, now)
+	sendDigest(context.Background(), alice, notificationWeeklySummary, "2021-03-01", send, now)
+	sendDigest(context.Background(), alice, notificationDailyDigest, "2021-03-02", send, now)
+	if strings.Join(sent, " ") != "alice bob alice alice" {
+		t.Errorf("unexpected digests to %v", sent)
+	}
+}
+
+func TestSendDigestWithoutRecord(t *testing.T) {
+	previous := claimDigest
+	claimDigest = func(context.Context, string, string, string) (bool, error) {
+		return false, errors.New("database unavailable")
+	}
+	defer func() {
+		claimDigest = previous
+	}()
+	sendDigest(context.Background(), &ruck.User{Name: "alice"}, notificationDailyDigest, "2021-03-01",
+		func(context.Context, *ruck.User, time.Time) error {
+			t.Error("digest sent without record")
+			return nil
+		}, time.Now())
+}
+
+func TestNewDailyDigest(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &ruck.User{Name: "alice", TimeZone: "Europe/Zurich"}
+	group := &ruck.Group{Name: "flat"}
+	now := time.Date(2021, 3, 1, 7, 0, 0, 0, zurich)
+	tasks := []*ruck.Task{
+		{Name: "Trash", AssigneeName: "alice", DueDate: time.Date(2021, 3, 1, 23, 59, 0, 0, zurich), Group: group},
+		{Name: "Tomorrow", AssigneeName: "alice", DueDate: time.Date(2021, 3, 2, 0, 0, 0, 0, zurich), Group: group},
+		{Name: "Dishes", AssigneeName: "alice", DueDate: time.Date(2021, 2, 27, 18, 0, 0, 0, zurich), Group: group},
+		{Name: "Of bob", AssigneeName: "bob", DueDate: now, Group: group},
+		{Name: "Open", DueDate: now, Group: group},
+	}
+	data := newDailyDigest(user, tasks, now)
+	if len(data.Tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(data.Tasks))
+	}
+	expected := []mailTask{
+		{Name: "Dishes", GroupName: "flat", Due: "Sat, 27 Feb 2021 18:00", Overdue: true},
+		{Name: "Trash", GroupName: "flat", Due: "Mon, 1 Mar 2021 23:59"},
+	}
+	for i, task := range data.Tasks {
+		if *task != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], *task)
+		}
+	}
+}
+
+func TestNotificationMails(t *testing.T) {
+	_, verifier := setUpTestTokens(t)
+	server := setUpTestMailer(t)
+	user := &ruck.User{Name: "alice", DisplayName: "Alice", EmailAddress: "alice@example.com", EmailVerified: true}
+	due := &mailTask{Name: "Trash", GroupName: "flat", Due: "Mon, 1 Mar 2021 18:00"}
+	overdue := &mailTask{Name: "Dishes", GroupName: "flat", Due: "Sat, 27 Feb 2021 18:00", Overdue: true}
+	tests := []struct {
+		notification string
+		data         *notificationMail
+		expected     []string
+	}{
+		{notificationReminders, &notificationMail{Task: overdue}, []string{
+			`The task "Dishes" of the group flat is overdue, it was due Sat, 27 Feb 2021 18:00.`,
+			"<p>The task <b>Dishes</b> of the group flat\nis overdue, it was due Sat, 27 Feb 2021 18:00.",
+		}},
+		{notificationDailyDigest, &notificationMail{Tasks: []*mailTask{overdue, due}}, []string{
+			"These tasks are assigned to you and due today:\n\n- Dishes (flat), was due Sat, 27 Feb 2021 18:00\n- Trash (flat), due Mon, 1 Mar 2021 18:00\n",
+			"<li><b>Trash</b> (flat),\ndue Mon, 1 Mar 2021 18:00</li>",
+			`<span style="color: #c00">was due Sat, 27 Feb 2021 18:00</span>`,
+		}},
+		{notificationWeeklySummary, &notificationMail{Groups: []*mailGroupSummary{{
+			Name:      "flat <3",
+			Completed: []*mailExecution{{TaskName: "Dishes", ExecutorName: "bob", Time: "Sun, 28 Feb 2021 10:00"}},
+		}}}, []string{
+			"flat <3\n\nCompleted:\n- Dishes by bob, Sun, 28 Feb 2021 10:00\n\nUpcoming: nothing\n",
+			"<h3>flat &lt;3</h3>",
+			"<li><b>Dishes</b> by bob, Sun, 28 Feb 2021 10:00</li>",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.notification, func(t *testing.T) {
+			if err := sendNotificationMail(user, test.notification, "Subject", test.data); err != nil {
+				t.Fatal(err)
+			}
+			received := receiveMail(t, server)
+			if len(received.To) != 1 || received.To[0] != "alice@example.com" {
+				t.Errorf("unexpected recipients %v", received.To)
+			}
+			expected := append([]string{
+				"List-Unsubscribe-Post: List-Unsubscribe=One-Click\n",
+				"Hello Alice\n",
+				"<p>Hello Alice</p>",
+				"Unsubscribe: https://ruck.example.com/unsubscribe?token=",
+				`<a href="https://ruck.example.com/unsubscribe?token=`,
+			}, test.expected...)
+			for _, part := range expected {
+				if !strings.Contains(received.Data, part) {
+					t.Errorf("%q missing:\n%s", part, received.Data)
+				}
+			}
+
+			// the link of the header unsubscribes from the notification of the mail
+			var link string
+			for _, line := range strings.Split(received.Data, "\n") {
+				if strings.HasPrefix(line, "List-Unsubscribe: <") {
+					link = strings.TrimSuffix(strings.TrimPrefix(line, "List-Unsubscribe: <"), ">")
+				}
+			}
+			parsed, err := url.Parse(link)
+			if err != nil || !strings.HasPrefix(link, "https://ruck.example.com/unsubscribe?") {
+				t.Fatalf("invalid unsubscribe link %q: %v", link, err)
+			}
+			userName, notification, err := verifier.VerifyUnsubscribeToken(parsed.Query().Get("token"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if userName != "alice" || notification != test.notification {
+				t.Errorf("token of %s for %s", userName, notification)
+			}
+		})
+	}
+}
+
+func TestUnsubscribeRejectsInvalidTokens(t *testing.T) {
+	issuer, _ := setUpTestTokens(t)
+	unknownNotification, err := issuer.IssueUnsubscribeToken("alice", "everything")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIssuer := &JwtTokenIssuer{PrivateKey: newTestKey(t, "other")}
+	otherKey, err := otherIssuer.IssueUnsubscribeToken("alice", notificationDailyDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verificationToken, err := issuer.IssueEmailVerificationToken(&ruck.User{Name: "alice", EmailAddress: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{
+		"missing":              "",
+		"unknown notification": unknownNotification,
+		"other key":            otherKey,
+		"verification token":   verificationToken,
+	} {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/unsubscribe", strings.NewReader(url.Values{"token": {token}}.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			Unsubscribe(recorder, request)
+			if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), ruck.ErrorCodeInvalidUnsubscribeToken) {
+				t.Errorf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,1095 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// openAPIDocument describes the HTTP API in the OpenAPI 3 format. It must be updated together with
+// the routes in cmd/serve.go and the types of the ruck package.
+var openAPIDocument = mustCompactJson(`{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "ruck",
+    "description": "API of ruckd to share recurring tasks in groups. Errors are returned as ErrorResponse with a stable code. The routes are also available without the /api/v1 prefix, but these are deprecated and use the previous field names.",
+    "version": "1"
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {
+        "type": "http",
+        "scheme": "bearer",
+        "description": "Session token of a login or a personal access token (prefixed with ruck_pat_)."
+      }
+    },
+    "parameters": {
+      "groupId": {"name": "groupId", "in": "path", "required": true, "schema": {"type": "string"}},
+      "taskId": {"name": "taskId", "in": "path", "required": true, "schema": {"type": "string"}},
+      "tokenId": {"name": "tokenId", "in": "path", "required": true, "schema": {"type": "string"}},
+      "webhookId": {"name": "webhookId", "in": "path", "required": true, "schema": {"type": "string"}}
+    },
+    "responses": {
+      "Error": {
+        "description": "Error",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorResponse"}}}
+      },
+      "AuthenticationResult": {
+        "description": "Tokens of the user or a request for a two-factor code",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AuthenticationResult"}}}
+      },
+      "User": {
+        "description": "User",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}
+      },
+      "Group": {
+        "description": "Group",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Group"}}}
+      },
+      "Task": {
+        "description": "Task",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Task"}}}
+      }
+    },
+    "schemas": {
+      "ErrorResponse": {
+        "type": "object",
+        "required": ["error", "code", "description"],
+        "properties": {
+          "error": {"type": "boolean", "enum": [true]},
+          "code": {"type": "string", "description": "Stable error code, e.g. task_not_found"},
+          "description": {"type": "string", "description": "Human readable description"},
+          "id": {"type": "string", "description": "Identifies the error in the server log"}
+        }
+      },
+      "LoginRequest": {
+        "type": "object",
+        "required": ["name", "password"],
+        "properties": {
+          "name": {"type": "string"},
+          "password": {"type": "string"}
+        }
+      },
+      "User": {
+        "type": "object",
+        "required": ["name", "email_address", "email_verified", "disabled"],
+        "properties": {
+          "name": {"type": "string"},
+          "email_address": {"type": "string"},
+          "email_verified": {"type": "boolean"},
+          "disabled": {"type": "boolean"},
+          "display_name": {"type": "string"},
+          "time_zone": {"type": "string", "description": "IANA time zone name, e.g. Europe/Zurich"},
+          "language": {"type": "string", "description": "BCP 47 language tag, e.g. de-CH"}
+        }
+      },
+      "ServerInfo": {
+        "type": "object",
+        "required": ["version", "api_versions", "capabilities"],
+        "properties": {
+          "version": {"type": "string"},
+          "api_versions": {"type": "array", "items": {"type": "string"}},
+          "capabilities": {
+            "type": "array",
+            "items": {"type": "string", "enum": ["sso", "registration", "invite_only", "email_verification", "two_factor", "personal_access_tokens"]}
+          }
+        }
+      },
+      "AuthenticationResult": {
+        "type": "object",
+        "properties": {
+          "token": {"type": "string"},
+          "expires_at": {"type": "string", "format": "date-time"},
+          "refresh_token": {"type": "string"},
+          "user": {"$ref": "#/components/schemas/User"},
+          "two_factor_required": {"type": "boolean"},
+          "two_factor_token": {"type": "string", "description": "Has to be sent to /api/v1/login/2fa together with a code"}
+        }
+      },
+      "RegistrationRequest": {
+        "type": "object",
+        "required": ["name", "email", "password", "password_confirmation"],
+        "properties": {
+          "name": {"type": "string"},
+          "email": {"type": "string"},
+          "password": {"type": "string"},
+          "password_confirmation": {"type": "string"},
+          "registration_code": {"type": "string"},
+          "group_id": {"type": "string", "description": "Group to join, accepted as invitation if the registration is invite-only"}
+        }
+      },
+      "RefreshRequest": {
+        "type": "object",
+        "required": ["refresh_token"],
+        "properties": {"refresh_token": {"type": "string"}}
+      },
+      "TwoFactorLoginRequest": {
+        "type": "object",
+        "required": ["token", "code"],
+        "properties": {
+          "token": {"type": "string"},
+          "code": {"type": "string", "description": "One-time or recovery code"}
+        }
+      },
+      "TwoFactorCodeRequest": {
+        "type": "object",
+        "required": ["code"],
+        "properties": {"code": {"type": "string"}}
+      },
+      "TwoFactorEnrollment": {
+        "type": "object",
+        "required": ["secret", "uri"],
+        "properties": {
+          "secret": {"type": "string"},
+          "uri": {"type": "string", "description": "otpauth URI for authenticator apps"}
+        }
+      },
+      "RecoveryCodes": {
+        "type": "object",
+        "required": ["recovery_codes"],
+        "properties": {"recovery_codes": {"type": "array", "items": {"type": "string"}}}
+      },
+      "SSOTokenRequest": {
+        "type": "object",
+        "required": ["code", "code_verifier"],
+        "properties": {
+          "code": {"type": "string"},
+          "code_verifier": {"type": "string"}
+        }
+      },
+      "VerificationRequest": {
+        "type": "object",
+        "required": ["token"],
+        "properties": {"token": {"type": "string"}}
+      },
+      "PasswordChangeRequest": {
+        "type": "object",
+        "required": ["current_password", "password", "password_confirmation"],
+        "properties": {
+          "current_password": {"type": "string"},
+          "password": {"type": "string"},
+          "password_confirmation": {"type": "string"}
+        }
+      },
+      "PasswordForgottenRequest": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {"name": {"type": "string"}}
+      },
+      "PasswordResetRequest": {
+        "type": "object",
+        "required": ["token", "password", "password_confirmation"],
+        "properties": {
+          "token": {"type": "string"},
+          "password": {"type": "string"},
+          "password_confirmation": {"type": "string"}
+        }
+      },
+      "AccountUpdateRequest": {
+        "type": "object",
+        "description": "Only the given fields are changed. A new email address has to be verified again.",
+        "properties": {
+          "display_name": {"type": "string"},
+          "email": {"type": "string"},
+          "time_zone": {"type": "string"},
+          "language": {"type": "string"}
+        }
+      },
+      "AccountDeletionRequest": {
+        "type": "object",
+        "properties": {"password": {"type": "string", "description": "Required if the user has a password"}}
+      },
+      "AccountExport": {
+        "type": "object",
+        "required": ["user", "groups", "tasks", "executions"],
+        "properties": {
+          "user": {"$ref": "#/components/schemas/User"},
+          "groups": {"type": "array", "items": {"$ref": "#/components/schemas/Group"}},
+          "tasks": {"type": "array", "items": {"$ref": "#/components/schemas/Task"}},
+          "executions": {"type": "array", "items": {"$ref": "#/components/schemas/TaskExecution"}},
+          "notification_settings": {"$ref": "#/components/schemas/NotificationSettings"},
+          "push_settings": {"$ref": "#/components/schemas/PushSettings"}
+        }
+      },
+      "NotificationSettings": {
+        "type": "object",
+        "description": "Mails are only sent to verified email addresses.",
+        "required": ["reminders", "daily_digest", "weekly_summary"],
+        "properties": {
+          "reminders": {"type": "boolean", "description": "Mail when an assigned task is due soon or overdue"},
+          "daily_digest": {"type": "boolean", "description": "Mail the assigned tasks due today every morning"},
+          "weekly_summary": {"type": "boolean", "description": "Mail the completed and upcoming tasks of the groups every Monday"}
+        }
+      },
+      "NotificationSettingsUpdateRequest": {
+        "type": "object",
+        "description": "Only the given fields are changed.",
+        "properties": {
+          "reminders": {"type": "boolean"},
+          "daily_digest": {"type": "boolean"},
+          "weekly_summary": {"type": "boolean"}
+        }
+      },
+      "PushSettings": {
+        "type": "object",
+        "required": ["service", "url", "reminders", "assignments"],
+        "properties": {
+          "service": {"type": "string", "enum": ["ntfy", "gotify", "json"]},
+          "url": {"type": "string", "description": "The ntfy topic URL, the Gotify server URL or the URL receiving the JSON messages"},
+          "token": {"type": "string", "writeOnly": true, "description": "Access token of ntfy, application token of Gotify or bearer token of JSON endpoints"},
+          "reminders": {"type": "boolean", "description": "Push when an assigned task is due soon or overdue"},
+          "assignments": {"type": "boolean", "description": "Push when a task is assigned to the user after someone completed it"}
+        }
+      },
+      "CalendarFeed": {
+        "type": "object",
+        "required": ["url", "mine_url", "created_at"],
+        "properties": {
+          "url": {"type": "string", "description": "iCalendar feed with the tasks of all groups of the user"},
+          "mine_url": {"type": "string", "description": "iCalendar feed with the occurrences assigned to the user"},
+          "created_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "TokenScope": {
+        "type": "string",
+        "enum": ["session", "full", "complete", "read"]
+      },
+      "PersonalAccessToken": {
+        "type": "object",
+        "required": ["id", "name", "scope", "created_at"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "scope": {"$ref": "#/components/schemas/TokenScope"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "last_used_at": {"type": "string", "format": "date-time"},
+          "token": {"type": "string", "description": "Only returned when the token is created"}
+        }
+      },
+      "PersonalAccessTokenRequest": {
+        "type": "object",
+        "required": ["name", "scope"],
+        "properties": {
+          "name": {"type": "string"},
+          "scope": {"$ref": "#/components/schemas/TokenScope"}
+        }
+      },
+      "Group": {
+        "type": "object",
+        "required": ["id", "name", "member_names"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "member_names": {"type": "array", "items": {"type": "string"}},
+          "reminder_lead_minutes": {"type": "integer", "description": "Time before the due date at which the assignee is reminded, 0 for the default of the server"},
+          "escalation": {"$ref": "#/components/schemas/Escalation"},
+          "claim_policy": {"$ref": "#/components/schemas/ClaimPolicy"},
+          "cover_policy": {"$ref": "#/components/schemas/CoverPolicy"}
+        }
+      },
+      "CoverPolicy": {
+        "type": "string",
+        "enum": ["", "swap", "skip"],
+        "description": "Turn of the assignee if another member completes the task: empty to keep it, swap for the place of the executor in the queue, skip to count it as taken"
+      },
+      "ClaimPolicy": {
+        "type": "string",
+        "enum": ["", "any", "open"],
+        "description": "Tasks the members can claim: empty for open and overdue tasks, any for all tasks, open only for tasks without assignee"
+      },
+      "Escalation": {
+        "type": "object",
+        "description": "Policy for overdue tasks, steps with zero minutes are disabled",
+        "properties": {
+          "notify_group_after_minutes": {"type": "integer", "minimum": 0, "maximum": 43200, "description": "Time after the due date at which all members are notified"},
+          "act_after_minutes": {"type": "integer", "minimum": 0, "maximum": 43200, "description": "Time after the due date at which the action is executed"},
+          "action": {"type": "string", "enum": ["reassign", "open"], "description": "reassign assigns the task to the next member, open lets anyone take it"},
+          "penalty": {"type": "integer", "minimum": 0, "maximum": 100, "description": "Recorded in the balance of the assignee when the action is executed"}
+        }
+      },
+      "GroupRequest": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {"name": {"type": "string"}}
+      },
+      "GroupUpdateRequest": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "reminder_lead_minutes": {"type": "integer", "minimum": 0, "maximum": 10080},
+          "escalation": {"$ref": "#/components/schemas/Escalation", "description": "Replaces the whole policy"},
+          "claim_policy": {"$ref": "#/components/schemas/ClaimPolicy"},
+          "cover_policy": {"$ref": "#/components/schemas/CoverPolicy"}
+        }
+      },
+      "TaskHistoryEntry": {
+        "type": "object",
+        "required": ["id", "task_id", "group_id", "type", "time", "due_date"],
+        "properties": {
+          "id": {"type": "string"},
+          "task_id": {"type": "string"},
+          "group_id": {"type": "string"},
+          "type": {"type": "string", "enum": ["completed", "escalated", "reassigned", "opened", "claimed"]},
+          "time": {"type": "string", "format": "date-time"},
+          "actor": {"type": "string", "description": "User who caused the entry, missing for the server"},
+          "assignee_name": {"type": "string", "description": "Assignee after the entry, missing if anyone can take the task"},
+          "previous_assignee_name": {"type": "string"},
+          "due_date": {"type": "string", "format": "date-time", "description": "Due date of the task before the entry"},
+          "penalty": {"type": "integer", "description": "Recorded in the balance of the previous assignee"}
+        }
+      },
+      "MemberBalance": {
+        "type": "object",
+        "required": ["member_name", "completed", "penalties", "balance"],
+        "properties": {
+          "member_name": {"type": "string"},
+          "completed": {"type": "integer"},
+          "penalties": {"type": "integer"},
+          "balance": {"type": "integer", "description": "Completed tasks minus the penalties"}
+        }
+      },
+      "Interval": {
+        "type": "object",
+        "required": ["unit", "amount"],
+        "properties": {
+          "unit": {"type": "string", "enum": ["days", "weeks", "months", "years"]},
+          "amount": {"type": "integer", "format": "int32", "minimum": 0}
+        }
+      },
+      "Task": {
+        "type": "object",
+        "required": ["id", "name", "interval", "group_id", "assignee_name", "due_date"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "interval": {"$ref": "#/components/schemas/Interval"},
+          "last_execution": {"$ref": "#/components/schemas/TaskExecution"},
+          "group_id": {"type": "string"},
+          "group": {"$ref": "#/components/schemas/Group"},
+          "assignee": {"$ref": "#/components/schemas/User"},
+          "assignee_name": {"type": "string", "description": "Empty if anyone can take the task"},
+          "due_date": {"type": "string", "format": "date-time"},
+          "queue": {"type": "array", "items": {"type": "string"}, "description": "Order of the turns of the members, the turn of the first member is the current one"}
+        }
+      },
+      "TaskRequest": {
+        "type": "object",
+        "required": ["name", "interval"],
+        "properties": {
+          "name": {"type": "string"},
+          "interval": {"$ref": "#/components/schemas/Interval"},
+          "assignee_name": {"type": "string", "description": "Member of the group, a random member if empty and the task isn't open"},
+          "open": {"type": "boolean", "description": "Creates the task without assignee, so that anyone can take it"}
+        }
+      },
+      "Event": {
+        "type": "object",
+        "required": ["id", "type", "time", "group_id"],
+        "properties": {
+          "id": {"type": "string"},
+          "type": {
+            "type": "string",
+            "enum": ["task.created", "task.updated", "task.completed", "task.deleted", "task.due_soon", "task.overdue", "task.escalated", "task.claimed", "group.member_joined", "group.member_left", "group.deleted", "ping"],
+            "description": "ping is only sent to webhooks by the test endpoint"
+          },
+          "time": {"type": "string", "format": "date-time"},
+          "group_id": {"type": "string"},
+          "actor": {"type": "string", "description": "User who caused the event"},
+          "task": {"$ref": "#/components/schemas/Task"},
+          "execution": {"$ref": "#/components/schemas/TaskExecution"},
+          "group": {"$ref": "#/components/schemas/Group"},
+          "member_name": {"type": "string", "description": "User who joined or left the group"},
+          "history": {"$ref": "#/components/schemas/TaskHistoryEntry", "description": "Set for escalations and claims"}
+        }
+      },
+      "EventType": {
+        "type": "string",
+        "enum": ["task.created", "task.updated", "task.completed", "task.deleted", "task.due_soon", "task.overdue", "task.escalated", "task.claimed", "group.member_joined", "group.member_left", "group.deleted"]
+      },
+      "Webhook": {
+        "type": "object",
+        "required": ["id", "group_id", "url", "events", "created_at"],
+        "properties": {
+          "id": {"type": "string"},
+          "group_id": {"type": "string"},
+          "url": {"type": "string", "format": "uri"},
+          "events": {"type": "array", "items": {"$ref": "#/components/schemas/EventType"}, "description": "Subscribed events, all if empty"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "secret": {"type": "string", "description": "Only returned when the webhook is created"}
+        }
+      },
+      "WebhookRequest": {
+        "type": "object",
+        "required": ["url"],
+        "properties": {
+          "url": {"type": "string", "format": "uri"},
+          "secret": {"type": "string", "description": "Key of the request signatures, generated if empty"},
+          "events": {"type": "array", "items": {"$ref": "#/components/schemas/EventType"}}
+        }
+      },
+      "WebhookDelivery": {
+        "type": "object",
+        "required": ["id", "webhook_id", "event_id", "event_type", "status", "attempts", "created_at"],
+        "properties": {
+          "id": {"type": "string", "description": "Sent in the X-Ruck-Delivery header"},
+          "webhook_id": {"type": "string"},
+          "event_id": {"type": "string"},
+          "event_type": {"type": "string"},
+          "status": {"type": "string", "enum": ["pending", "delivered", "failed"]},
+          "attempts": {"type": "integer"},
+          "response_status": {"type": "integer", "description": "HTTP status of the last attempt"},
+          "error": {"type": "string"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "last_attempt": {"type": "string", "format": "date-time"},
+          "next_attempt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "TaskExecution": {
+        "type": "object",
+        "required": ["executor_name", "time", "task_id"],
+        "properties": {
+          "executor_name": {"type": "string", "description": "Name of the user who completed the task"},
+          "executor": {"$ref": "#/components/schemas/User"},
+          "time": {"type": "string", "format": "date-time"},
+          "task_id": {"type": "string"},
+          "task": {"$ref": "#/components/schemas/Task"}
+        }
+      }
+    }
+  },
+  "security": [{"bearer": []}],
+  "paths": {
+    "/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "security": [],
+        "responses": {"200": {"description": "OpenAPI document", "content": {"application/json": {}}}}
+      }
+    },
+    "/.well-known/jwks.json": {
+      "get": {
+        "summary": "Public keys to verify the session tokens",
+        "security": [],
+        "responses": {"200": {"description": "JSON Web Key Set", "content": {"application/json": {}}}}
+      }
+    },
+    "/api/v1/version": {
+      "get": {
+        "summary": "Version and enabled features of the server",
+        "security": [],
+        "responses": {
+          "200": {"description": "Server information", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ServerInfo"}}}}
+        }
+      }
+    },
+    "/api/v1/login": {
+      "post": {
+        "summary": "Log in with name and password",
+        "security": [],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LoginRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/AuthenticationResult"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/login/2fa": {
+      "post": {
+        "summary": "Complete a login with a two-factor code",
+        "security": [],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TwoFactorLoginRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/AuthenticationResult"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/register": {
+      "post": {
+        "summary": "Create a user",
+        "security": [],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RegistrationRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/User"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/verify-email": {
+      "get": {
+        "summary": "Page to confirm the verification link of the mail",
+        "security": [],
+        "parameters": [{"name": "token", "in": "query", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "HTML form", "content": {"text/html": {}}}}
+      }
+    },
+    "/api/v1/verify-email": {
+      "post": {
+        "summary": "Verify the email address",
+        "security": [],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {"schema": {"$ref": "#/components/schemas/VerificationRequest"}},
+            "application/x-www-form-urlencoded": {"schema": {"$ref": "#/components/schemas/VerificationRequest"}}
+          }
+        },
+        "responses": {
+          "200": {"$ref": "#/components/responses/User"},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/verify-email/resend": {
+      "post": {
+        "summary": "Send the verification mail again",
+        "security": [],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LoginRequest"}}}},
+        "responses": {
+          "202": {"description": "Mail sent"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/password/forgot": {
+      "post": {
+        "summary": "Send a password reset mail",
+        "security": [],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasswordForgottenRequest"}}}},
+        "responses": {
+          "202": {"description": "Accepted, also if the user doesn't exist or has no verified email address"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/password/reset": {
+      "post": {
+        "summary": "Set a new password with the token of the reset mail",
+        "security": [],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasswordResetRequest"}}}},
+        "responses": {
+          "200": {"description": "Password changed"},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/token/refresh": {
+      "post": {
+        "summary": "Exchange a refresh token for new tokens",
+        "security": [],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RefreshRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/AuthenticationResult"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/sso/login": {
+      "get": {
+        "summary": "Start a single sign-on login",
+        "security": [],
+        "parameters": [
+          {"name": "redirect_uri", "in": "query", "required": true, "description": "Loopback address of the client", "schema": {"type": "string"}},
+          {"name": "state", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "code_challenge", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "code_challenge_method", "in": "query", "required": true, "schema": {"type": "string", "enum": ["S256"]}}
+        ],
+        "responses": {
+          "302": {"description": "Redirect to the identity provider"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/calendar/{token}.ics": {
+      "get": {
+        "summary": "Upcoming occurrences of the tasks of the groups of the user as iCalendar",
+        "security": [],
+        "parameters": [
+          {"name": "token", "in": "path", "required": true, "schema": {"type": "string"}},
+          {"name": "mine", "in": "query", "required": false, "schema": {"type": "boolean"}, "description": "Only the occurrences assigned to the user"}
+        ],
+        "responses": {
+          "200": {"description": "Calendar", "content": {"text/calendar": {}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/unsubscribe": {
+      "get": {
+        "summary": "Page to confirm the unsubscribe link of notification mails",
+        "security": [],
+        "parameters": [{"name": "token", "in": "query", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "HTML form", "content": {"text/html": {}}}}
+      },
+      "post": {
+        "summary": "Disable the notification of the unsubscribe link, also used for one-click unsubscribe (RFC 8058)",
+        "security": [],
+        "requestBody": {
+          "required": true,
+          "content": {"application/x-www-form-urlencoded": {"schema": {"type": "object", "properties": {"token": {"type": "string"}}, "required": ["token"]}}}
+        },
+        "responses": {
+          "200": {"description": "Confirmation page", "content": {"text/html": {}}},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sso/callback": {
+      "get": {
+        "summary": "Callback of the identity provider",
+        "security": [],
+        "responses": {
+          "302": {"description": "Redirect to the client with a one-time code"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/sso/token": {
+      "post": {
+        "summary": "Exchange the one-time code of a single sign-on login for tokens",
+        "security": [],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SSOTokenRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/AuthenticationResult"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "429": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/logout": {
+      "post": {
+        "summary": "Revoke the session token and its refresh tokens",
+        "responses": {
+          "204": {"description": "Logged out"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account": {
+      "get": {
+        "summary": "Profile of the user",
+        "responses": {
+          "200": {"$ref": "#/components/responses/User"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "summary": "Change the profile",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AccountUpdateRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/User"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Delete the account",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AccountDeletionRequest"}}}},
+        "responses": {
+          "204": {"description": "Deleted"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/export": {
+      "get": {
+        "summary": "All data stored about the user",
+        "responses": {
+          "200": {"description": "Export", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AccountExport"}}}},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/notifications": {
+      "get": {
+        "summary": "Notification mails the user receives",
+        "responses": {
+          "200": {"description": "Settings", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NotificationSettings"}}}},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "summary": "Change the notification mails the user receives",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NotificationSettingsUpdateRequest"}}}},
+        "responses": {
+          "200": {"description": "Settings", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NotificationSettings"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/push": {
+      "get": {
+        "summary": "Push notification settings of the user",
+        "responses": {
+          "200": {"description": "Settings", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PushSettings"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "summary": "Replace the push notification settings",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PushSettings"}}}},
+        "responses": {
+          "200": {"description": "Settings", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PushSettings"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Disable push notifications",
+        "responses": {
+          "204": {"description": "Deleted"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/push/test": {
+      "post": {
+        "summary": "Send a test message to the push service",
+        "responses": {
+          "204": {"description": "Accepted by the push service"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "502": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/calendar": {
+      "get": {
+        "summary": "URLs of the calendar feed",
+        "responses": {
+          "200": {"description": "Feed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CalendarFeed"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Create the calendar feed or replace its URLs",
+        "responses": {
+          "201": {"description": "Feed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CalendarFeed"}}}},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Disable the calendar feed",
+        "responses": {
+          "204": {"description": "Deleted"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/password": {
+      "post": {
+        "summary": "Change the password, other sessions are logged out",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PasswordChangeRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/AuthenticationResult"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/2fa": {
+      "post": {
+        "summary": "Start the enrollment of two-factor authentication",
+        "responses": {
+          "200": {"description": "Secret to add to an authenticator app", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TwoFactorEnrollment"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/2fa/confirm": {
+      "post": {
+        "summary": "Enable two-factor authentication with a code of the new secret",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TwoFactorCodeRequest"}}}},
+        "responses": {
+          "200": {"description": "Recovery codes", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RecoveryCodes"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/2fa/disable": {
+      "post": {
+        "summary": "Disable two-factor authentication",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TwoFactorCodeRequest"}}}},
+        "responses": {
+          "204": {"description": "Disabled"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/tokens": {
+      "get": {
+        "summary": "Personal access tokens of the user",
+        "responses": {
+          "200": {"description": "Tokens without the secret", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/PersonalAccessToken"}}}}},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Create a personal access token",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PersonalAccessTokenRequest"}}}},
+        "responses": {
+          "201": {"description": "Token including the secret", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PersonalAccessToken"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/account/tokens/{tokenId}": {
+      "delete": {
+        "summary": "Revoke a personal access token",
+        "parameters": [{"$ref": "#/components/parameters/tokenId"}],
+        "responses": {
+          "204": {"description": "Revoked"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/groups": {
+      "get": {
+        "summary": "Groups of the user",
+        "responses": {
+          "200": {"description": "Groups", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Group"}}}}},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Create a group with the user as only member",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GroupRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Group"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/groups/{groupId}": {
+      "parameters": [{"$ref": "#/components/parameters/groupId"}],
+      "get": {
+        "summary": "Group of the user",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Group"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "summary": "Change the fields of the group which are set",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GroupUpdateRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Group"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Delete a group",
+        "responses": {
+          "200": {"description": "Deleted"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/groups/{groupId}/join": {
+      "post": {
+        "summary": "Join a group",
+        "parameters": [{"$ref": "#/components/parameters/groupId"}],
+        "responses": {
+          "200": {"description": "Joined"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/groups/{groupId}/balance": {
+      "get": {
+        "summary": "Completed tasks and penalties of the members",
+        "parameters": [{"$ref": "#/components/parameters/groupId"}],
+        "responses": {
+          "200": {"description": "Balances", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/MemberBalance"}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/groups/{groupId}/tasks": {
+      "post": {
+        "summary": "Create a task in the group",
+        "parameters": [{"$ref": "#/components/parameters/groupId"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TaskRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Task"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/groups/{groupId}/webhooks": {
+      "parameters": [{"$ref": "#/components/parameters/groupId"}],
+      "get": {
+        "summary": "Webhooks of the group",
+        "responses": {
+          "200": {"description": "Webhooks without the secret", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Webhook"}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Send the events of the group to a URL",
+        "description": "Events are sent as POST requests with the Event as body. The X-Ruck-Signature header contains sha256= followed by the hex encoded HMAC-SHA256 of the X-Ruck-Timestamp header, a dot and the body. Failed deliveries are retried with exponential backoff.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/WebhookRequest"}}}},
+        "responses": {
+          "201": {"description": "Webhook including the secret", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Webhook"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/groups/{groupId}/webhooks/{webhookId}": {
+      "delete": {
+        "summary": "Delete a webhook with its delivery log",
+        "parameters": [{"$ref": "#/components/parameters/groupId"}, {"$ref": "#/components/parameters/webhookId"}],
+        "responses": {
+          "204": {"description": "Deleted"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/groups/{groupId}/webhooks/{webhookId}/deliveries": {
+      "get": {
+        "summary": "Latest deliveries of the webhook, the newest first",
+        "parameters": [{"$ref": "#/components/parameters/groupId"}, {"$ref": "#/components/parameters/webhookId"}],
+        "responses": {
+          "200": {"description": "Deliveries", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/WebhookDelivery"}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/groups/{groupId}/webhooks/{webhookId}/test": {
+      "post": {
+        "summary": "Send a ping event to the webhook once",
+        "parameters": [{"$ref": "#/components/parameters/groupId"}, {"$ref": "#/components/parameters/webhookId"}],
+        "responses": {
+          "200": {"description": "Result of the delivery", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/WebhookDelivery"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/tasks": {
+      "get": {
+        "summary": "Tasks of all groups of the user",
+        "parameters": [{"name": "open", "in": "query", "required": false, "description": "Only the tasks without assignee which anyone can claim", "schema": {"type": "boolean"}}],
+        "responses": {
+          "200": {"description": "Tasks", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Task"}}}}},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/tasks/{taskId}": {
+      "get": {
+        "summary": "Task with its group",
+        "parameters": [{"$ref": "#/components/parameters/taskId"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Task"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/tasks/{taskId}/complete": {
+      "post": {
+        "summary": "Complete the task and assign it to the next member",
+        "description": "The executor moves to the end of the queue. If it was another member's turn, the cover policy of the group decides about it.",
+        "parameters": [{"$ref": "#/components/parameters/taskId"}],
+        "responses": {
+          "200": {"description": "Execution", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TaskExecution"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/tasks/{taskId}/claim": {
+      "post": {
+        "summary": "Assign the task to the user",
+        "description": "Tasks without assignee can always be claimed, other tasks as allowed by the claim policy of the group. The rotation continues after the user.",
+        "parameters": [{"$ref": "#/components/parameters/taskId"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Task"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/tasks/{taskId}/history": {
+      "get": {
+        "summary": "Latest completions, reassignments and escalations of the task, the newest first",
+        "parameters": [{"$ref": "#/components/parameters/taskId"}],
+        "responses": {
+          "200": {"description": "History", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/TaskHistoryEntry"}}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/events": {
+      "get": {
+        "summary": "Stream of the events of the groups of the user",
+        "description": "Server-sent events with the type as event name and an Event as data. The stream ends when the token expires.",
+        "responses": {
+          "200": {"description": "Event stream", "content": {"text/event-stream": {"schema": {"$ref": "#/components/schemas/Event"}}}},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  }
+}`)
+
+func mustCompactJson(document string) []byte {
+	var buffer bytes.Buffer
+	if err := json.Compact(&buffer, []byte(document)); err != nil {
+		panic("invalid JSON document: " + err.Error())
+	}
+	return buffer.Bytes()
+}
+
+// GetOpenAPIDocument returns the OpenAPI description of the API.
+func GetOpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	setJsonHeaders(w)
+	if _, err := w.Write(openAPIDocument); err != nil {
+		log.Printf("Failed to write OpenAPI document: %s\n", err)
+	}
+}
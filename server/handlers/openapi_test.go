@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+)
+
+// openAPISchema is the subset of the schema objects used by the document.
+type openAPISchema struct {
+	Ref         string                    `json:"$ref"`
+	Type        string                    `json:"type"`
+	Format      string                    `json:"format"`
+	Enum        []interface{}             `json:"enum"`
+	Required    []string                  `json:"required"`
+	Properties  map[string]*openAPISchema `json:"properties"`
+	Items       *openAPISchema            `json:"items"`
+	Minimum     *float64                  `json:"minimum"`
+	Maximum     *float64                  `json:"maximum"`
+	Description string                    `json:"description"`
+}
+
+func openAPISchemas(t *testing.T) map[string]*openAPISchema {
+	t.Helper()
+	var document struct {
+		Components struct {
+			Schemas map[string]*openAPISchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPIDocument, &document); err != nil {
+		t.Fatal(err)
+	}
+	return document.Components.Schemas
+}
+
+// validateSchema reports every difference of the decoded JSON value to the schema. Objects must not
+// contain undocumented properties.
+func validateSchema(t *testing.T, schemas map[string]*openAPISchema, path string, schema *openAPISchema, value interface{}) {
+	t.Helper()
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, ok := schemas[name]
+		if !ok {
+			t.Fatalf("%s: unknown schema %s", path, schema.Ref)
+		}
+		validateSchema(t, schemas, path, resolved, value)
+		return
+	}
+	if value == nil {
+		t.Errorf("%s: null isn't allowed", path)
+		return
+	}
+	if schema.Enum != nil && !containsValue(schema.Enum, value) {
+		t.Errorf("%s: %v isn't one of %v", path, value, schema.Enum)
+	}
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: expected object, got %T", path, value)
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				t.Errorf("%s: required property %s missing", path, name)
+			}
+		}
+		for name, property := range object {
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				t.Errorf("%s: undocumented property %s", path, name)
+				continue
+			}
+			validateSchema(t, schemas, path+"."+name, propertySchema, property)
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			t.Errorf("%s: expected array, got %T", path, value)
+			return
+		}
+		for i, item := range array {
+			validateSchema(t, schemas, fmt.Sprintf("%s[%d]", path, i), schema.Items, item)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			t.Errorf("%s: expected string, got %T", path, value)
+			return
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				t.Errorf("%s: %s", path, err)
+			}
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			t.Errorf("%s: expected integer, got %v", path, value)
+			return
+		}
+		if (schema.Minimum != nil && number < *schema.Minimum) || (schema.Maximum != nil && number > *schema.Maximum) {
+			t.Errorf("%s: %v out of range", path, number)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: expected boolean, got %T", path, value)
+		}
+	default:
+		t.Fatalf("%s: unsupported schema type %q", path, schema.Type)
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateResponse checks that the body of the response is JSON described by the schema.
+func validateResponse(t *testing.T, recorder *httptest.ResponseRecorder, statusCode int, schemaName string) {
+	t.Helper()
+	if recorder.Code != statusCode {
+		t.Errorf("expected status %d, got %d", statusCode, recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+	var body interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	schemas := openAPISchemas(t)
+	validateSchema(t, schemas, schemaName, &openAPISchema{Ref: "#/components/schemas/" + schemaName}, body)
+}
+
+// newTestTask returns a task with all optional fields set.
+func newTestTask() *ruck.Task {
+	now := time.Date(2021, 3, 1, 9, 30, 0, 0, time.UTC)
+	group := &ruck.Group{
+		ID:               "group-1",
+		Name:             "flat",
+		MemberNames:      []string{"alice", "bob"},
+		ReminderLeadTime: time.Hour,
+		Escalation: ruck.EscalationPolicy{
+			NotifyGroupAfter: time.Hour,
+			ActAfter:         24 * time.Hour,
+			Action:           ruck.EscalationReassign,
+			Penalty:          1,
+		},
+		ClaimPolicy: ruck.ClaimAny,
+		CoverPolicy: ruck.CoverSwapTurns,
+	}
+	alice := &ruck.User{Name: "alice", EmailAddress: "alice@example.com", EmailVerified: true, DisplayName: "Alice",
+		TimeZone: "Europe/Zurich", Language: "de-CH", PasswordHash: []byte("hash")}
+	task := &ruck.Task{
+		ID:           "task-1",
+		Name:         "Dishes",
+		Interval:     ruck.Interval{Unit: ruck.Weeks, Amount: 1},
+		GroupID:      group.ID,
+		Group:        group,
+		Assignee:     alice,
+		AssigneeName: alice.Name,
+		DueDate:      now.Add(7 * 24 * time.Hour),
+		Queue:        []string{"alice", "bob"},
+	}
+	task.LastExecution = &ruck.TaskExecution{
+		ExecutorName: "bob",
+		Executor:     &ruck.User{Name: "bob"},
+		Time:         now,
+		TaskId:       task.ID,
+	}
+	return task
+}
+
+func TestServerInfoMatchesSchema(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	GetServerInfo(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/version", nil))
+	validateResponse(t, recorder, http.StatusOK, "ServerInfo")
+}
+
+func TestErrorResponsesMatchSchema(t *testing.T) {
+	tests := []struct {
+		handler    http.HandlerFunc
+		statusCode int
+	}{
+		{NotFound, http.StatusNotFound},
+		{MethodNotAllowed, http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		test.handler(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/unknown", nil))
+		validateResponse(t, recorder, test.statusCode, "ErrorResponse")
+	}
+}
+
+func TestModelsMatchSchema(t *testing.T) {
+	task := newTestTask()
+	open := newTestTask()
+	open.AssigneeName = ""
+	open.Assignee = nil
+	open.LastExecution = nil
+	open.Group = nil
+	execution := *task.LastExecution
+	execution.Task = task
+	tests := []struct {
+		schemaName string
+		value      interface{}
+	}{
+		{"Task", task},
+		{"Task", open},
+		{"Group", task.Group},
+		{"Group", &ruck.Group{ID: "group-2", Name: "empty", MemberNames: []string{"alice"}}},
+		{"TaskExecution", &execution},
+		{"User", task.Assignee},
+	}
+	for _, test := range tests {
+		t.Run(test.schemaName, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			writeResponse(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/", nil), test.value)
+			validateResponse(t, recorder, http.StatusOK, test.schemaName)
+		})
+	}
+}
+
+func TestOpenTaskRequestMatchesSchema(t *testing.T) {
+	body, err := json.Marshal(&api.TaskRequest{
+		Name:     "Dishes",
+		Interval: api.NewInterval(ruck.Interval{Unit: ruck.Weeks, Amount: 1}),
+		Open:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		t.Fatal(err)
+	}
+	validateSchema(t, openAPISchemas(t), "TaskRequest", &openAPISchema{Ref: "#/components/schemas/TaskRequest"}, value)
+
+	var request ruck.TaskRequest
+	if err := decodeRequest(httptest.NewRequest(http.MethodPost, "/api/v1/groups/group-1/tasks", strings.NewReader(string(body))), &request); err != nil {
+		t.Fatal(err)
+	}
+	if !request.Open || request.AssigneeName != "" || request.Interval.Unit != ruck.Weeks {
+		t.Errorf("unexpected request %+v", request)
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/mail"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	"time"
+)
+
+const passwordResetValidity = time.Hour
+
+var (
+	passwordResetLimiter = &intervalLimiter{Interval: 10 * time.Minute}
+
+	HttpErrInvalidResetToken = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidResetToken, "Invalid or expired reset token")
+	HttpErrWrongPassword     = NewErrorType(http.StatusForbidden, ruck.ErrorCodeWrongPassword, "Current password is wrong")
+	ErrInvalidResetToken     = errors.New("invalid or expired reset token")
+)
+
+const passwordResetMailText = `Hello %s
+
+Somebody requested to reset the password of your ruck account.
+To set a new password run:
+
+    ruck account reset-password %s
+
+The token is valid for %d minutes and can only be used once. If you did not request
+a new password, you can ignore this mail.
+`
+
+type passwordResetToken struct {
+	Hash     string    `bson:"hash"`
+	UserName string    `bson:"username"`
+	Expiry   time.Time `bson:"expiry"`
+}
+
+func generateSecretToken() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
+
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatePasswordResetToken creates a single-use token which allows to set a new password for the user.
+// Only the hash of the token is stored.
+func CreatePasswordResetToken(ctx context.Context, userName string) (string, error) {
+	if _, err := getUserForName(ctx, userName); err != nil {
+		return "", err
+	}
+	token, err := generateSecretToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = passwordResetCollection.InsertOne(ctx, &passwordResetToken{
+		Hash:     hashSecretToken(token),
+		UserName: userName,
+		Expiry:   time.Now().Add(passwordResetValidity),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store reset token: %s", err)
+	}
+	return token, nil
+}
+
+// consumePasswordResetToken deletes the token and returns the name of the user it was issued for.
+func consumePasswordResetToken(ctx context.Context, token string) (string, error) {
+	var resetToken passwordResetToken
+	err := passwordResetCollection.FindOneAndDelete(ctx, bson.M{
+		"hash": hashSecretToken(token),
+	}).Decode(&resetToken)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			err = ErrInvalidResetToken
+		}
+		return "", err
+	}
+	if time.Now().After(resetToken.Expiry) {
+		return "", ErrInvalidResetToken
+	}
+	return resetToken.UserName, nil
+}
+
+// setPassword stores the new password and invalidates all reset and access tokens of the user.
+func setPassword(ctx context.Context, userName string, password []byte) error {
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	result, err := usersCollection.UpdateOne(ctx, bson.M{userFieldName: userName}, bson.M{
+		"$set": bson.M{
+			"passwordhash":      hashed,
+			"passwordchangedat": time.Now(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNoSucUser
+	}
+	_, err = passwordResetCollection.DeleteMany(ctx, bson.M{"username": userName})
+	if err != nil {
+		return err
+	}
+	err = deleteRefreshTokensOfUser(ctx, userName)
+	if err != nil {
+		return err
+	}
+	err = resetLoginFailures(ctx, userName, passwordStep)
+	if err == nil {
+		log.Printf("Changed password of user %s\n", userName)
+	}
+	return err
+}
+
+func sendPasswordResetMail(ctx context.Context, user *ruck.User) error {
+	token, err := CreatePasswordResetToken(ctx, user.Name)
+	if err != nil {
+		return err
+	}
+	return UsedMailer.Send(newPasswordResetMail(user, token))
+}
+
+func newPasswordResetMail(user *ruck.User, token string) *mail.Message {
+	return &mail.Message{
+		To:      user.EmailAddress,
+		Subject: "Reset your password",
+		Text:    fmt.Sprintf(passwordResetMailText, user.Name, token, int(passwordResetValidity.Minutes())),
+	}
+}
+
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.PasswordChangeRequest
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	if !bytes.Equal(request.Password, request.PasswordConfirmation) {
+		HttpErrPasswordsDontMatch.CauseString("Password comparasion failed").Write(w, r)
+		return
+	}
+	user, err := authenticateUser(ctx, &ruck.Credentials{Name: userName, Password: request.CurrentPassword})
+	if err != nil {
+		if err == ErrNoSucUser {
+			HttpErrWrongPassword.Cause(err).Write(w, r)
+		} else {
+			writeAuthenticationError(w, r, err)
+		}
+		return
+	}
+	if err := setPassword(ctx, userName, request.Password); err != nil {
+		HttpErrInternal.Causef("Failed to change password: %s", err).Write(w, r)
+		return
+	}
+
+	// the old token is invalid now, so a new one is returned
+	user.PasswordHash = nil
+	result, err := issueAuthenticationResult(ctx, user)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, result)
+}
+
+// ForgotPassword mails a reset token to the verified address of the user. The response is the same
+// whether the user exists, has a verified address or the mail could be sent, so that it doesn't
+// reveal the accounts.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.PasswordForgottenRequest
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	if UsedMailer == nil {
+		HttpErrMailNotConfigured.Causef("%s requested password reset", request.Name).Write(w, r)
+		return
+	}
+	if !passwordResetLimiter.Allow(request.Name) {
+		HttpErrTooManyRequests.Causef("password reset for %s requested recently", request.Name).Write(w, r)
+		return
+	}
+	user, err := getUserForName(ctx, request.Name)
+	switch {
+	case err == ErrNoSucUser:
+		log.Printf("Password reset requested for unknown user %s\n", request.Name)
+	case err != nil:
+		log.Printf("Failed to load user %s for password reset: %s\n", request.Name, err)
+	case user.EmailAddress == "" || !user.EmailVerified:
+		log.Printf("Password reset requested for user %s without verified email address\n", user.Name)
+	default:
+		if err := sendPasswordResetMail(ctx, user); err != nil {
+			log.Printf("Failed to send password reset mail to user %s: %s\n", user.Name, err)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.PasswordResetRequest
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	if !bytes.Equal(request.Password, request.PasswordConfirmation) {
+		HttpErrPasswordsDontMatch.CauseString("Password comparasion failed").Write(w, r)
+		return
+	}
+	userName, err := consumePasswordResetToken(ctx, request.Token)
+	if err != nil {
+		if err == ErrInvalidResetToken {
+			HttpErrInvalidResetToken.Cause(err).Write(w, r)
+		} else {
+			HttpErrInternal.Cause(err).Write(w, r)
+		}
+		return
+	}
+	if err := setPassword(ctx, userName, request.Password); err != nil {
+		HttpErrInternal.Causef("Failed to reset password: %s", err).Write(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/notify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	maxPushURLLength   = 2000
+	maxPushTokenLength = 500
+)
+
+var (
+	HttpErrPushNotConfigured  = NewErrorType(http.StatusNotFound, ruck.ErrorCodePushNotConfigured, "Push notifications not configured")
+	HttpErrInvalidPushService = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidPushService, "Invalid push service")
+	HttpErrInvalidPushURL     = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidPushURL, "Invalid push URL")
+	HttpErrInvalidPushToken   = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidPushToken, "Invalid push token")
+	HttpErrPushFailed         = NewErrorType(http.StatusBadGateway, ruck.ErrorCodePushFailed, "Push service failed")
+	ErrPushNotConfigured      = errors.New("push notifications not configured")
+
+	// pushClient sends the push messages. Redirects aren't followed like for webhooks.
+	pushClient = &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// pushSettingsModel is the stored form of the push settings of a user.
+type pushSettingsModel struct {
+	UserName string            `bson:"username"`
+	Settings ruck.PushSettings `bson:",inline"`
+}
+
+// validatePushSettings checks the settings and returns the error to send to the client.
+func validatePushSettings(settings *ruck.PushSettings) *Error {
+	if !settings.Service.IsValid() {
+		return HttpErrInvalidPushService.Causef("invalid push service '%s'", settings.Service)
+	}
+	if len(settings.URL) > maxPushURLLength {
+		return HttpErrInvalidPushURL.CauseString("URL too long")
+	}
+	pushURL, err := url.Parse(settings.URL)
+	if err != nil {
+		return HttpErrInvalidPushURL.Cause(err)
+	}
+	if (pushURL.Scheme != "http" && pushURL.Scheme != "https") || pushURL.Host == "" {
+		return HttpErrInvalidPushURL.Causef("invalid push URL '%s'", settings.URL)
+	}
+	if len(settings.Token) > maxPushTokenLength {
+		return HttpErrInvalidPushToken.CauseString("token too long")
+	}
+	if settings.Service == ruck.PushServiceGotify && settings.Token == "" {
+		return HttpErrInvalidPushToken.CauseString("Gotify requires an application token")
+	}
+	return nil
+}
+
+// getPushSettings returns the settings including the token or ErrPushNotConfigured.
+func getPushSettings(ctx context.Context, userName string) (*ruck.PushSettings, error) {
+	var model pushSettingsModel
+	err := pushSettingsCollection.FindOne(ctx, bson.M{"username": userName}).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPushNotConfigured
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model.Settings, nil
+}
+
+func setPushSettings(ctx context.Context, userName string, settings *ruck.PushSettings) error {
+	_, err := pushSettingsCollection.ReplaceOne(ctx, bson.M{"username": userName}, &pushSettingsModel{
+		UserName: userName,
+		Settings: *settings,
+	}, options.Replace().SetUpsert(true))
+	return err
+}
+
+func deletePushSettings(ctx context.Context, userName string) error {
+	result, err := pushSettingsCollection.DeleteOne(ctx, bson.M{"username": userName})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrPushNotConfigured
+	}
+	return nil
+}
+
+// withoutToken returns a copy of the settings which can be sent to the client.
+func withoutToken(settings *ruck.PushSettings) *ruck.PushSettings {
+	result := *settings
+	result.Token = ""
+	return &result
+}
+
+// newPushMessage describes the reminder for its recipient.
+func newPushMessage(kind notify.ReminderKind, task *ruck.Task, location *time.Location) *notify.PushMessage {
+	due := task.DueDate.In(location).Format(mailTimeFormat)
+	name := task.Name
+	if task.Group != nil {
+		name = fmt.Sprintf("%s (%s)", task.Name, task.Group.Name)
+	}
+	message := &notify.PushMessage{
+		Kind:     kind,
+		TaskID:   task.ID,
+		Priority: notify.PushPriorityDefault,
+	}
+	switch kind {
+	case notify.ReminderOverdue:
+		message.Title = fmt.Sprintf("%s is overdue", task.Name)
+		message.Message = fmt.Sprintf("%s was due %s.", name, due)
+		message.Priority = notify.PushPriorityHigh
+		message.Tags = []string{"warning"}
+	case notify.ReminderAssigned:
+		message.Title = fmt.Sprintf("Your turn: %s", task.Name)
+		message.Message = fmt.Sprintf("%s is assigned to you and due %s.", name, due)
+		message.Tags = []string{"point_right"}
+	case notify.ReminderEscalated:
+		message.Title = fmt.Sprintf("%s is still overdue", task.Name)
+		if task.AssigneeName == "" {
+			message.Message = fmt.Sprintf("%s was due %s, anyone can take it.", name, due)
+		} else {
+			message.Message = fmt.Sprintf("%s was due %s, it is assigned to %s.", name, due, task.AssigneeName)
+		}
+		message.Priority = notify.PushPriorityHigh
+		message.Tags = []string{"rotating_light"}
+	default:
+		message.Title = fmt.Sprintf("%s is due soon", task.Name)
+		message.Message = fmt.Sprintf("%s is due %s.", name, due)
+		message.Tags = []string{"alarm_clock"}
+	}
+	return message
+}
+
+// PushNotifier pushes reminders, assignments and escalations to the push services configured by
+// the recipients. Disabled users don't receive any.
+type PushNotifier struct{}
+
+func (PushNotifier) Notify(ctx context.Context, reminder *notify.Reminder) error {
+	user := reminder.Recipient
+	if user == nil || user.IsDisabled {
+		return nil
+	}
+	settings, err := getPushSettings(ctx, user.Name)
+	if err == ErrPushNotConfigured {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if reminder.Kind == notify.ReminderAssigned && !settings.Assignments ||
+		reminder.Kind != notify.ReminderAssigned && !settings.Reminders {
+		return nil
+	}
+	return notify.Push(ctx, pushClient, settings, newPushMessage(reminder.Kind, reminder.Task, userLocation(user)))
+}
+
+func GetPushSettings(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	settings, err := getPushSettings(r.Context(), userName)
+	switch err {
+	case ErrPushNotConfigured:
+		HttpErrPushNotConfigured.Cause(err).Write(w, r)
+	case nil:
+		writeResponse(w, r, withoutToken(settings))
+	default:
+		HttpErrInternal.Cause(err).Write(w, r)
+	}
+}
+
+// SetPushSettings replaces the push settings of the user.
+func SetPushSettings(w http.ResponseWriter, r *http.Request) {
+	var settings ruck.PushSettings
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := decodeRequest(r, &settings); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	if httpErr := validatePushSettings(&settings); httpErr != nil {
+		httpErr.Write(w, r)
+		return
+	}
+	if err := setPushSettings(r.Context(), userName, &settings); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, withoutToken(&settings))
+}
+
+func DeletePushSettings(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	err = deletePushSettings(r.Context(), userName)
+	switch err {
+	case ErrPushNotConfigured:
+		HttpErrPushNotConfigured.Cause(err).Write(w, r)
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		HttpErrInternal.Cause(err).Write(w, r)
+	}
+}
+
+// TestPush sends a test message to the push service of the user and reports whether it was accepted.
+func TestPush(w http.ResponseWriter, r *http.Request) {
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	settings, err := getPushSettings(r.Context(), userName)
+	switch err {
+	case ErrPushNotConfigured:
+		HttpErrPushNotConfigured.Cause(err).Write(w, r)
+		return
+	case nil:
+	default:
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	err = notify.Push(r.Context(), pushClient, settings, &notify.PushMessage{
+		Title:    "ruck",
+		Message:  "Push notifications are working.",
+		Priority: notify.PushPriorityDefault,
+		Tags:     []string{"white_check_mark"},
+		Kind:     "test",
+	})
+	if err != nil {
+		log.Printf("Test push of user %s failed: %s\n", userName, err)
+		HttpErrPushFailed.Cause(err).Write(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
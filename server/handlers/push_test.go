@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/notify"
+)
+
+func TestPushNotifierSkipsDisabledUsers(t *testing.T) {
+	// the settings aren't loaded, the test has no database
+	reminder := &notify.Reminder{
+		Kind:      notify.ReminderOverdue,
+		Task:      &ruck.Task{Name: "Dishes"},
+		Recipient: &ruck.User{Name: "alice", IsDisabled: true},
+	}
+	if err := (PushNotifier{}).Notify(context.Background(), reminder); err != nil {
+		t.Error(err)
+	}
+}
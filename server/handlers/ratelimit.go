@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// intervalLimiter allows one event per key in the given interval.
+type intervalLimiter struct {
+	Interval time.Duration
+	mutex    sync.Mutex
+	last     map[string]time.Time
+}
+
+// Allow returns true and records the event if the last event for the key is long enough ago.
+func (l *intervalLimiter) Allow(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	if l.last == nil {
+		l.last = make(map[string]time.Time)
+	}
+	if last, ok := l.last[key]; ok && now.Sub(last) < l.Interval {
+		return false
+	}
+	l.last[key] = now
+	// forget old entries to keep the map small
+	for k, t := range l.last {
+		if now.Sub(t) >= l.Interval {
+			delete(l.last, k)
+		}
+	}
+	return true
+}
+
+// RateLimiter limits the requests per client IP address with a token bucket.
+type RateLimiter struct {
+	RequestsPerMinute int
+	mutex             sync.Mutex
+	buckets           map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+const maxRateLimiterBuckets = 10000
+
+func NewRateLimiter(requestsPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		RequestsPerMinute: requestsPerMinute,
+		buckets:           make(map[string]*tokenBucket),
+	}
+}
+
+func (l *RateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	burst := float64(l.RequestsPerMinute)
+	bucket.tokens += now.Sub(bucket.last).Minutes() * burst
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.last = now
+}
+
+// Allow takes a token from the bucket of the key and returns false if the bucket is empty.
+func (l *RateLimiter) Allow(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	if len(l.buckets) >= maxRateLimiterBuckets {
+		// forget buckets which are full again
+		for k, bucket := range l.buckets {
+			l.refill(bucket, now)
+			if bucket.tokens >= float64(l.RequestsPerMinute) {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.RequestsPerMinute), last: now}
+		l.buckets[key] = bucket
+	}
+	l.refill(bucket, now)
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// MiddleWare rejects requests of clients which exceeded the limit.
+// The limiter is disabled if RequestsPerMinute is not positive.
+func (l *RateLimiter) MiddleWare(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		address := clientAddress(r)
+		if l.RequestsPerMinute > 0 && !l.Allow(address) {
+			w.Header().Set("Retry-After", "60")
+			HttpErrTooManyRequests.Causef("rate limit exceeded by %s for %s", address, r.URL.Path).Write(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
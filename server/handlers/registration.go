@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+// RegistrationMode defines who can use the registration endpoint.
+type RegistrationMode string
+
+const (
+	// RegistrationOpen allows everyone to register.
+	RegistrationOpen RegistrationMode = "open"
+	// RegistrationInviteOnly requires a registration code or the ID of an existing group.
+	RegistrationInviteOnly RegistrationMode = "invite"
+	// RegistrationClosed only allows administrators to create users.
+	RegistrationClosed RegistrationMode = "closed"
+
+	DefaultRegistrationCodeValidity = 7 * 24 * time.Hour
+)
+
+var (
+	UsedRegistrationMode = RegistrationOpen
+
+	HttpErrRegistrationClosed  = NewErrorType(http.StatusForbidden, ruck.ErrorCodeRegistrationClosed, "Registration is closed")
+	HttpErrInvitationRequired  = NewErrorType(http.StatusForbidden, ruck.ErrorCodeInvitationRequired, "Registration requires a valid registration code or group invitation")
+	ErrInvalidRegistrationCode = errors.New("invalid registration code")
+	ErrInvalidRegistrationMode = errors.New("invalid registration mode")
+	ErrNoSuchRegistrationCode  = errors.New("no such registration code")
+)
+
+// ParseRegistrationMode converts the configured value to a RegistrationMode.
+func ParseRegistrationMode(value string) (RegistrationMode, error) {
+	switch mode := RegistrationMode(value); mode {
+	case RegistrationOpen, RegistrationInviteOnly, RegistrationClosed:
+		return mode, nil
+	case "":
+		return RegistrationOpen, nil
+	default:
+		return "", fmt.Errorf("%w: %q (use open, invite or closed)", ErrInvalidRegistrationMode, value)
+	}
+}
+
+// RegistrationCode allows to register if the registration is invite-only.
+type RegistrationCode struct {
+	ID            string    `bson:"id"`
+	Hash          string    `bson:"hash"`
+	CreatedAt     time.Time `bson:"createdat"`
+	Expiry        time.Time `bson:"expiry"`
+	RemainingUses int       `bson:"remaininguses"`
+}
+
+// CreateRegistrationCode returns a new registration code which can be used the given number of times.
+func CreateRegistrationCode(ctx context.Context, validity time.Duration, uses int) (string, *RegistrationCode, error) {
+	code, err := generateSecretToken()
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	model := &RegistrationCode{
+		ID:            generateId(),
+		Hash:          hashSecretToken(code),
+		CreatedAt:     now,
+		Expiry:        now.Add(validity),
+		RemainingUses: uses,
+	}
+	if _, err := registrationCodeCollection.InsertOne(ctx, model); err != nil {
+		return "", nil, err
+	}
+	log.Printf("Created registration code %s\n", model.ID)
+	return code, model, nil
+}
+
+// ListRegistrationCodes returns all registration codes which are not expired.
+func ListRegistrationCodes(ctx context.Context) ([]*RegistrationCode, error) {
+	cursor, err := registrationCodeCollection.Find(ctx, bson.M{
+		"expiry": bson.M{"$gt": time.Now()},
+	}, options.Find().SetSort(bson.M{"createdat": 1}))
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]*RegistrationCode, 0)
+	if err := cursor.All(ctx, &codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RevokeRegistrationCode deletes the registration code with the ID.
+func RevokeRegistrationCode(ctx context.Context, id string) error {
+	result, err := registrationCodeCollection.DeleteOne(ctx, bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNoSuchRegistrationCode
+	}
+	log.Printf("Revoked registration code %s\n", id)
+	return nil
+}
+
+// useRegistrationCode decrements the remaining uses of the code and returns its ID.
+func useRegistrationCode(ctx context.Context, code string) (string, error) {
+	var model RegistrationCode
+	err := registrationCodeCollection.FindOneAndUpdate(ctx, bson.M{
+		"hash":          hashSecretToken(code),
+		"expiry":        bson.M{"$gt": time.Now()},
+		"remaininguses": bson.M{"$gt": 0},
+	}, bson.M{
+		"$inc": bson.M{"remaininguses": -1},
+	}).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrInvalidRegistrationCode
+	}
+	if err != nil {
+		return "", err
+	}
+	return model.ID, nil
+}
+
+// restoreRegistrationCode gives back a use of the code if the registration failed.
+func restoreRegistrationCode(ctx context.Context, id string) {
+	_, err := registrationCodeCollection.UpdateOne(ctx, bson.M{"id": id}, bson.M{
+		"$inc": bson.M{"remaininguses": 1},
+	})
+	if err != nil {
+		log.Printf("Failed to restore registration code %s: %s\n", id, err)
+	}
+}
+
+func groupExists(ctx context.Context, groupId string) (bool, error) {
+	count, err := groupsCollection.CountDocuments(ctx, bson.M{"id": groupId})
+	return count != 0, err
+}
+
+// checkRegistrationAllowed enforces the registration mode. If a registration code was used, its ID is
+// returned so it can be restored if the registration fails.
+func checkRegistrationAllowed(w http.ResponseWriter, r *http.Request, request *ruck.RegistrationRequest) (codeId string, ok bool) {
+	var ctx = r.Context()
+	switch UsedRegistrationMode {
+	case RegistrationOpen:
+		return "", true
+	case RegistrationInviteOnly:
+		if request.GroupID != "" {
+			exists, err := groupExists(ctx, request.GroupID)
+			if err != nil {
+				HttpErrInternal.Cause(err).Write(w, r)
+				return "", false
+			}
+			if exists {
+				return "", true
+			}
+		}
+		if request.RegistrationCode != "" {
+			codeId, err := useRegistrationCode(ctx, request.RegistrationCode)
+			if err == nil {
+				return codeId, true
+			}
+			if err != ErrInvalidRegistrationCode {
+				HttpErrInternal.Cause(err).Write(w, r)
+				return "", false
+			}
+		}
+		HttpErrInvitationRequired.Causef("%s has no valid invitation", request.Name).Write(w, r)
+		return "", false
+	default:
+		HttpErrRegistrationClosed.Causef("%s tried to register", request.Name).Write(w, r)
+		return "", false
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"github.com/coffeemakr/ruck/server/notify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+const (
+	DefaultReminderInterval = 5 * time.Minute
+	DefaultReminderLeadTime = 24 * time.Hour
+	// MaxReminderLeadTime limits the lead time of the groups.
+	MaxReminderLeadTime = 7 * 24 * time.Hour
+	// reminderRetention is the time after the due date for which sent reminders are remembered.
+	reminderRetention = 365 * 24 * time.Hour
+)
+
+var (
+	// UsedNotifier delivers reminders in addition to the event streams and webhooks. It is disabled if
+	// it is nil.
+	UsedNotifier notify.Notifier
+	// ReminderLeadTime is used for groups without their own lead time.
+	ReminderLeadTime = DefaultReminderLeadTime
+)
+
+func reminderLeadTime(group *ruck.Group) time.Duration {
+	if group.ReminderLeadTime > 0 {
+		return group.ReminderLeadTime
+	}
+	return ReminderLeadTime
+}
+
+// reminderKindAt returns the reminder which is due for the task at the time, or an empty kind if
+// none is due. Only the overdue reminder is sent if the server missed the other one.
+func reminderKindAt(task *ruck.Task, now time.Time) notify.ReminderKind {
+	if !now.Before(task.DueDate) {
+		return notify.ReminderOverdue
+	}
+	if !now.Before(task.DueDate.Add(-reminderLeadTime(task.Group))) {
+		return notify.ReminderDueSoon
+	}
+	return ""
+}
+
+// getTasksDueBefore returns the tasks including their group which are due before the deadline.
+func getTasksDueBefore(ctx context.Context, deadline time.Time) ([]*ruck.Task, error) {
+	match := bson.D{{Key: "$match", Value: bson.M{"duedate": bson.M{"$lte": deadline}}}}
+	cursor, err := taskCollection.Aggregate(ctx, mongo.Pipeline{match, moveToTasks, lookupGroupForTask})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var tasks []*ruck.Task
+	for cursor.Next(ctx) {
+		var model taskWithGroupModel
+		if err := cursor.Decode(&model); err != nil {
+			return nil, err
+		}
+		if len(model.Groups) == 0 {
+			continue
+		}
+		task := model.Task
+		task.Group = model.Groups[0]
+		tasks = append(tasks, &task)
+	}
+	return tasks, cursor.Err()
+}
+
+// claimReminder records the reminder and returns false if it has already been sent. The records
+// prevent duplicate reminders after a restart of the server.
+func claimReminder(ctx context.Context, task *ruck.Task, kind notify.ReminderKind) (bool, error) {
+	filter := bson.M{"taskid": task.ID, "kind": kind, "duedate": task.DueDate}
+	result, err := reminderCollection.UpdateOne(ctx, filter, bson.M{
+		"$setOnInsert": bson.M{"sentat": time.Now()},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount == 1, nil
+}
+
+func sendReminder(ctx context.Context, kind notify.ReminderKind, task *ruck.Task) {
+	eventType := api.EventTaskDueSoon
+	if kind == notify.ReminderOverdue {
+		eventType = api.EventTaskOverdue
+	}
+	publishTaskEvent(eventType, task, task.Group, "")
+	notifyAssignee(ctx, kind, task)
+}
+
+// notifyAssignee loads the assignee of the task and sends the reminder to the notifier. Nobody is
+// notified about tasks without assignee or if the assignee is disabled.
+func notifyAssignee(ctx context.Context, kind notify.ReminderKind, task *ruck.Task) {
+	if UsedNotifier == nil || task.AssigneeName == "" {
+		return
+	}
+	assignee, err := getUserForName(ctx, task.AssigneeName)
+	if err != nil {
+		log.Printf("Failed to load assignee %s of task %s: %s\n", task.AssigneeName, task.ID, err)
+		return
+	}
+	if assignee.IsDisabled {
+		return
+	}
+	task.Assignee = assignee
+	if err := UsedNotifier.Notify(ctx, &notify.Reminder{Kind: kind, Task: task, Recipient: assignee}); err != nil {
+		log.Printf("Failed to send %s reminder for task %s: %s\n", kind, task.ID, err)
+	}
+}
+
+// sendDueReminders sends the reminders which became due since the last run.
+func sendDueReminders(ctx context.Context) {
+	now := time.Now()
+	maxLeadTime := MaxReminderLeadTime
+	if ReminderLeadTime > maxLeadTime {
+		maxLeadTime = ReminderLeadTime
+	}
+	tasks, err := getTasksDueBefore(ctx, now.Add(maxLeadTime))
+	if err != nil {
+		log.Printf("Failed to get due tasks: %s\n", err)
+		return
+	}
+	for _, task := range tasks {
+		kind := reminderKindAt(task, now)
+		if kind == "" {
+			continue
+		}
+		claimed, err := claimReminder(ctx, task, kind)
+		if err != nil {
+			log.Printf("Failed to record %s reminder for task %s: %s\n", kind, task.ID, err)
+			continue
+		}
+		if claimed {
+			sendReminder(ctx, kind, task)
+		}
+	}
+}
+
+// RunReminderScheduler sends reminders for due and overdue tasks, escalates overdue tasks and sends
+// the digests in the interval until the context is done.
+func RunReminderScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sendDueReminders(ctx)
+		escalateOverdueTasks(ctx)
+		sendDueDigests(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/notify"
+)
+
+func TestReminderKindAt(t *testing.T) {
+	due := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		leadTime time.Duration
+		now      time.Time
+		kind     notify.ReminderKind
+	}{
+		{"before the lead time", 0, due.Add(-DefaultReminderLeadTime - time.Second), ""},
+		{"start of the lead time", 0, due.Add(-DefaultReminderLeadTime), notify.ReminderDueSoon},
+		{"within the lead time", 0, due.Add(-time.Hour), notify.ReminderDueSoon},
+		{"just before the due date", 0, due.Add(-time.Nanosecond), notify.ReminderDueSoon},
+		{"at the due date", 0, due, notify.ReminderOverdue},
+		{"after the due date", 0, due.Add(30 * 24 * time.Hour), notify.ReminderOverdue},
+		{"before the lead time of the group", time.Hour, due.Add(-2 * time.Hour), ""},
+		{"within the lead time of the group", time.Hour, due.Add(-time.Hour), notify.ReminderDueSoon},
+		{"maximal lead time of the group", MaxReminderLeadTime, due.Add(-MaxReminderLeadTime), notify.ReminderDueSoon},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := &ruck.Task{DueDate: due, Group: &ruck.Group{ReminderLeadTime: test.leadTime}}
+			if kind := reminderKindAt(task, test.now); kind != test.kind {
+				t.Errorf("expected %q, got %q", test.kind, kind)
+			}
+		})
+	}
+}
+
+func TestReminderKindAtWithServerLeadTime(t *testing.T) {
+	previous := ReminderLeadTime
+	ReminderLeadTime = 2 * time.Hour
+	defer func() {
+		ReminderLeadTime = previous
+	}()
+	due := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	task := &ruck.Task{DueDate: due, Group: &ruck.Group{}}
+	if kind := reminderKindAt(task, due.Add(-3*time.Hour)); kind != "" {
+		t.Errorf("expected no reminder, got %q", kind)
+	}
+	if kind := reminderKindAt(task, due.Add(-2*time.Hour)); kind != notify.ReminderDueSoon {
+		t.Errorf("expected the due soon reminder, got %q", kind)
+	}
+}
+
+// TestMissedDueSoonReminder runs the scans of the scheduler. If the server didn't run during the
+// lead time only the overdue reminder is sent.
+func TestMissedDueSoonReminder(t *testing.T) {
+	due := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		scans []time.Time
+		sent  []notify.ReminderKind
+	}{
+		{"both", []time.Time{due.Add(-25 * time.Hour), due.Add(-23 * time.Hour), due.Add(-time.Hour), due.Add(time.Minute)},
+			[]notify.ReminderKind{notify.ReminderDueSoon, notify.ReminderOverdue}},
+		{"down during the lead time", []time.Time{due.Add(-25 * time.Hour), due.Add(time.Minute), due.Add(time.Hour)},
+			[]notify.ReminderKind{notify.ReminderOverdue}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := &ruck.Task{DueDate: due, Group: &ruck.Group{}}
+			// the reminder records of claimReminder
+			claimed := map[notify.ReminderKind]bool{}
+			var sent []notify.ReminderKind
+			for _, now := range test.scans {
+				kind := reminderKindAt(task, now)
+				if kind != "" && !claimed[kind] {
+					claimed[kind] = true
+					sent = append(sent, kind)
+				}
+			}
+			if len(sent) != len(test.sent) {
+				t.Fatalf("expected %v, got %v", test.sent, sent)
+			}
+			for i := range sent {
+				if sent[i] != test.sent[i] {
+					t.Errorf("expected %v, got %v", test.sent, sent)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/oidc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ssoLoginValidity = 10 * time.Minute
+	ssoCodeValidity  = time.Minute
+	ssoCallbackPath  = "/sso/callback"
+)
+
+var (
+	// UsedIdentityProvider is the OpenID provider used for single sign-on. SSO is disabled if it is nil.
+	UsedIdentityProvider *oidc.Provider
+	// SSOAutoProvision creates a user at the first login if no user matches the identity.
+	SSOAutoProvision bool
+
+	HttpErrSSONotConfigured      = NewErrorType(http.StatusServiceUnavailable, ruck.ErrorCodeSSONotConfigured, "Single sign-on is not configured")
+	HttpErrInvalidRedirectURI    = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidRedirectURI, "Invalid redirect_uri, only loopback addresses are allowed")
+	HttpErrCodeChallengeRequired = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeCodeChallengeRequired, "A S256 code_challenge is required")
+	HttpErrInvalidSSOState       = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidSSOState, "Invalid or expired login, please try again")
+	HttpErrInvalidSSOCode        = NewErrorType(http.StatusUnauthorized, ruck.ErrorCodeInvalidSSOCode, "Invalid or expired code")
+	HttpErrSSOFailed             = NewErrorType(http.StatusUnauthorized, ruck.ErrorCodeSSOFailed, "Login at the identity provider failed")
+	HttpErrSSONoAccount          = NewErrorType(http.StatusForbidden, ruck.ErrorCodeSSONoAccount, "No user exists for this identity")
+	HttpErrSSONameTaken          = NewErrorType(http.StatusConflict, ruck.ErrorCodeSSONameTaken, "The user name of the identity is already taken")
+	HttpErrSSOEmailConflict      = NewErrorType(http.StatusConflict, ruck.ErrorCodeSSOEmailConflict, "The email address of the identity belongs to an account which can't be linked")
+	ErrSSONoAccount              = errors.New("no user for identity")
+	ErrSSONameTaken              = errors.New("user name already taken")
+	ErrSSOEmailConflict          = errors.New("email address used by an account which can't be linked")
+
+	invalidUserNameCharacters = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+)
+
+// ssoLogin is a login started by a client which waits for the callback of the provider.
+type ssoLogin struct {
+	StateHash     string    `bson:"statehash"`
+	CodeVerifier  string    `bson:"codeverifier"`
+	Nonce         string    `bson:"nonce"`
+	RedirectURI   string    `bson:"redirecturi"`
+	ClientState   string    `bson:"clientstate"`
+	CodeChallenge string    `bson:"codechallenge"`
+	Expiry        time.Time `bson:"expiry"`
+}
+
+// ssoCode is handed to the client after a successful login and exchanged for tokens.
+type ssoCode struct {
+	Hash          string    `bson:"hash"`
+	UserName      string    `bson:"username"`
+	CodeChallenge string    `bson:"codechallenge"`
+	Expiry        time.Time `bson:"expiry"`
+}
+
+// isValid checks that the code isn't expired and the verifier matches the PKCE challenge of the login.
+func (c *ssoCode) isValid(codeVerifier string, now time.Time) bool {
+	challenge := oidc.CodeChallenge(codeVerifier)
+	return !now.After(c.Expiry) && subtle.ConstantTimeCompare([]byte(challenge), []byte(c.CodeChallenge)) == 1
+}
+
+// ssoIdentity links the subject of the provider to a user.
+type ssoIdentity struct {
+	Issuer   string `bson:"issuer"`
+	Subject  string `bson:"subject"`
+	UserName string `bson:"username"`
+}
+
+// isLoopbackRedirect only allows redirects to the machine of the user, as used by the CLI.
+func isLoopbackRedirect(redirectURI string) bool {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || parsed.Scheme != "http" || parsed.User != nil || parsed.Fragment != "" {
+		return false
+	}
+	host := parsed.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func redirectWithQuery(w http.ResponseWriter, r *http.Request, location string, query url.Values) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	values := parsed.Query()
+	for key, value := range query {
+		values[key] = value
+	}
+	parsed.RawQuery = values.Encode()
+	http.Redirect(w, r, parsed.String(), http.StatusFound)
+}
+
+// StartSSOLogin redirects the user to the identity provider. The client passes a loopback
+// redirect_uri, a state and a PKCE code_challenge.
+func StartSSOLogin(w http.ResponseWriter, r *http.Request) {
+	if UsedIdentityProvider == nil {
+		HttpErrSSONotConfigured.CauseString("no provider").Write(w, r)
+		return
+	}
+	query := r.URL.Query()
+	login := ssoLogin{
+		RedirectURI:   query.Get("redirect_uri"),
+		ClientState:   query.Get("state"),
+		CodeChallenge: query.Get("code_challenge"),
+		Expiry:        time.Now().Add(ssoLoginValidity),
+	}
+	if !isLoopbackRedirect(login.RedirectURI) {
+		HttpErrInvalidRedirectURI.Causef("redirect to %s", login.RedirectURI).Write(w, r)
+		return
+	}
+	if login.CodeChallenge == "" || query.Get("code_challenge_method") != "S256" {
+		HttpErrCodeChallengeRequired.CauseString("missing PKCE").Write(w, r)
+		return
+	}
+	state, err := oidc.RandomString()
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if login.CodeVerifier, err = oidc.RandomString(); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if login.Nonce, err = oidc.RandomString(); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	login.StateHash = hashSecretToken(state)
+	if _, err := ssoLoginCollection.InsertOne(r.Context(), &login); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	authorizationURL := UsedIdentityProvider.AuthorizationURL(buildPublicURL(ssoCallbackPath, nil), state,
+		login.Nonce, oidc.CodeChallenge(login.CodeVerifier))
+	http.Redirect(w, r, authorizationURL, http.StatusFound)
+}
+
+// SSOCallback receives the authorization code from the provider, logs in the user and redirects
+// back to the client with a short-lived code.
+func SSOCallback(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var login ssoLogin
+	if UsedIdentityProvider == nil {
+		HttpErrSSONotConfigured.CauseString("no provider").Write(w, r)
+		return
+	}
+	query := r.URL.Query()
+	err := ssoLoginCollection.FindOneAndDelete(ctx, bson.M{
+		"statehash": hashSecretToken(query.Get("state")),
+	}).Decode(&login)
+	if err == mongo.ErrNoDocuments || (err == nil && time.Now().After(login.Expiry)) {
+		HttpErrInvalidSSOState.CauseString("unknown state").Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if providerError := query.Get("error"); providerError != "" {
+		redirectWithQuery(w, r, login.RedirectURI, url.Values{
+			"error": {providerError},
+			"state": {login.ClientState},
+		})
+		return
+	}
+	rawIDToken, err := UsedIdentityProvider.Exchange(ctx, query.Get("code"), buildPublicURL(ssoCallbackPath, nil), login.CodeVerifier)
+	if err != nil {
+		HttpErrSSOFailed.Cause(err).Write(w, r)
+		return
+	}
+	claims, err := UsedIdentityProvider.VerifyIDToken(ctx, rawIDToken, login.Nonce)
+	if err != nil {
+		HttpErrSSOFailed.Cause(err).Write(w, r)
+		return
+	}
+	user, err := getUserForIdentity(ctx, claims)
+	if err != nil {
+		switch err {
+		case ErrSSONoAccount:
+			HttpErrSSONoAccount.Causef("no user for subject %s", claims.Subject).Write(w, r)
+		case ErrSSONameTaken:
+			HttpErrSSONameTaken.Causef("can't provision subject %s", claims.Subject).Write(w, r)
+		case ErrSSOEmailConflict:
+			HttpErrSSOEmailConflict.Causef("can't link subject %s with email %s", claims.Subject, claims.Email).Write(w, r)
+		default:
+			HttpErrInternal.Cause(err).Write(w, r)
+		}
+		return
+	}
+	if user.IsDisabled {
+		HttpErrUserDisabled.Causef("%s tried to log in", user.Name).Write(w, r)
+		return
+	}
+	code, err := generateSecretToken()
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	_, err = ssoCodeCollection.InsertOne(ctx, &ssoCode{
+		Hash:          hashSecretToken(code),
+		UserName:      user.Name,
+		CodeChallenge: login.CodeChallenge,
+		Expiry:        time.Now().Add(ssoCodeValidity),
+	})
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	redirectWithQuery(w, r, login.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {login.ClientState},
+	})
+}
+
+// ExchangeSSOCode returns the tokens for a code received by SSOCallback. The code verifier
+// proofs that the client started the login.
+func ExchangeSSOCode(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.SSOTokenRequest
+	var code ssoCode
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	err := ssoCodeCollection.FindOneAndDelete(ctx, bson.M{"hash": hashSecretToken(request.Code)}).Decode(&code)
+	if err == mongo.ErrNoDocuments {
+		HttpErrInvalidSSOCode.CauseString("unknown code").Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if !code.isValid(request.CodeVerifier, time.Now()) {
+		HttpErrInvalidSSOCode.CauseString("expired or wrong verifier").Write(w, r)
+		return
+	}
+	user, err := getUserForName(ctx, code.UserName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if user.IsDisabled {
+		HttpErrUserDisabled.Causef("%s tried to log in", user.Name).Write(w, r)
+		return
+	}
+	result, err := issueAuthenticationResult(ctx, user)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	log.Printf("User %s logged in with single sign-on\n", user.Name)
+	writeResponse(w, r, result)
+}
+
+// getUserForIdentity finds the user linked to the subject. Otherwise the identity is linked to the
+// user with the same email address or a new user is provisioned. An existing user is only linked if
+// both the user and the identity provider verified the address and no other user has it, so that
+// nobody can take over an account by setting its address at the provider.
+func getUserForIdentity(ctx context.Context, claims *oidc.Claims) (*ruck.User, error) {
+	var identity ssoIdentity
+	issuer := UsedIdentityProvider.Metadata.Issuer
+	err := ssoIdentityCollection.FindOne(ctx, bson.M{"issuer": issuer, "subject": claims.Subject}).Decode(&identity)
+	if err == nil {
+		return getUserForName(ctx, identity.UserName)
+	} else if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	var user *ruck.User
+	if claims.Email != "" {
+		user, err = getUserToLink(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if user == nil {
+		if !SSOAutoProvision {
+			return nil, ErrSSONoAccount
+		}
+		user, err = provisionUser(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = ssoIdentityCollection.InsertOne(ctx, &ssoIdentity{
+		Issuer:   issuer,
+		Subject:  claims.Subject,
+		UserName: user.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Linked identity %s of %s to user %s\n", claims.Subject, issuer, user.Name)
+	return user, nil
+}
+
+// getUserToLink returns the user with the email address of the identity or nil if no user has the
+// address. ErrSSOEmailConflict is returned if the address can't prove that the identity belongs to
+// the user.
+func getUserToLink(ctx context.Context, claims *oidc.Claims) (*ruck.User, error) {
+	cursor, err := usersCollection.Find(ctx, bson.M{"emailaddress": claims.Email}, options.Find().SetLimit(2))
+	if err != nil {
+		return nil, err
+	}
+	var users []*ruck.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return chooseUserToLink(claims, users)
+}
+
+// chooseUserToLink applies the linking rules of getUserToLink to the users with the address.
+func chooseUserToLink(claims *oidc.Claims, users []*ruck.User) (*ruck.User, error) {
+	switch {
+	case len(users) == 0:
+		return nil, nil
+	case len(users) > 1:
+		log.Printf("Identity %s has email %s which is used by multiple users\n", claims.Subject, claims.Email)
+		return nil, ErrSSOEmailConflict
+	case !claims.EmailVerified || !users[0].EmailVerified:
+		log.Printf("Identity %s has email %s of user %s, but it isn't verified\n", claims.Subject, claims.Email, users[0].Name)
+		return nil, ErrSSOEmailConflict
+	}
+	user := users[0]
+	user.PasswordHash = nil
+	return user, nil
+}
+
+// provisionUser creates a user without password for the identity.
+func provisionUser(ctx context.Context, claims *oidc.Claims) (*ruck.User, error) {
+	name := claims.PreferredUsername
+	if name == "" && claims.Email != "" {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	name = invalidUserNameCharacters.ReplaceAllString(name, "")
+	if name == "" {
+		return nil, ErrSSONameTaken
+	}
+	_, err := getUserForName(ctx, name)
+	if err == nil {
+		return nil, ErrSSONameTaken
+	} else if err != ErrNoSucUser {
+		return nil, err
+	}
+	user := &ruck.User{
+		Name:          name,
+		EmailAddress:  claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}
+	if err := createUser(ctx, user); err != nil {
+		return nil, err
+	}
+	log.Printf("Provisioned user %s for identity %s\n", user.Name, claims.Subject)
+	return user, nil
+}
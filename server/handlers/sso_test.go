@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/oidc"
+)
+
+func TestChooseUserToLink(t *testing.T) {
+	verifiedUser := func() *ruck.User {
+		return &ruck.User{Name: "alice", EmailAddress: "alice@example.com", EmailVerified: true, PasswordHash: []byte("hash")}
+	}
+	unverifiedUser := verifiedUser()
+	unverifiedUser.EmailVerified = false
+	tests := []struct {
+		name          string
+		emailVerified bool
+		users         []*ruck.User
+		linked        bool
+		err           error
+	}{
+		{"no user with the address", true, nil, false, nil},
+		{"verified on both sides", true, []*ruck.User{verifiedUser()}, true, nil},
+		{"not verified by the provider", false, []*ruck.User{verifiedUser()}, false, ErrSSOEmailConflict},
+		{"not verified by the user", true, []*ruck.User{unverifiedUser}, false, ErrSSOEmailConflict},
+		{"address of multiple users", true, []*ruck.User{verifiedUser(), verifiedUser()}, false, ErrSSOEmailConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := &oidc.Claims{Subject: "subject-1", Email: "alice@example.com", EmailVerified: test.emailVerified}
+			user, err := chooseUserToLink(claims, test.users)
+			if err != test.err {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+			if (user != nil) != test.linked {
+				t.Fatalf("expected linked %t, got %v", test.linked, user)
+			}
+			if user != nil && user.PasswordHash != nil {
+				t.Error("the password hash must not be returned")
+			}
+		})
+	}
+}
+
+func TestSSOCodeIsValid(t *testing.T) {
+	now := time.Now()
+	code := &ssoCode{
+		UserName:      "alice",
+		CodeChallenge: oidc.CodeChallenge("verifier"),
+		Expiry:        now.Add(ssoCodeValidity),
+	}
+	if !code.isValid("verifier", now) {
+		t.Error("expected the code to be valid")
+	}
+	if code.isValid("other verifier", now) {
+		t.Error("wrong code verifier accepted")
+	}
+	if code.isValid("", now) {
+		t.Error("missing code verifier accepted")
+	}
+	if code.isValid("verifier", now.Add(ssoCodeValidity+time.Second)) {
+		t.Error("expired code accepted")
+	}
+}
+
+func TestIsLoopbackRedirect(t *testing.T) {
+	for redirectURI, expected := range map[string]bool{
+		"http://localhost:8123/callback":        true,
+		"http://127.0.0.1:8123/callback":        true,
+		"http://[::1]:8123/callback":            true,
+		"https://localhost/callback":            false,
+		"http://example.com/callback":           false,
+		"http://user@localhost/callback":        false,
+		"http://localhost/callback#fragment":    false,
+		"http://localhost.example.com/callback": false,
+	} {
+		if isLoopbackRedirect(redirectURI) != expected {
+			t.Errorf("isLoopbackRedirect(%s) != %t", redirectURI, expected)
+		}
+	}
+}
@@ -2,22 +2,24 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	http_error "github.com/coffeemakr/go-http-error"
 	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"github.com/coffeemakr/ruck/server/notify"
 	"github.com/gorilla/mux"
 	"log"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 var (
 	ErrNoSuchTask             = errors.New("no such task")
 	ErrMultipleTaskedMatched  = errors.New("multiple tasks matched")
-	HttpErrTaskNotFound       = http_error.NewHttpErrorType(http.StatusNotFound, "task not found")
-	HttpErrAssigneeNotInGroup = http_error.NewHttpErrorType(http.StatusBadRequest, "assignee not in group")
+	HttpErrTaskNotFound       = NewErrorType(http.StatusNotFound, ruck.ErrorCodeTaskNotFound, "Task not found")
+	HttpErrInvalidInterval    = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidInterval, "Invalid interval unit")
+	HttpErrAssigneeNotInGroup = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeAssigneeNotInGroup, "Assignee not in group")
 )
 
 func getGroupId(r *http.Request) string {
@@ -52,12 +54,23 @@ func stringArrayContain(s []string, e string) bool {
 	return false
 }
 
+// removeString returns the slice without the element.
+func removeString(s []string, e string) []string {
+	result := make([]string, 0, len(s))
+	for _, a := range s {
+		if a != e {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
 func CreateTaskForGroup(w http.ResponseWriter, r *http.Request) {
 	var (
-		task  ruck.Task
-		group *ruck.Group
-		err   error
-		ctx   = r.Context()
+		request ruck.TaskRequest
+		group   *ruck.Group
+		err     error
+		ctx     = r.Context()
 	)
 	groupId := getGroupId(r)
 	userName, err := GetUserNameFromRequest(r)
@@ -65,21 +78,35 @@ func CreateTaskForGroup(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 	// decode task
-	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+	if err := decodeRequest(r, &request); err != nil {
 		ErrInvalidJsonBody.Cause(err).Write(w, r)
 		return
 	}
 
 	// load group and check therefore if the user is a member of the group
 	group, err = getGroupForUser(ctx, groupId, userName)
-	if err != nil {
-		http_error.ErrBadRequest.Cause(err).Write(w, r)
+	if err == ErrGroupNotFound {
+		HttpErrGroupNotFound.Cause(err).Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
 		return
 	}
 
-	if task.AssigneeName == "" {
+	task := ruck.Task{
+		Name:         request.Name,
+		Interval:     request.Interval,
+		AssigneeName: request.AssigneeName,
+	}
+	switch {
+	case request.Open && task.AssigneeName != "":
+		HttpErrBadRequest.Causef("open task assigned to %s", task.AssigneeName).Write(w, r)
+		return
+	case request.Open:
+		// anyone can take the task
+	case task.AssigneeName == "":
 		task.AssigneeName = randomChoice(group.MemberNames)
-	} else if !stringArrayContain(group.MemberNames, task.AssigneeName) {
+	case !stringArrayContain(group.MemberNames, task.AssigneeName):
 		HttpErrAssigneeNotInGroup.Causef("can't assign %s", task.AssigneeName).Write(w, r)
 		return
 	}
@@ -91,7 +118,7 @@ func CreateTaskForGroup(w http.ResponseWriter, r *http.Request) {
 	case ruck.Weeks:
 	// Ok
 	default:
-		http_error.ErrBadRequest.CauseString("Invalid interval unit").Write(w, r)
+		HttpErrInvalidInterval.Causef("invalid interval unit %q", task.Interval.Unit).Write(w, r)
 		return
 
 	}
@@ -100,21 +127,20 @@ func CreateTaskForGroup(w http.ResponseWriter, r *http.Request) {
 	task.DueDate = task.Interval.Next(time.Now())
 	task.GroupID = groupId
 	if err := createTask(ctx, &task); err != nil {
-		http_error.ErrInternalServerError.Causef("Failed to create task: %s", err).Write(w, r)
+		HttpErrInternal.Causef("Failed to create task: %s", err).Write(w, r)
 		return
 	}
-	if err := writeJson(w, task); err != nil {
-		http_error.ErrInternalServerError.Causef("Failed to write response: %s", err).Write(w, r)
-	}
+	publishTaskEvent(api.EventTaskCreated, &task, group, userName)
+	writeResponse(w, r, &task)
 }
 
 func UpdateTaskById(w http.ResponseWriter, r *http.Request) {
 	taskId := getTaskId(r)
 	ctx := r.Context()
 	var updateTask ruck.Task
-	err := json.NewDecoder(r.Body).Decode(&updateTask)
+	err := decodeRequest(r, &updateTask)
 	if err != nil {
-		http_error.ErrBadRequest.Cause(err).Write(w, r)
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
 		return
 	}
 	updateTask.ID = taskId
@@ -123,9 +149,9 @@ func UpdateTaskById(w http.ResponseWriter, r *http.Request) {
 	case ErrNoSuchTask:
 		HttpErrTaskNotFound.Cause(err).Write(w, r)
 	case nil:
-		mustWriteJson(w, updateTask)
+		writeResponse(w, r, &updateTask)
 	default:
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
+		HttpErrInternal.Cause(err).Write(w, r)
 	}
 }
 
@@ -137,17 +163,16 @@ func GetTaskById(w http.ResponseWriter, r *http.Request) {
 	case ErrNoSuchTask:
 		HttpErrTaskNotFound.Cause(err).Write(w, r)
 	case nil:
-		mustWriteJson(w, task)
+		writeResponse(w, r, task)
 	default:
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
+		HttpErrInternal.Cause(err).Write(w, r)
 	}
 }
 
+// assignTaskToNextPerson moves the executor to the end of the queue of the task and assigns it to
+// the member whose turn is next.
 func assignTaskToNextPerson(ctx context.Context, executorName string, task *ruck.Task) error {
-	// TODO: The one that executed the task should be put at the end of the queue
-	if task.AssigneeName == executorName {
-		task.AssignNext()
-	}
+	task.Rotate(executorName)
 	task.DueDate = task.Interval.Next(time.Now())
 	return updateTaskById(ctx, task)
 }
@@ -165,7 +190,7 @@ func CreateTaskExecution(w http.ResponseWriter, r *http.Request) {
 		if err == ErrNoSuchTask {
 			HttpErrTaskNotFound.Cause(err).Write(w, r)
 		} else {
-			http_error.ErrInternalServerError.Cause(err).Write(w, r)
+			HttpErrInternal.Cause(err).Write(w, r)
 		}
 		return
 	}
@@ -177,15 +202,33 @@ func CreateTaskExecution(w http.ResponseWriter, r *http.Request) {
 		Task:         task,
 	}
 	if err := createTaskExecution(ctx, &execution); err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
+		HttpErrInternal.Cause(err).Write(w, r)
 		return
 	}
 
+	previousAssignee := task.AssigneeName
+	previousDueDate := task.DueDate
 	if err := assignTaskToNextPerson(ctx, userName, task); err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
 	}
-	log.Printf("Created task execution: %s\n", execution)
-	mustWriteJson(w, execution)
+	recordTaskHistory(ctx, &api.TaskHistoryEntry{
+		TaskID:               task.ID,
+		GroupID:              task.Group.ID,
+		Type:                 api.HistoryCompleted,
+		Actor:                userName,
+		AssigneeName:         task.AssigneeName,
+		PreviousAssigneeName: previousAssignee,
+		DueDate:              previousDueDate,
+	})
+	log.Printf("Created task execution: %v\n", execution)
+	publishTaskCompleted(&execution, task, task.Group)
+	if task.AssigneeName != previousAssignee {
+		// the push services may be slow, the copy isn't shared with the response
+		assignedTask := *task
+		go notifyAssignee(context.Background(), notify.ReminderAssigned, &assignedTask)
+	}
+	writeResponse(w, r, &execution)
 }
 
 func GetAllTasks(w http.ResponseWriter, r *http.Request) {
@@ -195,8 +238,18 @@ func GetAllTasks(w http.ResponseWriter, r *http.Request) {
 	}
 	tasks, err := getTasksForUser(r.Context(), userName)
 	if err != nil {
-		http_error.ErrInternalServerError.Cause(err).Write(w, r)
+		HttpErrInternal.Cause(err).Write(w, r)
 		return
 	}
-	mustWriteJson(w, tasks)
+	// the pool of tasks which anyone can claim
+	if open, _ := strconv.ParseBool(r.URL.Query().Get("open")); open {
+		openTasks := make([]*ruck.Task, 0)
+		for _, task := range tasks {
+			if task.AssigneeName == "" {
+				openTasks = append(openTasks, task)
+			}
+		}
+		tasks = openTasks
+	}
+	writeResponse(w, r, tasks)
 }
@@ -49,6 +49,19 @@ func updateTaskById(ctx context.Context, task *ruck.Task) error {
 	return nil
 }
 
+// getTasksOfGroup returns the tasks of the group without the group itself.
+func getTasksOfGroup(ctx context.Context, groupId string) ([]*ruck.Task, error) {
+	cursor, err := taskCollection.Find(ctx, bson.M{"groupid": groupId})
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*ruck.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
 func createTaskExecution(ctx context.Context, execution *ruck.TaskExecution) error {
 	_, err := taskExecutionCollection.InsertOne(ctx, execution)
 	return err
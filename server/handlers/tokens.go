@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	"time"
+)
+
+const DefaultRefreshTokenLifetime = 30 * 24 * time.Hour
+
+var (
+	// RefreshTokenLifetime is the time a refresh token can be used after it was issued.
+	RefreshTokenLifetime = DefaultRefreshTokenLifetime
+
+	HttpErrInvalidRefreshToken = NewErrorType(http.StatusUnauthorized, ruck.ErrorCodeInvalidRefreshToken, "Invalid or expired refresh token")
+	ErrInvalidRefreshToken     = errors.New("invalid or expired refresh token")
+)
+
+// refreshToken is the stored form of a refresh token. Every refresh token can only be used once.
+type refreshToken struct {
+	Hash          string    `bson:"hash"`
+	UserName      string    `bson:"username"`
+	AccessTokenID string    `bson:"accesstokenid"`
+	Expiry        time.Time `bson:"expiry"`
+}
+
+// revokedToken marks an access token as invalid until it expires anyway.
+type revokedToken struct {
+	ID     string    `bson:"id"`
+	Expiry time.Time `bson:"expiry"`
+}
+
+// issueAuthenticationResult issues a new access token and a refresh token bound to it.
+func issueAuthenticationResult(ctx context.Context, user *ruck.User) (*ruck.AuthenticationResult, error) {
+	if UsedTokenIssuer == nil {
+		panic("token issuer is nil")
+	}
+	decodedToken := ruck.DecodedToken{UserName: user.Name}
+	token, err := UsedTokenIssuer.IssueToken(&decodedToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue access token: %s", err)
+	}
+	secret, err := generateSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	_, err = refreshTokenCollection.InsertOne(ctx, &refreshToken{
+		Hash:          hashSecretToken(secret),
+		UserName:      user.Name,
+		AccessTokenID: decodedToken.ID,
+		Expiry:        time.Now().Add(RefreshTokenLifetime),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %s", err)
+	}
+	return &ruck.AuthenticationResult{
+		Token:        token,
+		ExpiresAt:    decodedToken.Expiry,
+		RefreshToken: secret,
+		User:         user,
+	}, nil
+}
+
+// consumeRefreshToken deletes the refresh token and returns the name of the user it belongs to.
+func consumeRefreshToken(ctx context.Context, secret string) (string, error) {
+	var token refreshToken
+	err := refreshTokenCollection.FindOneAndDelete(ctx, bson.M{
+		"hash": hashSecretToken(secret),
+	}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			err = ErrInvalidRefreshToken
+		}
+		return "", err
+	}
+	if time.Now().After(token.Expiry) {
+		return "", ErrInvalidRefreshToken
+	}
+	return token.UserName, nil
+}
+
+func deleteRefreshTokensOfUser(ctx context.Context, userName string) error {
+	_, err := refreshTokenCollection.DeleteMany(ctx, bson.M{"username": userName})
+	return err
+}
+
+// revokeAccessToken revokes the access token and the refresh token issued with it.
+func revokeAccessToken(ctx context.Context, token *ruck.DecodedToken) error {
+	_, err := revokedTokenCollection.InsertOne(ctx, &revokedToken{
+		ID:     token.ID,
+		Expiry: token.Expiry,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = refreshTokenCollection.DeleteMany(ctx, bson.M{"accesstokenid": token.ID})
+	return err
+}
+
+func isAccessTokenRevoked(ctx context.Context, tokenId string) (bool, error) {
+	count, err := revokedTokenCollection.CountDocuments(ctx, bson.M{"id": tokenId})
+	if err != nil {
+		return false, err
+	}
+	return count != 0, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.RefreshRequest
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	userName, err := consumeRefreshToken(ctx, request.RefreshToken)
+	if err != nil {
+		if err == ErrInvalidRefreshToken {
+			HttpErrInvalidRefreshToken.Cause(err).Write(w, r)
+		} else {
+			HttpErrInternal.Cause(err).Write(w, r)
+		}
+		return
+	}
+	user, err := getUserForName(ctx, userName)
+	if err != nil {
+		if err == ErrNoSucUser {
+			HttpErrInvalidRefreshToken.Cause(err).Write(w, r)
+		} else {
+			HttpErrInternal.Cause(err).Write(w, r)
+		}
+		return
+	}
+	if user.IsDisabled {
+		HttpErrUserDisabled.Causef("%s tried to refresh token", user.Name).Write(w, r)
+		return
+	}
+	result, err := issueAuthenticationResult(ctx, user)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, result)
+}
+
+// Logout revokes the access token used for the request and the refresh token issued with it.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	token, err := GetTokenFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := revokeAccessToken(r.Context(), token); err != nil {
+		HttpErrInternal.Causef("Failed to revoke token: %s", err).Write(w, r)
+		return
+	}
+	log.Printf("User %s logged out\n", token.UserName)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/totp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	totpIssuer         = "ruck"
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+var (
+	HttpErrTwoFactorAlreadyEnabled = NewErrorType(http.StatusConflict, ruck.ErrorCodeTwoFactorAlreadyEnabled, "Two-factor authentication is already enabled")
+	HttpErrTwoFactorNotEnabled     = NewErrorType(http.StatusConflict, ruck.ErrorCodeTwoFactorNotEnabled, "Two-factor authentication is not enabled")
+	HttpErrInvalidTwoFactorCode    = NewErrorType(http.StatusUnauthorized, ruck.ErrorCodeInvalidTwoFactorCode, "Invalid one-time code")
+	HttpErrInvalidTwoFactorToken   = NewErrorType(http.StatusUnauthorized, ruck.ErrorCodeInvalidTwoFactorToken, "Invalid or expired two-factor token, please log in again")
+	ErrInvalidTwoFactorCode        = errors.New("invalid one-time code")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// twoFactorModel stores the TOTP secret of a user. It is only used for logins after it was confirmed.
+type twoFactorModel struct {
+	UserName string `bson:"username"`
+	Secret   string `bson:"secret"`
+	Enabled  bool   `bson:"enabled"`
+	// LastCounter is the time step of the last accepted code, codes can't be used twice.
+	LastCounter   int64    `bson:"lastcounter"`
+	RecoveryCodes []string `bson:"recoverycodes"`
+}
+
+func getTwoFactor(ctx context.Context, userName string) (*twoFactorModel, error) {
+	var model twoFactorModel
+	err := twoFactorCollection.FindOne(ctx, bson.M{"username": userName}).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func isTwoFactorEnabled(ctx context.Context, userName string) (bool, error) {
+	model, err := getTwoFactor(ctx, userName)
+	if err != nil {
+		return false, err
+	}
+	return model != nil && model.Enabled, nil
+}
+
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeLength*5/8)
+		if _, err = rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes = append(codes, code)
+		hashes = append(hashes, hashSecretToken(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// useTwoFactorCode accepts a one-time code or a recovery code. Every code can only be used once.
+func useTwoFactorCode(ctx context.Context, model *twoFactorModel, code string) error {
+	if counter, ok := totp.Validate(model.Secret, code, time.Now()); ok {
+		// the condition prevents that the same code is accepted twice by concurrent requests
+		result, err := twoFactorCollection.UpdateOne(ctx, bson.M{
+			"username":    model.UserName,
+			"lastcounter": bson.M{"$lt": int64(counter)},
+		}, bson.M{
+			"$set": bson.M{"lastcounter": int64(counter)},
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+	if !model.Enabled {
+		return ErrInvalidTwoFactorCode
+	}
+	result, err := twoFactorCollection.UpdateOne(ctx, bson.M{
+		"username":      model.UserName,
+		"recoverycodes": hashSecretToken(normalizeRecoveryCode(code)),
+	}, bson.M{
+		"$pull": bson.M{"recoverycodes": hashSecretToken(normalizeRecoveryCode(code))},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	log.Printf("User %s used a recovery code\n", model.UserName)
+	return nil
+}
+
+// writeTwoFactorRequired responds to a login with a token for the second step.
+func writeTwoFactorRequired(w http.ResponseWriter, r *http.Request, user *ruck.User) {
+	token, err := UsedTokenIssuer.IssueTwoFactorToken(user.Name)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, &ruck.AuthenticationResult{
+		TwoFactorRequired: true,
+		TwoFactorToken:    token,
+	})
+}
+
+// EnrollTwoFactor creates a new TOTP secret. It must be confirmed with a code before it is used.
+func EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	enabled, err := isTwoFactorEnabled(ctx, userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if enabled {
+		HttpErrTwoFactorAlreadyEnabled.Causef("%s tried to enroll again", userName).Write(w, r)
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	_, err = twoFactorCollection.ReplaceOne(ctx, bson.M{"username": userName}, &twoFactorModel{
+		UserName: userName,
+		Secret:   secret,
+	}, options.Replace().SetUpsert(true))
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, &ruck.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, userName, secret),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication and returns the recovery codes.
+func ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.TwoFactorCodeRequest
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	model, err := getTwoFactor(ctx, userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if model == nil {
+		HttpErrTwoFactorNotEnabled.Causef("%s didn't enroll", userName).Write(w, r)
+		return
+	}
+	if model.Enabled {
+		HttpErrTwoFactorAlreadyEnabled.Causef("%s confirmed again", userName).Write(w, r)
+		return
+	}
+	err = useTwoFactorCode(ctx, model, request.Code)
+	if err != nil {
+		if err == ErrInvalidTwoFactorCode {
+			HttpErrInvalidTwoFactorCode.Cause(err).Write(w, r)
+		} else {
+			HttpErrInternal.Cause(err).Write(w, r)
+		}
+		return
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	_, err = twoFactorCollection.UpdateOne(ctx, bson.M{"username": userName}, bson.M{
+		"$set": bson.M{
+			"enabled":       true,
+			"recoverycodes": hashes,
+		},
+	})
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	log.Printf("User %s enabled two-factor authentication\n", userName)
+	writeResponse(w, r, &ruck.RecoveryCodes{Codes: codes})
+}
+
+// DisableTwoFactor disables two-factor authentication. A valid code is required.
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.TwoFactorCodeRequest
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	model, err := getTwoFactor(ctx, userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if model == nil || !model.Enabled {
+		HttpErrTwoFactorNotEnabled.Causef("%s can't disable", userName).Write(w, r)
+		return
+	}
+	if err := useTwoFactorCode(ctx, model, request.Code); err != nil {
+		if err == ErrInvalidTwoFactorCode {
+			HttpErrInvalidTwoFactorCode.Cause(err).Write(w, r)
+		} else {
+			HttpErrInternal.Cause(err).Write(w, r)
+		}
+		return
+	}
+	if _, err := twoFactorCollection.DeleteOne(ctx, bson.M{"username": userName}); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	log.Printf("User %s disabled two-factor authentication\n", userName)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CompleteTwoFactorLogin is the second step of the login if two-factor authentication is enabled.
+func CompleteTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	var ctx = r.Context()
+	var request ruck.TwoFactorLoginRequest
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	userName, err := UsedTokenVerifier.VerifyTwoFactorToken(request.Token)
+	if err != nil {
+		HttpErrInvalidTwoFactorToken.Cause(err).Write(w, r)
+		return
+	}
+	failures, err := checkNotLocked(ctx, userName, twoFactorStep)
+	if err != nil {
+		writeAuthenticationError(w, r, err)
+		return
+	}
+	model, err := getTwoFactor(ctx, userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if model == nil || !model.Enabled {
+		HttpErrTwoFactorNotEnabled.Causef("%s disabled two-factor authentication", userName).Write(w, r)
+		return
+	}
+	err = useTwoFactorCode(ctx, model, request.Code)
+	if err == ErrInvalidTwoFactorCode {
+		if err := recordLoginFailure(ctx, userName, twoFactorStep); err != nil {
+			HttpErrInternal.Cause(err).Write(w, r)
+			return
+		}
+		HttpErrInvalidTwoFactorCode.Cause(err).Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if failures != nil {
+		if err := resetLoginFailures(ctx, userName, twoFactorStep); err != nil {
+			HttpErrInternal.Cause(err).Write(w, r)
+			return
+		}
+	}
+	user, err := getUserForName(ctx, userName)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if user.IsDisabled {
+		HttpErrUserDisabled.Causef("%s tried to log in", userName).Write(w, r)
+		return
+	}
+	result, err := issueAuthenticationResult(ctx, user)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	log.Printf("User %s logged in with two-factor authentication\n", user.Name)
+	writeResponse(w, r, result)
+}
@@ -3,22 +3,26 @@ package handlers
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
-	httperrors "github.com/coffeemakr/go-http-error"
 	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/passhash"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/bcrypt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
 var (
-	bcryptCost                = bcrypt.DefaultCost
-	HttpErrPasswordsDontMatch = httperrors.ErrBadRequest.WithDescription("Passwords don't match")
-	HttpErrInvalidCredentials = httperrors.NewHttpErrorType(http.StatusUnauthorized, "Invalid credentials")
+	// UsedPasswordHasher hashes new passwords. Hashes with other parameters are replaced at the next login.
+	UsedPasswordHasher passhash.Hasher = passhash.DefaultArgon2id
+
+	HttpErrPasswordsDontMatch = NewErrorType(http.StatusBadRequest, ruck.ErrorCodePasswordsDontMatch, "Passwords don't match")
+	HttpErrInvalidCredentials = NewErrorType(http.StatusUnauthorized, ruck.ErrorCodeInvalidCredentials, "Invalid credentials")
+	HttpErrUserDisabled       = NewErrorType(http.StatusForbidden, ruck.ErrorCodeUserDisabled, "User is disabled")
 	ErrNoSucUser              = errors.New("no such user")
+	ErrUserDisabled           = errors.New("user is disabled")
 )
 
 const userFieldName = "name"
@@ -28,45 +32,50 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 	var result *ruck.AuthenticationResult
 	var credentials ruck.Credentials
 	var ctx = r.Context()
-	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+	if err := decodeRequest(r, &credentials); err != nil {
 		ErrInvalidJsonBody.Cause(err).Write(w, r)
 		return
 	}
 
 	// TODO: password policy
 
-	user, err := getUserForCredentials(ctx, &credentials)
+	user, err := authenticateUser(ctx, &credentials)
 	if err != nil {
-		if err == ErrNoSucUser {
-			HttpErrInvalidCredentials.Cause(err).Write(w, r)
-		} else {
-			httperrors.ErrInternalServerError.Causef("Failed to get user: %s", err).Write(w, r)
-		}
+		writeAuthenticationError(w, r, err)
 		return
 	}
 
-	if UsedTokenIssuer == nil {
-		panic("token issuer is nil")
+	if RequireVerifiedEmail && !user.EmailVerified {
+		HttpErrEmailNotVerified.Causef("%s tried to log in without verified email", user.Name).Write(w, r)
+		return
 	}
 
-	token, err := UsedTokenIssuer.IssueToken(&ruck.DecodedToken{UserName: user.Name})
-
-	result = &ruck.AuthenticationResult{
-		Token: token,
-		User:  user,
+	twoFactorEnabled, err := isTwoFactorEnabled(ctx, user.Name)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if twoFactorEnabled {
+		writeTwoFactorRequired(w, r, user)
+		return
 	}
 
-	log.Println(result)
-	if err := writeJson(w, result); err != nil {
-		httperrors.ErrInternalServerError.Causef("failed to write auth response: %s", err).Write(w, r)
+	user.PasswordHash = nil
+	result, err = issueAuthenticationResult(ctx, user)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
 	}
+
+	log.Printf("User %s logged in\n", user.Name)
+	writeResponse(w, r, result)
 }
 
 func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	var user *ruck.User
 	var registrationRequest ruck.RegistrationRequest
 	var ctx = r.Context()
-	if err := json.NewDecoder(r.Body).Decode(&registrationRequest); err != nil {
+	if err := decodeRequest(r, &registrationRequest); err != nil {
 		ErrInvalidJsonBody.Cause(err).Write(w, r)
 		return
 	}
@@ -77,18 +86,47 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 	// TODO: password policy
 
+	// the address is optional, but it is used for mails if it is set
+	registrationRequest.Email = strings.TrimSpace(registrationRequest.Email)
+	if registrationRequest.Email != "" {
+		if httpErr := validateEmailAddress(registrationRequest.Email); httpErr != nil {
+			httpErr.Write(w, r)
+			return
+		}
+	}
+
+	codeId, ok := checkRegistrationAllowed(w, r, &registrationRequest)
+	if !ok {
+		return
+	}
+
 	user, err := registerUser(ctx, &registrationRequest)
 	if err != nil {
-		httperrors.ErrInternalServerError.Causef("Failed to register user: %s", err).Write(w, r)
+		if codeId != "" {
+			restoreRegistrationCode(ctx, codeId)
+		}
+		HttpErrInternal.Causef("Failed to register user: %s", err).Write(w, r)
 		return
 	}
 
-	if err := writeJson(w, user); err != nil {
-		httperrors.ErrInternalServerError.Causef("Failed to write user response %s", err).Write(w, r)
+	if registrationRequest.GroupID != "" {
+		if err := joinGroup(ctx, user.Name, registrationRequest.GroupID); err != nil {
+			log.Printf("User %s couldn't join group %s: %s\n", user.Name, registrationRequest.GroupID, err)
+		}
+	}
+
+	if UsedMailer != nil {
+		if err := sendVerificationMail(user); err != nil {
+			log.Printf("Failed to send verification mail to %s: %s\n", user.Name, err)
+		}
 	}
+
+	writeResponse(w, r, user)
 }
 
 func createUser(ctx context.Context, user *ruck.User) (err error) {
+	// tokens of a deleted user with the same name must not be accepted
+	user.PasswordChangedAt = time.Now()
 	_, err = usersCollection.InsertOne(ctx, user)
 	user.PasswordHash = nil // Prevent hash from leaking
 	return
@@ -120,18 +158,54 @@ func getUserForCredentials(ctx context.Context, credentials *ruck.Credentials) (
 	if err != nil {
 		return
 	}
-	err = bcrypt.CompareHashAndPassword(user.PasswordHash, credentials.Password)
-	if err != nil {
-		if err == bcrypt.ErrMismatchedHashAndPassword {
+	matches, err := passhash.Verify(user.PasswordHash, credentials.Password)
+	if err != nil || !matches {
+		if err == nil {
 			err = ErrNoSucUser
 		}
 		user = nil
+		return
+	}
+	// only checked after the password, so the state isn't leaked
+	if user.IsDisabled {
+		user = nil
+		err = ErrUserDisabled
+		return
+	}
+	if UsedPasswordHasher.NeedsRehash(user.PasswordHash) {
+		rehashPassword(ctx, user.Name, user.PasswordHash, credentials.Password)
 	}
 	return
 }
 
+func hashPassword(password []byte) ([]byte, error) {
+	return UsedPasswordHasher.Hash(password)
+}
+
+// rehashPassword replaces the hash with one using the current algorithm and parameters.
+// Failures are only logged, as the login succeeded anyway.
+func rehashPassword(ctx context.Context, userName string, oldHash []byte, password []byte) {
+	hashed, err := hashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash password of %s: %s\n", userName, err)
+		return
+	}
+	// the old hash is part of the filter, so a concurrent password change isn't overwritten
+	_, err = usersCollection.UpdateOne(ctx, bson.M{
+		userFieldName:  userName,
+		"passwordhash": oldHash,
+	}, bson.M{
+		"$set": bson.M{"passwordhash": hashed},
+	})
+	if err != nil {
+		log.Printf("Failed to store rehashed password of %s: %s\n", userName, err)
+		return
+	}
+	log.Printf("Rehashed password of %s\n", userName)
+}
+
 func registerUser(ctx context.Context, registration *ruck.RegistrationRequest) (user *ruck.User, err error) {
-	hashed, err := bcrypt.GenerateFromPassword(registration.Password, bcryptCost)
+	hashed, err := hashPassword(registration.Password)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/server/mail/mailtest"
+)
+
+func TestRegisterUserRejectsInvalidEmail(t *testing.T) {
+	server := setUpTestMailer(t)
+	body := `{"name": "alice", "email": "alice@example.com\r\nBcc: eve@example.com",
+		"password": "secret", "password_confirmation": "secret"}`
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/users/register", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	RegisterUser(recorder, request)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", recorder.Code, recorder.Body)
+	}
+	var response ruck.ErrorResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != ruck.ErrorCodeInvalidEmail {
+		t.Errorf("expected code %s, got %s", ruck.ErrorCodeInvalidEmail, response.Code)
+	}
+	if _, err := server.Receive(100 * time.Millisecond); err != mailtest.ErrNoMail {
+		t.Errorf("no mail expected, got %v", err)
+	}
+}
@@ -0,0 +1,608 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	maxWebhooksPerGroup        = 10
+	maxWebhookURLLength        = 2000
+	maxWebhookSecretLength     = 200
+	maxWebhookAttempts         = 6
+	maxListedWebhookDeliveries = 50
+	// webhookRetryDelay is doubled after every failed attempt.
+	webhookRetryDelay = 30 * time.Second
+	// webhookDeliveryLease is the time a delivery is reserved for a worker. It is retried afterwards
+	// if the worker didn't finish it, e.g. because the server stopped.
+	webhookDeliveryLease     = 2 * time.Minute
+	webhookPollInterval      = 10 * time.Second
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+)
+
+var (
+	HttpErrWebhookNotFound      = NewErrorType(http.StatusNotFound, ruck.ErrorCodeWebhookNotFound, "Webhook not found")
+	HttpErrInvalidWebhookURL    = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidWebhookURL, "Invalid webhook URL")
+	HttpErrInvalidWebhookSecret = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidWebhookSecret, "Invalid webhook secret")
+	HttpErrInvalidEventType     = NewErrorType(http.StatusBadRequest, ruck.ErrorCodeInvalidEventType, "Invalid event type")
+	HttpErrTooManyWebhooks      = NewErrorType(http.StatusConflict, ruck.ErrorCodeTooManyWebhooks, "Too many webhooks")
+	ErrNoSuchWebhook            = errors.New("no such webhook")
+	ErrTooManyWebhooks          = errors.New("too many webhooks")
+	ErrLocalWebhookReceiver     = errors.New("webhook receiver in a local network")
+
+	// AllowLocalWebhooks allows receivers on the server itself and in private networks. Otherwise
+	// members could use the webhooks to reach services which aren't public.
+	AllowLocalWebhooks bool
+
+	// localNetworks are the private networks in addition to loopback and link-local addresses.
+	localNetworks = mustParseCIDRs("10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+	// webhookClient sends the webhook requests. Redirects aren't followed, the receiver must
+	// respond with a 2xx status. The address is checked after the name was resolved, so that a
+	// public name can't point to a local address.
+	webhookClient = &http.Client{
+		Timeout: 10 * time.Second,
+		// no proxy is used, it would connect to the receiver instead of the server
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 10 * time.Second,
+				Control: checkWebhookReceiverAddress,
+			}).DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	// webhookWakeUp notifies the worker about new deliveries.
+	webhookWakeUp = make(chan struct{}, 1)
+)
+
+// webhookModel is the stored form of a webhook.
+type webhookModel struct {
+	ID        string          `bson:"id"`
+	GroupID   string          `bson:"groupid"`
+	URL       string          `bson:"url"`
+	Secret    string          `bson:"secret"`
+	Events    []api.EventType `bson:"events"`
+	CreatedBy string          `bson:"createdby"`
+	CreatedAt time.Time       `bson:"createdat"`
+}
+
+func (m *webhookModel) toWebhook() *api.Webhook {
+	events := m.Events
+	if events == nil {
+		events = []api.EventType{}
+	}
+	return &api.Webhook{
+		ID:        m.ID,
+		GroupID:   m.GroupID,
+		URL:       m.URL,
+		Events:    events,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// webhookDeliveryModel is a request to a webhook. It contains the URL and the secret, so that the
+// final events of a group are delivered after the group and its webhooks have been deleted.
+type webhookDeliveryModel struct {
+	ID             string                    `bson:"id"`
+	WebhookID      string                    `bson:"webhookid"`
+	GroupID        string                    `bson:"groupid"`
+	URL            string                    `bson:"url"`
+	Secret         string                    `bson:"secret"`
+	EventID        string                    `bson:"eventid"`
+	EventType      api.EventType             `bson:"eventtype"`
+	Payload        []byte                    `bson:"payload"`
+	Status         api.WebhookDeliveryStatus `bson:"status"`
+	Attempts       int                       `bson:"attempts"`
+	ResponseStatus int                       `bson:"responsestatus"`
+	Error          string                    `bson:"error"`
+	CreatedAt      time.Time                 `bson:"createdat"`
+	LastAttempt    time.Time                 `bson:"lastattempt"`
+	NextAttempt    time.Time                 `bson:"nextattempt"`
+}
+
+func (m *webhookDeliveryModel) toWebhookDelivery() *api.WebhookDelivery {
+	delivery := &api.WebhookDelivery{
+		ID:             m.ID,
+		WebhookID:      m.WebhookID,
+		EventID:        m.EventID,
+		EventType:      m.EventType,
+		Status:         m.Status,
+		Attempts:       m.Attempts,
+		ResponseStatus: m.ResponseStatus,
+		Error:          m.Error,
+		CreatedAt:      m.CreatedAt,
+	}
+	if !m.LastAttempt.IsZero() {
+		lastAttempt := m.LastAttempt
+		delivery.LastAttempt = &lastAttempt
+	}
+	if m.Status == api.WebhookDeliveryPending {
+		nextAttempt := m.NextAttempt
+		delivery.NextAttempt = &nextAttempt
+	}
+	return delivery
+}
+
+func getWebhookId(r *http.Request) string {
+	var vars = mux.Vars(r)
+	webhookId, ok := vars["webhookId"]
+	if !ok || webhookId == "" {
+		panic("Can't read webhook id")
+	}
+	return webhookId
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// isLocalIP returns true for addresses of the server itself and of private networks.
+func isLocalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, network := range localNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebhookReceiverAddress is called before a connection to a receiver is established.
+func checkWebhookReceiverAddress(network, address string, _ syscall.RawConn) error {
+	if AllowLocalWebhooks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isLocalIP(ip) {
+		return fmt.Errorf("%w: %s", ErrLocalWebhookReceiver, address)
+	}
+	return nil
+}
+
+// validateWebhookRequest checks the request and returns the error to send to the client.
+func validateWebhookRequest(request *api.WebhookRequest) *Error {
+	if len(request.URL) > maxWebhookURLLength {
+		return HttpErrInvalidWebhookURL.CauseString("URL too long")
+	}
+	webhookURL, err := url.Parse(request.URL)
+	if err != nil {
+		return HttpErrInvalidWebhookURL.Cause(err)
+	}
+	if (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+		return HttpErrInvalidWebhookURL.Causef("invalid webhook URL '%s'", request.URL)
+	}
+	// names are checked when they are resolved for the delivery
+	if !AllowLocalWebhooks {
+		host := webhookURL.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && isLocalIP(ip)) {
+			return HttpErrInvalidWebhookURL.Causef("local webhook URL '%s'", request.URL)
+		}
+	}
+	if len(request.Secret) > maxWebhookSecretLength {
+		return HttpErrInvalidWebhookSecret.CauseString("secret too long")
+	}
+	for _, eventType := range request.Events {
+		if !eventType.IsValid() {
+			return HttpErrInvalidEventType.Causef("invalid event type '%s'", eventType)
+		}
+	}
+	return nil
+}
+
+func createWebhook(ctx context.Context, groupId string, userName string, request *api.WebhookRequest) (*api.Webhook, error) {
+	count, err := webhookCollection.CountDocuments(ctx, bson.M{"groupid": groupId})
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxWebhooksPerGroup {
+		return nil, ErrTooManyWebhooks
+	}
+	secret := request.Secret
+	if secret == "" {
+		secret, err = generateSecretToken()
+		if err != nil {
+			return nil, err
+		}
+	}
+	model := webhookModel{
+		ID:        generateId(),
+		GroupID:   groupId,
+		URL:       request.URL,
+		Secret:    secret,
+		Events:    request.Events,
+		CreatedBy: userName,
+		CreatedAt: time.Now(),
+	}
+	if _, err := webhookCollection.InsertOne(ctx, &model); err != nil {
+		return nil, fmt.Errorf("failed to store webhook: %s", err)
+	}
+	webhook := model.toWebhook()
+	webhook.Secret = secret
+	return webhook, nil
+}
+
+func getWebhooks(ctx context.Context, groupId string) ([]*webhookModel, error) {
+	results := []*webhookModel{}
+	cursor, err := webhookCollection.Find(ctx, bson.M{"groupid": groupId},
+		options.Find().SetSort(bson.M{"createdat": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func getWebhook(ctx context.Context, groupId string, webhookId string) (*webhookModel, error) {
+	var model webhookModel
+	err := webhookCollection.FindOne(ctx, bson.M{"id": webhookId, "groupid": groupId}).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNoSuchWebhook
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// deleteWebhook deletes the webhook and its delivery log.
+func deleteWebhook(ctx context.Context, groupId string, webhookId string) error {
+	result, err := webhookCollection.DeleteOne(ctx, bson.M{"id": webhookId, "groupid": groupId})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNoSuchWebhook
+	}
+	_, err = webhookDeliveryCollection.DeleteMany(ctx, bson.M{"webhookid": webhookId})
+	return err
+}
+
+// deleteWebhooksOfGroup deletes the webhooks of a deleted group. Pending deliveries are still sent.
+func deleteWebhooksOfGroup(ctx context.Context, groupId string) error {
+	_, err := webhookCollection.DeleteMany(ctx, bson.M{"groupid": groupId})
+	return err
+}
+
+func getWebhookDeliveries(ctx context.Context, webhookId string) ([]*api.WebhookDelivery, error) {
+	results := []*api.WebhookDelivery{}
+	cursor, err := webhookDeliveryCollection.Find(ctx, bson.M{"webhookid": webhookId},
+		options.Find().SetSort(bson.M{"createdat": -1}).SetLimit(maxListedWebhookDeliveries))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var model webhookDeliveryModel
+		if err := cursor.Decode(&model); err != nil {
+			return nil, err
+		}
+		results = append(results, model.toWebhookDelivery())
+	}
+	return results, cursor.Err()
+}
+
+func newWebhookDelivery(webhook *webhookModel, event *api.Event) (*webhookDeliveryModel, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &webhookDeliveryModel{
+		ID:          generateId(),
+		WebhookID:   webhook.ID,
+		GroupID:     webhook.GroupID,
+		URL:         webhook.URL,
+		Secret:      webhook.Secret,
+		EventID:     event.ID,
+		EventType:   event.Type,
+		Payload:     payload,
+		Status:      api.WebhookDeliveryPending,
+		CreatedAt:   now,
+		NextAttempt: now,
+	}, nil
+}
+
+// enqueueWebhookDeliveries stores a delivery of the event for every webhook of the group which
+// subscribed to it. The worker sends them in the background.
+func enqueueWebhookDeliveries(event *api.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	webhooks, err := getWebhooks(ctx, event.GroupID)
+	if err != nil {
+		log.Printf("Failed to get webhooks of group %s: %s\n", event.GroupID, err)
+		return
+	}
+	var deliveries []interface{}
+	for _, webhook := range webhooks {
+		if !webhook.toWebhook().Accepts(event.Type) {
+			continue
+		}
+		delivery, err := newWebhookDelivery(webhook, event)
+		if err != nil {
+			log.Printf("Failed to create delivery of event %s: %s\n", event.ID, err)
+			return
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	if _, err := webhookDeliveryCollection.InsertMany(ctx, deliveries); err != nil {
+		log.Printf("Failed to store webhook deliveries of event %s: %s\n", event.ID, err)
+		return
+	}
+	select {
+	case webhookWakeUp <- struct{}{}:
+	default:
+	}
+}
+
+// attemptWebhookDelivery sends the delivery once and records the result. It returns true if the
+// receiver accepted it.
+func attemptWebhookDelivery(ctx context.Context, delivery *webhookDeliveryModel) bool {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttempt = now
+	delivery.ResponseStatus = 0
+	err := sendWebhookRequest(ctx, delivery, now)
+	if err == nil {
+		delivery.Status = api.WebhookDeliveryDelivered
+		delivery.Error = ""
+		return true
+	}
+	delivery.Error = err.Error()
+	return false
+}
+
+func sendWebhookRequest(ctx context.Context, delivery *webhookDeliveryModel, now time.Time) error {
+	request, err := http.NewRequestWithContext(ctx, "POST", delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "ruck-webhook/"+ruck.Version)
+	request.Header.Set(api.WebhookEventHeader, string(delivery.EventType))
+	request.Header.Set(api.WebhookDeliveryHeader, delivery.ID)
+	request.Header.Set(api.WebhookTimestampHeader, timestamp)
+	request.Header.Set(api.WebhookSignatureHeader, api.WebhookSignature(delivery.Secret, timestamp, delivery.Payload))
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	// read a part of the body to allow reusing the connection
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64*1024))
+	delivery.ResponseStatus = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("receiver responded with %s", response.Status)
+	}
+	return nil
+}
+
+// scheduleWebhookRetry sets the time of the next attempt or marks the delivery as failed.
+func scheduleWebhookRetry(delivery *webhookDeliveryModel) {
+	if delivery.Attempts >= maxWebhookAttempts {
+		delivery.Status = api.WebhookDeliveryFailed
+		return
+	}
+	delivery.NextAttempt = delivery.LastAttempt.Add(webhookRetryDelay << uint(delivery.Attempts-1))
+}
+
+// saveWebhookDelivery writes the delivery to the delivery log.
+var saveWebhookDelivery = func(ctx context.Context, delivery *webhookDeliveryModel) error {
+	_, err := webhookDeliveryCollection.ReplaceOne(ctx, bson.M{"id": delivery.ID}, delivery)
+	return err
+}
+
+// deliverWebhook attempts the delivery, schedules a retry if it failed and records the attempt in
+// the delivery log.
+func deliverWebhook(ctx context.Context, delivery *webhookDeliveryModel) {
+	if !attemptWebhookDelivery(ctx, delivery) {
+		scheduleWebhookRetry(delivery)
+		log.Printf("Webhook delivery %s to %s failed (attempt %d): %s\n",
+			delivery.ID, delivery.URL, delivery.Attempts, delivery.Error)
+	}
+	if err := saveWebhookDelivery(ctx, delivery); err != nil {
+		log.Printf("Failed to save webhook delivery %s: %s\n", delivery.ID, err)
+	}
+}
+
+// deliverNextWebhook sends the next due delivery. It returns false if there is none.
+func deliverNextWebhook(ctx context.Context) bool {
+	now := time.Now()
+	var delivery webhookDeliveryModel
+	err := webhookDeliveryCollection.FindOneAndUpdate(ctx, bson.M{
+		"status":      api.WebhookDeliveryPending,
+		"nextattempt": bson.M{"$lte": now},
+	}, bson.M{
+		"$set": bson.M{"nextattempt": now.Add(webhookDeliveryLease)},
+	}, options.FindOneAndUpdate().SetSort(bson.M{"nextattempt": 1})).Decode(&delivery)
+	if err == mongo.ErrNoDocuments {
+		return false
+	}
+	if err != nil {
+		log.Printf("Failed to get next webhook delivery: %s\n", err)
+		return false
+	}
+	deliverWebhook(ctx, &delivery)
+	return true
+}
+
+// RunWebhookWorker sends the pending webhook deliveries until the context is done.
+func RunWebhookWorker(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		for deliverNextWebhook(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-webhookWakeUp:
+		}
+	}
+}
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	group := groupOfRequest(w, r)
+	if group == nil {
+		return
+	}
+	models, err := getWebhooks(r.Context(), group.ID)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	webhooks := make([]*api.Webhook, 0, len(models))
+	for _, model := range models {
+		webhooks = append(webhooks, model.toWebhook())
+	}
+	writeResponse(w, r, webhooks)
+}
+
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var request api.WebhookRequest
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	group := groupOfRequest(w, r)
+	if group == nil {
+		return
+	}
+	if err := decodeRequest(r, &request); err != nil {
+		ErrInvalidJsonBody.Cause(err).Write(w, r)
+		return
+	}
+	if httpErr := validateWebhookRequest(&request); httpErr != nil {
+		httpErr.Write(w, r)
+		return
+	}
+	webhook, err := createWebhook(r.Context(), group.ID, userName, &request)
+	if err == ErrTooManyWebhooks {
+		HttpErrTooManyWebhooks.Causef("group %s has %d webhooks", group.ID, maxWebhooksPerGroup).Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	log.Printf("Created webhook %s for group %s\n", webhook.ID, group.ID)
+	writeResponseWithStatus(w, r, http.StatusCreated, webhook)
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	group := groupOfRequest(w, r)
+	if group == nil {
+		return
+	}
+	webhookId := getWebhookId(r)
+	err := deleteWebhook(r.Context(), group.ID, webhookId)
+	switch err {
+	case nil:
+		log.Printf("Deleted webhook %s of group %s\n", webhookId, group.ID)
+		w.WriteHeader(http.StatusNoContent)
+	case ErrNoSuchWebhook:
+		HttpErrWebhookNotFound.Cause(err).Write(w, r)
+	default:
+		HttpErrInternal.Cause(err).Write(w, r)
+	}
+}
+
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	group := groupOfRequest(w, r)
+	if group == nil {
+		return
+	}
+	webhook, err := getWebhook(r.Context(), group.ID, getWebhookId(r))
+	if err == ErrNoSuchWebhook {
+		HttpErrWebhookNotFound.Cause(err).Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	deliveries, err := getWebhookDeliveries(r.Context(), webhook.ID)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, deliveries)
+}
+
+// TestWebhook sends a ping event to the webhook and returns the result. The ping isn't retried.
+func TestWebhook(w http.ResponseWriter, r *http.Request) {
+	group := groupOfRequest(w, r)
+	if group == nil {
+		return
+	}
+	userName, err := GetUserNameFromRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	webhook, err := getWebhook(r.Context(), group.ID, getWebhookId(r))
+	if err == ErrNoSuchWebhook {
+		HttpErrWebhookNotFound.Cause(err).Write(w, r)
+		return
+	} else if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	event := newEvent(api.EventPing, group.ID, userName)
+	event.Group = api.NewGroup(group)
+	delivery, err := newWebhookDelivery(webhook, event)
+	if err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	if !attemptWebhookDelivery(r.Context(), delivery) {
+		delivery.Status = api.WebhookDeliveryFailed
+	}
+	if _, err := webhookDeliveryCollection.InsertOne(r.Context(), delivery); err != nil {
+		HttpErrInternal.Cause(err).Write(w, r)
+		return
+	}
+	writeResponse(w, r, delivery.toWebhookDelivery())
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck"
+	"github.com/coffeemakr/ruck/api"
+)
+
+// webhookReceiver is a webhook endpoint which responds with the given status codes in turn. Local
+// receivers are allowed until the test ends.
+type webhookReceiver struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses}
+	allowLocalWebhooks(t, true)
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		receiver.mutex.Lock()
+		status := receiver.statuses[len(receiver.requests)%len(receiver.statuses)]
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		receiver.mutex.Unlock()
+		if status == http.StatusFound {
+			w.Header().Set("Location", "/elsewhere")
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func allowLocalWebhooks(t *testing.T, allowed bool) {
+	previous := AllowLocalWebhooks
+	AllowLocalWebhooks = allowed
+	t.Cleanup(func() {
+		AllowLocalWebhooks = previous
+	})
+}
+
+func (r *webhookReceiver) requestCount() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.requests)
+}
+
+// recordWebhookDeliveries replaces the delivery log until the test ends and returns the saved copies.
+func recordWebhookDeliveries(t *testing.T) *[]webhookDeliveryModel {
+	t.Helper()
+	var saved []webhookDeliveryModel
+	previous := saveWebhookDelivery
+	saveWebhookDelivery = func(ctx context.Context, delivery *webhookDeliveryModel) error {
+		saved = append(saved, *delivery)
+		return nil
+	}
+	t.Cleanup(func() {
+		saveWebhookDelivery = previous
+	})
+	return &saved
+}
+
+func newTestWebhookDelivery(t *testing.T, url string) *webhookDeliveryModel {
+	t.Helper()
+	webhook := &webhookModel{ID: "webhook", GroupID: "group", URL: url, Secret: "top secret"}
+	event := newEvent(api.EventTaskCompleted, "group", "alice")
+	delivery, err := newWebhookDelivery(webhook, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delivery
+}
+
+func TestWebhookRequestIsSigned(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	recordWebhookDeliveries(t)
+	delivery := newTestWebhookDelivery(t, receiver.URL)
+
+	deliverWebhook(context.Background(), delivery)
+
+	if receiver.requestCount() != 1 {
+		t.Fatalf("expected 1 request, got %d", receiver.requestCount())
+	}
+	request, body := receiver.requests[0], receiver.bodies[0]
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("unexpected body %s", body)
+	}
+	timestamp := request.Header.Get(api.WebhookTimestampHeader)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Errorf("invalid timestamp %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte("top secret"))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if expected := "sha256=" + hex.EncodeToString(mac.Sum(nil)); request.Header.Get(api.WebhookSignatureHeader) != expected {
+		t.Errorf("expected signature %s, got %s", expected, request.Header.Get(api.WebhookSignatureHeader))
+	}
+	for header, expected := range map[string]string{
+		"Content-Type":            "application/json",
+		"User-Agent":              "ruck-webhook/" + ruck.Version,
+		api.WebhookEventHeader:    string(api.EventTaskCompleted),
+		api.WebhookDeliveryHeader: delivery.ID,
+	} {
+		if actual := request.Header.Get(header); actual != expected {
+			t.Errorf("expected %s header %q, got %q", header, expected, actual)
+		}
+	}
+}
+
+func TestWebhookDeliveryRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		// attempts are the number of attempts until the delivery is done
+		attempts       int
+		status         api.WebhookDeliveryStatus
+		responseStatus int
+	}{
+		{"ok", []int{http.StatusOK}, 1, api.WebhookDeliveryDelivered, http.StatusOK},
+		{"no content", []int{http.StatusNoContent}, 1, api.WebhookDeliveryDelivered, http.StatusNoContent},
+		{"server error once", []int{http.StatusServiceUnavailable, http.StatusOK}, 2, api.WebhookDeliveryDelivered, http.StatusOK},
+		{"server error", []int{http.StatusInternalServerError}, maxWebhookAttempts, api.WebhookDeliveryFailed, http.StatusInternalServerError},
+		{"redirect", []int{http.StatusFound}, maxWebhookAttempts, api.WebhookDeliveryFailed, http.StatusFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t, test.statuses...)
+			saved := recordWebhookDeliveries(t)
+			delivery := newTestWebhookDelivery(t, receiver.URL)
+
+			// the worker picks the delivery up again as long as it is pending
+			for delivery.Status == api.WebhookDeliveryPending && delivery.Attempts < maxWebhookAttempts+1 {
+				deliverWebhook(context.Background(), delivery)
+			}
+
+			if receiver.requestCount() != test.attempts {
+				t.Errorf("expected %d requests, got %d", test.attempts, receiver.requestCount())
+			}
+			if len(*saved) != test.attempts {
+				t.Fatalf("expected %d saved attempts, got %d", test.attempts, len(*saved))
+			}
+			for i, entry := range *saved {
+				if entry.ID != delivery.ID || entry.Attempts != i+1 || entry.LastAttempt.IsZero() {
+					t.Errorf("unexpected log entry %d: %+v", i, entry)
+				}
+				if i == len(*saved)-1 {
+					break
+				}
+				if entry.Status != api.WebhookDeliveryPending || entry.Error == "" || entry.ResponseStatus < 300 {
+					t.Errorf("attempt %d must be pending with an error: %+v", i+1, entry)
+				}
+				if delay := entry.NextAttempt.Sub(entry.LastAttempt); delay != webhookRetryDelay<<uint(i) {
+					t.Errorf("attempt %d is retried after %s", i+1, delay)
+				}
+			}
+			last := (*saved)[len(*saved)-1]
+			if last.Status != test.status || last.ResponseStatus != test.responseStatus {
+				t.Errorf("expected %s with response %d, got %s with response %d",
+					test.status, test.responseStatus, last.Status, last.ResponseStatus)
+			}
+			if (last.Status == api.WebhookDeliveryDelivered) != (last.Error == "") {
+				t.Errorf("unexpected error %q of %s delivery", last.Error, last.Status)
+			}
+		})
+	}
+}
+
+func TestWebhookDeliveryUnreachable(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	saved := recordWebhookDeliveries(t)
+	delivery := newTestWebhookDelivery(t, receiver.URL)
+	receiver.Close()
+
+	deliverWebhook(context.Background(), delivery)
+
+	if len(*saved) != 1 {
+		t.Fatalf("expected 1 saved attempt, got %d", len(*saved))
+	}
+	entry := (*saved)[0]
+	if entry.Status != api.WebhookDeliveryPending || entry.ResponseStatus != 0 || entry.Error == "" {
+		t.Errorf("unexpected log entry %+v", entry)
+	}
+	if !entry.NextAttempt.Equal(entry.LastAttempt.Add(webhookRetryDelay)) {
+		t.Errorf("unexpected retry at %s after attempt at %s", entry.NextAttempt, entry.LastAttempt)
+	}
+}
+
+func TestWebhookDeliveryToLocalReceiver(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	allowLocalWebhooks(t, false)
+	saved := recordWebhookDeliveries(t)
+	delivery := newTestWebhookDelivery(t, receiver.URL)
+
+	deliverWebhook(context.Background(), delivery)
+
+	if receiver.requestCount() != 0 {
+		t.Errorf("expected no request, got %d", receiver.requestCount())
+	}
+	if len(*saved) != 1 {
+		t.Fatalf("expected 1 saved attempt, got %d", len(*saved))
+	}
+	if entry := (*saved)[0]; !strings.Contains(entry.Error, ErrLocalWebhookReceiver.Error()) {
+		t.Errorf("unexpected error %q", entry.Error)
+	}
+}
+
+func TestCheckWebhookReceiverAddress(t *testing.T) {
+	allowLocalWebhooks(t, false)
+	for address, local := range map[string]bool{
+		"127.0.0.1:80":          true,
+		"127.1.2.3:443":         true,
+		"[::1]:80":              true,
+		"0.0.0.0:80":            true,
+		"[::]:80":               true,
+		"10.1.2.3:80":           true,
+		"172.16.0.1:80":         true,
+		"172.31.255.255:80":     true,
+		"192.168.1.1:80":        true,
+		"100.64.0.1:80":         true,
+		"169.254.169.254:80":    true,
+		"[fe80::1]:80":          true,
+		"[fd00::1]:80":          true,
+		"[::ffff:127.0.0.1]:80": true,
+		"[::ffff:10.0.0.1]:80":  true,
+		"172.32.0.1:80":         false,
+		"192.0.2.1:443":         false,
+		"[2001:db8::1]:443":     false,
+	} {
+		err := checkWebhookReceiverAddress("tcp", address, nil)
+		if local != errors.Is(err, ErrLocalWebhookReceiver) {
+			t.Errorf("unexpected result for %s: %v", address, err)
+		}
+	}
+	allowLocalWebhooks(t, true)
+	if err := checkWebhookReceiverAddress("tcp", "127.0.0.1:80", nil); err != nil {
+		t.Errorf("local receiver rejected: %v", err)
+	}
+}
+
+func TestValidateWebhookRequestRejectsLocalURLs(t *testing.T) {
+	allowLocalWebhooks(t, false)
+	for webhookURL, valid := range map[string]bool{
+		"https://hooks.example.com/ruck": true,
+		"http://192.0.2.1:8080/ruck":     true,
+		"http://localhost:8080/ruck":     false,
+		"http://127.0.0.1/ruck":          false,
+		"http://[::1]/ruck":              false,
+		"http://169.254.169.254/latest":  false,
+		"http://10.0.0.1/ruck":           false,
+	} {
+		httpErr := validateWebhookRequest(&api.WebhookRequest{URL: webhookURL})
+		if (httpErr == nil) != valid {
+			t.Errorf("unexpected result for %s: %v", webhookURL, httpErr)
+		}
+		if httpErr != nil && httpErr.Type != HttpErrInvalidWebhookURL {
+			t.Errorf("unexpected error type %s for %s", httpErr.Type.Code, webhookURL)
+		}
+	}
+	allowLocalWebhooks(t, true)
+	if httpErr := validateWebhookRequest(&api.WebhookRequest{URL: "http://localhost:8080/ruck"}); httpErr != nil {
+		t.Errorf("local URL rejected: %v", httpErr)
+	}
+}
@@ -0,0 +1,125 @@
+// Package ical writes calendars in the iCalendar format (RFC 5545).
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	utcTimeFormat = "20060102T150405Z"
+	// maxLineLength is the maximal length of a content line in octets without the line break.
+	maxLineLength = 75
+)
+
+// Event is a VEVENT at a point in time.
+type Event struct {
+	// UID has to be globally unique and stable for the same event.
+	UID         string
+	Start       time.Time
+	Summary     string
+	Description string
+	Categories  []string
+}
+
+// Calendar is a VCALENDAR with events.
+type Calendar struct {
+	ProdID string
+	// Name is shown by calendar apps which support X-WR-CALNAME.
+	Name string
+	// RefreshInterval is a hint for calendar apps how often they should reload the calendar.
+	RefreshInterval time.Duration
+	Events          []*Event
+}
+
+// escapeText escapes a TEXT value.
+func escapeText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// formatDuration formats the duration in whole minutes or hours.
+func formatDuration(duration time.Duration) string {
+	minutes := int(duration / time.Minute)
+	if minutes%60 == 0 {
+		return fmt.Sprintf("PT%dH", minutes/60)
+	}
+	return fmt.Sprintf("PT%dM", minutes)
+}
+
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes the content line and folds it after 75 octets without splitting UTF-8 characters.
+func (w *writer) line(name string, value string) {
+	line := name + ":" + value
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.write(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// the space of the continuation line counts towards its length
+		limit = maxLineLength - 1
+	}
+	w.write(line + "\r\n")
+}
+
+func (w *writer) write(s string) {
+	if w.err == nil {
+		_, w.err = w.w.WriteString(s)
+	}
+}
+
+// Encode writes the calendar. All times are written in UTC.
+func (c *Calendar) Encode(out io.Writer, now time.Time) error {
+	w := &writer{w: bufio.NewWriter(out)}
+	stamp := now.UTC().Format(utcTimeFormat)
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", c.ProdID)
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		w.line("X-WR-CALNAME", escapeText(c.Name))
+	}
+	if c.RefreshInterval > 0 {
+		w.line("REFRESH-INTERVAL;VALUE=DURATION", formatDuration(c.RefreshInterval))
+		w.line("X-PUBLISHED-TTL", formatDuration(c.RefreshInterval))
+	}
+	for _, event := range c.Events {
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", event.UID)
+		w.line("DTSTAMP", stamp)
+		w.line("DTSTART", event.Start.UTC().Format(utcTimeFormat))
+		w.line("SUMMARY", escapeText(event.Summary))
+		if event.Description != "" {
+			w.line("DESCRIPTION", escapeText(event.Description))
+		}
+		if len(event.Categories) != 0 {
+			categories := make([]string, len(event.Categories))
+			for i, category := range event.Categories {
+				categories[i] = escapeText(category)
+			}
+			w.line("CATEGORIES", strings.Join(categories, ","))
+		}
+		w.line("END", "VEVENT")
+	}
+	w.line("END", "VCALENDAR")
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
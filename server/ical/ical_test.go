@@ -0,0 +1,134 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		text    string
+		escaped string
+	}{
+		{"Clean the kitchen", "Clean the kitchen"},
+		{`C:\Users`, `C:\\Users`},
+		{"bread; milk, eggs", `bread\; milk\, eggs`},
+		{"first\nsecond\r\nthird", `first\nsecond\nthird`},
+		{`\;`, `\\\;`},
+		{"Küche: 10€", "Küche: 10€"},
+	}
+	for _, test := range tests {
+		if escaped := escapeText(test.text); escaped != test.escaped {
+			t.Errorf("escapeText(%q) = %q, expected %q", test.text, escaped, test.escaped)
+		}
+	}
+}
+
+// encodeEvent encodes a calendar with the event and returns the content lines of the event.
+func encodeEvent(t *testing.T, event *Event) []string {
+	t.Helper()
+	var buffer bytes.Buffer
+	calendar := &Calendar{ProdID: "-//ruck//test//EN", Events: []*Event{event}}
+	if err := calendar.Encode(&buffer, time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	output := buffer.String()
+	if !strings.HasSuffix(output, "\r\n") {
+		t.Fatalf("the last line isn't terminated: %q", output)
+	}
+	lines := strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
+	for _, line := range lines {
+		if len(line) > maxLineLength {
+			t.Errorf("line has %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line splits a UTF-8 character: %q", line)
+		}
+		if strings.Contains(line, "\n") {
+			t.Errorf("line contains a line feed: %q", line)
+		}
+	}
+	return lines
+}
+
+// unfold joins the continuation lines to the content lines.
+func unfold(lines []string) []string {
+	var result []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, " ") && len(result) != 0 {
+			result[len(result)-1] += line[1:]
+		} else {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string
+	}{
+		{"short", "Clean the kitchen"},
+		{"exactly 75 octets", strings.Repeat("a", maxLineLength-len("SUMMARY:"))},
+		{"76 octets", strings.Repeat("a", maxLineLength-len("SUMMARY:")+1)},
+		{"ascii", strings.Repeat("Take out the trash. ", 12)},
+		{"two byte characters", strings.Repeat("ü", 100)},
+		{"three byte characters at the limit", "a" + strings.Repeat("€", 60)},
+		{"four byte characters", strings.Repeat("🧹", 50)},
+		{"escaped", strings.Repeat("milk, eggs; bread\n", 10)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := encodeEvent(t, &Event{UID: "task-1@ruck", Start: time.Date(2020, 5, 2, 8, 0, 0, 0, time.UTC), Summary: test.summary})
+			expected := "SUMMARY:" + escapeText(test.summary)
+			found := false
+			for _, line := range unfold(lines) {
+				if strings.HasPrefix(line, "SUMMARY:") {
+					found = true
+					if line != expected {
+						t.Errorf("unfolded to %q, expected %q", line, expected)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("summary missing in %q", lines)
+			}
+			if folded := len(expected) > maxLineLength; folded != (len(lines) != len(unfold(lines))) {
+				t.Errorf("expected folding %t of %d octets: %q", folded, len(expected), lines)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	lines := encodeEvent(t, &Event{
+		UID:         "task-1-20200502@ruck",
+		Start:       time.Date(2020, 5, 2, 10, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+		Summary:     "Clean, the kitchen",
+		Description: "Group: Home\nAssigned to alice",
+		Categories:  []string{"Home", "a,b"},
+	})
+	expected := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//ruck//test//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"BEGIN:VEVENT",
+		"UID:task-1-20200502@ruck",
+		"DTSTAMP:20200501T120000Z",
+		"DTSTART:20200502T080000Z",
+		`SUMMARY:Clean\, the kitchen`,
+		`DESCRIPTION:Group: Home\nAssigned to alice`,
+		`CATEGORIES:Home,a\,b`,
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+}
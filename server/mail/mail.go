@@ -0,0 +1,111 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var ErrNoRecipient = errors.New("no recipient")
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	// HTML is sent as alternative to the text if it is set.
+	HTML string
+	// Headers are added to the standard headers, e.g. List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Mailer sends messages to users.
+type Mailer interface {
+	Send(message *Message) error
+}
+
+// SMTPMailer delivers mails through an SMTP server.
+// STARTTLS is used if the server supports it.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) address() string {
+	return m.Host + ":" + strconv.FormatInt(int64(m.Port), 10)
+}
+
+func (m *SMTPMailer) auth() smtp.Auth {
+	if m.Username == "" {
+		return nil
+	}
+	return smtp.PlainAuth("", m.Username, m.Password, m.Host)
+}
+
+func (m *SMTPMailer) Send(message *Message) error {
+	if message.To == "" {
+		return ErrNoRecipient
+	}
+	err := smtp.SendMail(m.address(), m.auth(), m.From, []string{message.To}, m.encode(message))
+	if err != nil {
+		return fmt.Errorf("sending mail to %s failed: %s", message.To, err)
+	}
+	return nil
+}
+
+func (m *SMTPMailer) encode(message *Message) []byte {
+	var buf bytes.Buffer
+	writeHeader(&buf, "From", m.From)
+	writeHeader(&buf, "To", message.To)
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", message.Subject))
+	writeHeader(&buf, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&buf, "MIME-Version", "1.0")
+	names := make([]string, 0, len(message.Headers))
+	for name := range message.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeHeader(&buf, name, message.Headers[name])
+	}
+	if message.HTML == "" {
+		writeHeader(&buf, "Content-Type", "text/plain; charset=utf-8")
+		writeHeader(&buf, "Content-Transfer-Encoding", "8bit")
+		buf.WriteString("\r\n")
+		buf.WriteString(message.Text)
+		return buf.Bytes()
+	}
+	body := multipart.NewWriter(&buf)
+	writeHeader(&buf, "Content-Type", "multipart/alternative; boundary="+body.Boundary())
+	buf.WriteString("\r\n")
+	// the last part is preferred by mail clients
+	writePart(body, "text/plain; charset=utf-8", message.Text)
+	writePart(body, "text/html; charset=utf-8", message.HTML)
+	body.Close()
+	return buf.Bytes()
+}
+
+func writePart(writer *multipart.Writer, contentType string, content string) {
+	part, _ := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	// writes to a buffer don't fail
+	_, _ = part.Write([]byte(content))
+}
+
+func writeHeader(buf *bytes.Buffer, name string, value string) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteString("\r\n")
+}
@@ -0,0 +1,95 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck/server/mail/mailtest"
+)
+
+func newTestMailer(t *testing.T) (*SMTPMailer, *mailtest.Server) {
+	t.Helper()
+	server := mailtest.NewServer()
+	t.Cleanup(server.Close)
+	return &SMTPMailer{Host: server.Host, Port: server.Port, From: "ruck@example.com"}, server
+}
+
+func receive(t *testing.T, server *mailtest.Server) *mailtest.Mail {
+	t.Helper()
+	received, err := server.Receive(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return received
+}
+
+func TestSMTPMailerSendsText(t *testing.T) {
+	mailer, server := newTestMailer(t)
+	err := mailer.Send(&Message{
+		To:      "alice@example.com",
+		Subject: "Grüße",
+		Text:    "Hello alice\n.\nbye\n",
+		Headers: map[string]string{"List-Unsubscribe": "<https://ruck.example.com/unsubscribe>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := receive(t, server)
+	if received.From != "ruck@example.com" || len(received.To) != 1 || received.To[0] != "alice@example.com" {
+		t.Errorf("unexpected envelope from %s to %v", received.From, received.To)
+	}
+	for _, expected := range []string{
+		"From: ruck@example.com\n",
+		"To: alice@example.com\n",
+		"Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\n",
+		"List-Unsubscribe: <https://ruck.example.com/unsubscribe>\n",
+		"Content-Type: text/plain; charset=utf-8\n",
+		// the server reads the lines without CRLF and restores the escaped dot
+		"\n\nHello alice\n.\nbye\n",
+	} {
+		if !strings.Contains(received.Data, expected) {
+			t.Errorf("%q missing:\n%s", expected, received.Data)
+		}
+	}
+}
+
+func TestSMTPMailerSendsAlternatives(t *testing.T) {
+	mailer, server := newTestMailer(t)
+	err := mailer.Send(&Message{
+		To:      "alice@example.com",
+		Subject: "Digest",
+		Text:    "plain text",
+		HTML:    "<p>html</p>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := receive(t, server)
+	text := strings.Index(received.Data, "plain text")
+	html := strings.Index(received.Data, "<p>html</p>")
+	if !strings.Contains(received.Data, "Content-Type: multipart/alternative; boundary=") {
+		t.Errorf("not multipart:\n%s", received.Data)
+	}
+	if text < 0 || html < 0 || html < text {
+		t.Errorf("the HTML part has to follow the text part:\n%s", received.Data)
+	}
+}
+
+func TestSMTPMailerRequiresRecipient(t *testing.T) {
+	mailer, server := newTestMailer(t)
+	if err := mailer.Send(&Message{Subject: "nobody"}); err != ErrNoRecipient {
+		t.Errorf("expected ErrNoRecipient, got %v", err)
+	}
+	if _, err := server.Receive(100 * time.Millisecond); err != mailtest.ErrNoMail {
+		t.Errorf("no mail expected, got %v", err)
+	}
+}
+
+func TestSMTPMailerReportsConnectionErrors(t *testing.T) {
+	mailer, server := newTestMailer(t)
+	server.Close()
+	if err := mailer.Send(&Message{To: "alice@example.com"}); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,143 @@
+// Package mailtest provides a fake SMTP server which records the received mails, e.g. to test an
+// SMTPMailer without delivering the mails.
+package mailtest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoMail = errors.New("no mail received")
+
+// Mail is a received mail.
+type Mail struct {
+	From string
+	To   []string
+	// Data is the message with headers and body.
+	Data string
+}
+
+// Server accepts all mails on a local port. It doesn't support TLS and authentication.
+type Server struct {
+	Host     string
+	Port     int
+	listener net.Listener
+	mails    chan *Mail
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a free port of the loopback interface. It panics if it can't listen.
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("mailtest: failed to listen: " + err.Error())
+	}
+	address := listener.Addr().(*net.TCPAddr)
+	s := &Server{
+		Host:     address.IP.String(),
+		Port:     address.Port,
+		listener: listener,
+		mails:    make(chan *Mail, 100),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close stops accepting connections and waits for the open ones.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	s.wg.Wait()
+}
+
+// Receive returns the next received mail or ErrNoMail after the timeout.
+func (s *Server) Receive(timeout time.Duration) (*Mail, error) {
+	select {
+	case mail := <-s.mails:
+		return mail, nil
+	case <-time.After(timeout):
+		return nil, ErrNoMail
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// address returns the address of a MAIL FROM or RCPT TO command.
+func address(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func (s *Server) handle(netConn net.Conn) {
+	conn := textproto.NewConn(netConn)
+	defer conn.Close()
+	_ = netConn.SetDeadline(time.Now().Add(10 * time.Second))
+	var mail *Mail
+	if conn.PrintfLine("220 mailtest ESMTP") != nil {
+		return
+	}
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			err = conn.PrintfLine("250 mailtest")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			mail = &Mail{From: address(line)}
+			err = conn.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:") && mail != nil:
+			mail.To = append(mail.To, address(line))
+			err = conn.PrintfLine("250 OK")
+		case command == "DATA" && mail != nil:
+			if err = conn.PrintfLine("354 End data with <CR><LF>.<CR><LF>"); err != nil {
+				return
+			}
+			data, readErr := ioutil.ReadAll(conn.DotReader())
+			if readErr != nil {
+				return
+			}
+			mail.Data = string(data)
+			s.mails <- mail
+			mail = nil
+			err = conn.PrintfLine("250 OK")
+		case command == "RSET":
+			mail = nil
+			err = conn.PrintfLine("250 OK")
+		case command == "NOOP":
+			err = conn.PrintfLine("250 OK")
+		case command == "QUIT":
+			_ = conn.PrintfLine("221 Bye")
+			return
+		default:
+			err = conn.PrintfLine("502 Command not implemented")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
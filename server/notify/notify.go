@@ -0,0 +1,64 @@
+// Package notify sends reminders about due, overdue and newly assigned tasks to the users.
+package notify
+
+import (
+	"context"
+	"log"
+
+	"github.com/coffeemakr/ruck"
+)
+
+type ReminderKind string
+
+const (
+	// ReminderDueSoon is sent once the lead time of the group before the due date is reached.
+	ReminderDueSoon ReminderKind = "due_soon"
+	// ReminderOverdue is sent once the due date has passed.
+	ReminderOverdue ReminderKind = "overdue"
+	// ReminderAssigned is sent when the task is assigned to the next member after it was completed.
+	ReminderAssigned ReminderKind = "assigned"
+	// ReminderEscalated is sent to all members of the group when the escalation policy of the group
+	// acts on the overdue task.
+	ReminderEscalated ReminderKind = "escalated"
+)
+
+// Reminder is sent once per kind and due date of a task, assignments whenever the assignee changes.
+type Reminder struct {
+	Kind ReminderKind
+	// Task includes its group and its assignee.
+	Task *ruck.Task
+	// Recipient is the assignee except for escalations.
+	Recipient *ruck.User
+}
+
+// Notifier delivers reminders to the recipient.
+type Notifier interface {
+	Notify(ctx context.Context, reminder *Reminder) error
+}
+
+// Multi sends the reminders to all notifiers. It returns the first error after trying all of them.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, reminder *Reminder) error {
+	var firstErr error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, reminder); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// LogNotifier writes the reminders to the server log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(_ context.Context, reminder *Reminder) error {
+	recipient := ""
+	if reminder.Recipient != nil {
+		recipient = reminder.Recipient.Name
+	}
+	log.Printf("Reminder %s to %s: task %s of group %s is due %s, assigned to %s\n", reminder.Kind,
+		recipient, reminder.Task.ID, reminder.Task.GroupID, reminder.Task.DueDate.Format("2006-01-02 15:04"),
+		reminder.Task.AssigneeName)
+	return nil
+}
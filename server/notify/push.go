@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/coffeemakr/ruck"
+)
+
+type PushPriority int
+
+// Priorities of push messages as used by ntfy.
+const (
+	PushPriorityDefault PushPriority = 3
+	PushPriorityHigh    PushPriority = 4
+)
+
+// PushMessage is sent to the push service of a user.
+type PushMessage struct {
+	Title    string       `json:"title"`
+	Message  string       `json:"message"`
+	Priority PushPriority `json:"priority"`
+	// Tags are shown as emojis by ntfy.
+	Tags []string `json:"tags,omitempty"`
+	// Kind is the kind of the reminder or "test".
+	Kind ReminderKind `json:"kind"`
+	// TaskID is empty for test messages.
+	TaskID string `json:"task_id,omitempty"`
+}
+
+// gotifyMessage is the body of the Gotify message API, which uses priorities from 0 to 10.
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// Push sends the message to the service of the settings.
+func Push(ctx context.Context, client *http.Client, settings *ruck.PushSettings, message *PushMessage) error {
+	var request *http.Request
+	var err error
+	switch settings.Service {
+	case ruck.PushServiceNtfy:
+		request, err = http.NewRequestWithContext(ctx, "POST", settings.URL, strings.NewReader(message.Message))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		// ntfy decodes non-ASCII header values with RFC 2047
+		request.Header.Set("Title", mime.QEncoding.Encode("utf-8", message.Title))
+		request.Header.Set("Priority", strconv.Itoa(int(message.Priority)))
+		if len(message.Tags) != 0 {
+			request.Header.Set("Tags", strings.Join(message.Tags, ","))
+		}
+		if settings.Token != "" {
+			request.Header.Set("Authorization", "Bearer "+settings.Token)
+		}
+	case ruck.PushServiceGotify:
+		body, err := json.Marshal(&gotifyMessage{
+			Title:    message.Title,
+			Message:  message.Message,
+			Priority: int(message.Priority) * 2,
+		})
+		if err != nil {
+			return err
+		}
+		request, err = http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(settings.URL, "/")+"/message", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Gotify-Key", settings.Token)
+	case ruck.PushServiceJSON:
+		body, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		request, err = http.NewRequestWithContext(ctx, "POST", settings.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		if settings.Token != "" {
+			request.Header.Set("Authorization", "Bearer "+settings.Token)
+		}
+	default:
+		return fmt.Errorf("unknown push service '%s'", settings.Service)
+	}
+	request.Header.Set("User-Agent", "ruck-push/"+ruck.Version)
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	// read a part of the body to allow reusing the connection
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64*1024))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("push service responded with %s", response.Status)
+	}
+	return nil
+}
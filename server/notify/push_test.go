@@ -0,0 +1,182 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coffeemakr/ruck"
+)
+
+// receivedPush is a request received by the fake push service.
+type receivedPush struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// newPushService starts a push service which responds with the status and records the requests.
+func newPushService(t *testing.T, status int) (*httptest.Server, *[]receivedPush) {
+	t.Helper()
+	var received []receivedPush
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		received = append(received, receivedPush{method: r.Method, path: r.URL.Path, header: r.Header, body: body})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+var testPushMessage = &PushMessage{
+	Title:    "Küche putzen is overdue",
+	Message:  "Clean the kitchen, it was due yesterday.",
+	Priority: PushPriorityHigh,
+	Tags:     []string{"warning", "broom"},
+	Kind:     ReminderOverdue,
+	TaskID:   "task-1",
+}
+
+func TestPushRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings ruck.PushSettings
+		path     string
+		headers  map[string]string
+		check    func(t *testing.T, push receivedPush)
+	}{
+		{
+			name:     "ntfy",
+			settings: ruck.PushSettings{Service: ruck.PushServiceNtfy, URL: "/my-topic", Token: "tk_secret"},
+			path:     "/my-topic",
+			headers: map[string]string{
+				"Content-Type":  "text/plain; charset=utf-8",
+				"Title":         "=?utf-8?q?K=C3=BCche_putzen_is_overdue?=",
+				"Priority":      "4",
+				"Tags":          "warning,broom",
+				"Authorization": "Bearer tk_secret",
+			},
+			check: func(t *testing.T, push receivedPush) {
+				if string(push.body) != testPushMessage.Message {
+					t.Errorf("unexpected body %q", push.body)
+				}
+				title, err := new(mime.WordDecoder).DecodeHeader(push.header.Get("Title"))
+				if err != nil || title != testPushMessage.Title {
+					t.Errorf("title decoded to %q: %v", title, err)
+				}
+			},
+		},
+		{
+			name:     "ntfy without token",
+			settings: ruck.PushSettings{Service: ruck.PushServiceNtfy, URL: "/my-topic"},
+			path:     "/my-topic",
+			headers:  map[string]string{"Authorization": ""},
+		},
+		{
+			name:     "gotify",
+			settings: ruck.PushSettings{Service: ruck.PushServiceGotify, URL: "/gotify/", Token: "app-token"},
+			path:     "/gotify/message",
+			headers: map[string]string{
+				"Content-Type":  "application/json",
+				"X-Gotify-Key":  "app-token",
+				"Authorization": "",
+			},
+			check: func(t *testing.T, push receivedPush) {
+				var message gotifyMessage
+				if err := json.Unmarshal(push.body, &message); err != nil {
+					t.Fatal(err)
+				}
+				expected := gotifyMessage{Title: testPushMessage.Title, Message: testPushMessage.Message, Priority: 8}
+				if message != expected {
+					t.Errorf("expected %+v, got %+v", expected, message)
+				}
+			},
+		},
+		{
+			name:     "json",
+			settings: ruck.PushSettings{Service: ruck.PushServiceJSON, URL: "/hook", Token: "secret"},
+			path:     "/hook",
+			headers: map[string]string{
+				"Content-Type":  "application/json",
+				"Authorization": "Bearer secret",
+			},
+			check: func(t *testing.T, push receivedPush) {
+				var message PushMessage
+				if err := json.Unmarshal(push.body, &message); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(&message, testPushMessage) {
+					t.Errorf("expected %+v, got %+v", testPushMessage, message)
+				}
+				var fields map[string]json.RawMessage
+				if err := json.Unmarshal(push.body, &fields); err != nil {
+					t.Fatal(err)
+				}
+				for _, name := range []string{"title", "message", "priority", "tags", "kind", "task_id"} {
+					if _, ok := fields[name]; !ok {
+						t.Errorf("field %s missing in %s", name, push.body)
+					}
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, received := newPushService(t, http.StatusOK)
+			settings := test.settings
+			settings.URL = server.URL + settings.URL
+
+			if err := Push(context.Background(), server.Client(), &settings, testPushMessage); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(*received) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(*received))
+			}
+			push := (*received)[0]
+			if push.method != http.MethodPost || push.path != test.path {
+				t.Errorf("expected POST %s, got %s %s", test.path, push.method, push.path)
+			}
+			if !strings.HasPrefix(push.header.Get("User-Agent"), "ruck-push/") {
+				t.Errorf("unexpected User-Agent %q", push.header.Get("User-Agent"))
+			}
+			for header, expected := range test.headers {
+				if actual := push.header.Get(header); actual != expected {
+					t.Errorf("expected %s header %q, got %q", header, expected, actual)
+				}
+			}
+			if test.check != nil {
+				test.check(t, push)
+			}
+		})
+	}
+}
+
+func TestPushErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError} {
+		server, received := newPushService(t, status)
+		settings := &ruck.PushSettings{Service: ruck.PushServiceNtfy, URL: server.URL + "/topic"}
+		err := Push(context.Background(), server.Client(), settings, testPushMessage)
+		if err == nil || !strings.Contains(err.Error(), http.StatusText(status)) {
+			t.Errorf("expected error for status %d, got %v", status, err)
+		}
+		if len(*received) != 1 {
+			t.Errorf("expected 1 request for status %d, got %d", status, len(*received))
+		}
+	}
+
+	settings := &ruck.PushSettings{Service: "pager", URL: "http://127.0.0.1/"}
+	if err := Push(context.Background(), http.DefaultClient, settings, testPushMessage); err == nil {
+		t.Error("expected an error for an unknown service")
+	}
+}
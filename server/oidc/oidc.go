@@ -0,0 +1,248 @@
+// Package oidc implements the parts of OpenID Connect needed to log in users with the
+// authorization code flow and PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/square/go-jose/v3"
+	"github.com/square/go-jose/v3/jwt"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// keyRefreshInterval limits how often the keys are downloaded again for an unknown key ID.
+	keyRefreshInterval = time.Minute
+	maxResponseSize    = 1 << 20
+	// Leeway is the allowed clock skew between ruckd and the provider.
+	Leeway = time.Minute
+)
+
+var (
+	DefaultScopes   = []string{"openid", "profile", "email"}
+	ErrNoIDToken    = errors.New("token response contains no id_token")
+	ErrInvalidNonce = errors.New("nonce of the ID token doesn't match")
+)
+
+// Metadata is the subset of the provider metadata used by ruckd.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of an ID token which are used to identify the user.
+type Claims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"-"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+	Nonce             string `json:"nonce"`
+	// some providers send the boolean as string
+	RawEmailVerified interface{} `json:"email_verified"`
+}
+
+// Provider is an OpenID provider ruckd is registered at as client.
+type Provider struct {
+	Metadata     Metadata
+	ClientID     string
+	ClientSecret string
+	HTTPClient   *http.Client
+
+	keysLock    sync.Mutex
+	keys        *jose.JSONWebKeySet
+	keysFetched time.Time
+}
+
+// Discover loads the metadata of the provider from its well-known configuration.
+func Discover(ctx context.Context, issuer string, clientID string, clientSecret string) (*Provider, error) {
+	provider := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+	err := provider.getJson(ctx, strings.TrimSuffix(issuer, "/")+discoveryPath, &provider.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover provider %s: %w", issuer, err)
+	}
+	if provider.Metadata.Issuer != issuer {
+		return nil, fmt.Errorf("provider returned issuer %q instead of %q", provider.Metadata.Issuer, issuer)
+	}
+	if provider.Metadata.AuthorizationEndpoint == "" || provider.Metadata.TokenEndpoint == "" || provider.Metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("metadata of provider %s is incomplete", issuer)
+	}
+	return provider, nil
+}
+
+func readResponse(response *http.Response, result interface{}) error {
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s: %s", response.Status, body)
+	}
+	return json.Unmarshal(body, result)
+}
+
+func (p *Provider) getJson(ctx context.Context, location string, result interface{}) error {
+	request, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := p.HTTPClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return readResponse(response, result)
+}
+
+// AuthorizationURL returns the URL the user is redirected to for the login at the provider.
+func (p *Provider) AuthorizationURL(redirectURL string, state string, nonce string, codeChallenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {strings.Join(DefaultScopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(p.Metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.Metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange redeems the authorization code and returns the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code string, redirectURL string, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.ClientSecret == "" {
+		// public client
+		form.Set("client_id", p.ClientID)
+	}
+	request, err := http.NewRequest("POST", p.Metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	response, err := p.HTTPClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	var tokenResponse struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := readResponse(response, &tokenResponse); err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	if tokenResponse.IDToken == "" {
+		return "", ErrNoIDToken
+	}
+	return tokenResponse.IDToken, nil
+}
+
+// getKeys returns the signing keys of the provider. They are downloaded again if refresh is true,
+// e.g. if the provider rotated its keys.
+func (p *Provider) getKeys(ctx context.Context, refresh bool) (*jose.JSONWebKeySet, error) {
+	p.keysLock.Lock()
+	defer p.keysLock.Unlock()
+	if p.keys != nil && (!refresh || time.Since(p.keysFetched) < keyRefreshInterval) {
+		return p.keys, nil
+	}
+	var keys jose.JSONWebKeySet
+	if err := p.getJson(ctx, p.Metadata.JWKSURI, &keys); err != nil {
+		return nil, fmt.Errorf("failed to load keys of provider: %w", err)
+	}
+	p.keys = &keys
+	p.keysFetched = time.Now()
+	return p.keys, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of the ID token.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*Claims, error) {
+	token, err := jwt.ParseSigned(rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	var standardClaims jwt.Claims
+	var claims Claims
+	keys, err := p.getKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := token.Claims(keys, &standardClaims, &claims); err != nil {
+		keys, err = p.getKeys(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := token.Claims(keys, &standardClaims, &claims); err != nil {
+			return nil, err
+		}
+	}
+	err = standardClaims.ValidateWithLeeway(jwt.Expected{
+		Issuer:   p.Metadata.Issuer,
+		Audience: jwt.Audience{p.ClientID},
+		Time:     time.Now(),
+	}, Leeway)
+	if err != nil {
+		return nil, err
+	}
+	if standardClaims.Expiry == nil {
+		return nil, jwt.ErrExpired
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+	switch verified := claims.RawEmailVerified.(type) {
+	case bool:
+		claims.EmailVerified = verified
+	case string:
+		claims.EmailVerified = verified == "true"
+	}
+	return &claims, nil
+}
+
+// RandomString returns a random URL-safe string which can be used as state, nonce or code verifier.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE challenge of the verifier.
+func CodeChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,247 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coffeemakr/ruck/server/oidc/oidctest"
+	"github.com/square/go-jose/v3/jwt"
+)
+
+const testRedirectURL = "https://ruck.example.com/sso/callback"
+
+func newTestProvider(t *testing.T, clientSecret string) (*oidctest.Provider, *Provider) {
+	t.Helper()
+	server := oidctest.NewProvider("ruck", clientSecret)
+	t.Cleanup(server.Close)
+	provider, err := Discover(context.Background(), server.Issuer(), "ruck", clientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, provider
+}
+
+func TestDiscover(t *testing.T) {
+	server, provider := newTestProvider(t, "")
+	expected := Metadata{
+		Issuer:                server.Issuer(),
+		AuthorizationEndpoint: server.Issuer() + "/authorize",
+		TokenEndpoint:         server.Issuer() + "/token",
+		JWKSURI:               server.Issuer() + "/keys",
+	}
+	if provider.Metadata != expected {
+		t.Errorf("expected %+v, got %+v", expected, provider.Metadata)
+	}
+
+	// the issuer of the document must be the one which was discovered
+	if _, err := Discover(context.Background(), server.Issuer()+"/", "ruck", ""); err == nil {
+		t.Error("expected an error for a different issuer")
+	}
+	if _, err := Discover(context.Background(), server.Issuer()+"/tenant", "ruck", ""); err == nil {
+		t.Error("expected an error for a missing document")
+	}
+}
+
+func TestAuthorizationURL(t *testing.T) {
+	_, provider := newTestProvider(t, "")
+	location, err := url.Parse(provider.AuthorizationURL(testRedirectURL, "state", "nonce", CodeChallenge("verifier")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := location.Query()
+	for key, expected := range map[string]string{
+		"response_type":         "code",
+		"client_id":             "ruck",
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid profile email",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	} {
+		if query.Get(key) != expected {
+			t.Errorf("expected %s=%s, got %s", key, expected, query.Get(key))
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	for _, clientSecret := range []string{"", "client secret"} {
+		server, provider := newTestProvider(t, clientSecret)
+		nonce, err := RandomString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifier, err := RandomString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := server.Claims("subject-1", nonce)
+		claims["email"] = "alice@example.com"
+		claims["email_verified"] = true
+		claims["preferred_username"] = "alice"
+		code := server.NewCode(server.SignIDToken(claims), testRedirectURL, CodeChallenge(verifier))
+
+		rawIDToken, err := provider.Exchange(context.Background(), code, testRedirectURL, verifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verified, err := provider.VerifyIDToken(context.Background(), rawIDToken, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verified.Subject != "subject-1" || verified.Email != "alice@example.com" || !verified.EmailVerified ||
+			verified.PreferredUsername != "alice" || verified.Nonce != nonce {
+			t.Errorf("unexpected claims %+v", verified)
+		}
+	}
+}
+
+func TestExchangeErrors(t *testing.T) {
+	server, provider := newTestProvider(t, "client secret")
+	idToken := server.SignIDToken(server.Claims("subject-1", "nonce"))
+	tests := []struct {
+		name        string
+		provider    *Provider
+		redirectURL string
+		verifier    string
+	}{
+		{"wrong code verifier", provider, testRedirectURL, "other verifier"},
+		{"wrong redirect URL", provider, "https://evil.example.com/callback", "verifier"},
+		{"wrong client secret", &Provider{Metadata: provider.Metadata, ClientID: "ruck", ClientSecret: "guessed", HTTPClient: provider.HTTPClient}, testRedirectURL, "verifier"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := server.NewCode(idToken, testRedirectURL, CodeChallenge("verifier"))
+			if _, err := test.provider.Exchange(context.Background(), code, test.redirectURL, test.verifier); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	code := server.NewCode(idToken, testRedirectURL, CodeChallenge("verifier"))
+	if _, err := provider.Exchange(context.Background(), code, testRedirectURL, "verifier"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, testRedirectURL, "verifier"); err == nil {
+		t.Error("a code must only be exchanged once")
+	}
+}
+
+func TestVerifyIDTokenErrors(t *testing.T) {
+	server, provider := newTestProvider(t, "")
+	other := oidctest.NewProvider("ruck", "")
+	defer other.Close()
+	now := time.Now()
+	modified := func(changes map[string]interface{}) string {
+		claims := server.Claims("subject-1", "nonce")
+		for key, value := range changes {
+			if value == nil {
+				delete(claims, key)
+			} else {
+				claims[key] = value
+			}
+		}
+		return server.SignIDToken(claims)
+	}
+	tests := []struct {
+		name    string
+		idToken string
+		err     error
+	}{
+		{"wrong nonce", modified(map[string]interface{}{"nonce": "other nonce"}), ErrInvalidNonce},
+		{"missing nonce", modified(map[string]interface{}{"nonce": nil}), ErrInvalidNonce},
+		{"wrong audience", modified(map[string]interface{}{"aud": "other client"}), jwt.ErrInvalidAudience},
+		{"wrong issuer", modified(map[string]interface{}{"iss": other.Issuer()}), jwt.ErrInvalidIssuer},
+		{"expired", modified(map[string]interface{}{"exp": jwt.NewNumericDate(now.Add(-2 * Leeway))}), jwt.ErrExpired},
+		{"without expiry", modified(map[string]interface{}{"exp": nil}), jwt.ErrExpired},
+		{"not yet valid", modified(map[string]interface{}{"nbf": jwt.NewNumericDate(now.Add(2 * Leeway))}), jwt.ErrNotValidYet},
+		{"signed by another provider", other.SignIDToken(server.Claims("subject-1", "nonce")), nil},
+		{"malformed", "not a token", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(context.Background(), test.idToken, "nonce")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+
+	// within the leeway
+	if _, err := provider.VerifyIDToken(context.Background(), modified(map[string]interface{}{
+		"exp": jwt.NewNumericDate(now.Add(-Leeway / 2)),
+	}), "nonce"); err != nil {
+		t.Errorf("expired token within leeway: %v", err)
+	}
+}
+
+func TestVerifyIDTokenEmailVerified(t *testing.T) {
+	server, provider := newTestProvider(t, "")
+	for _, test := range []struct {
+		value    interface{}
+		verified bool
+	}{{true, true}, {false, false}, {"true", true}, {"false", false}, {nil, false}} {
+		claims := server.Claims("subject-1", "nonce")
+		if test.value != nil {
+			claims["email_verified"] = test.value
+		}
+		verified, err := provider.VerifyIDToken(context.Background(), server.SignIDToken(claims), "nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verified.EmailVerified != test.verified {
+			t.Errorf("email_verified %v parsed as %t", test.value, verified.EmailVerified)
+		}
+	}
+}
+
+func TestVerifyIDTokenWithRotatedKey(t *testing.T) {
+	server, provider := newTestProvider(t, "")
+	if _, err := provider.VerifyIDToken(context.Background(), server.SignIDToken(server.Claims("subject-1", "nonce")), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	server.RotateKey()
+	rotated := server.SignIDToken(server.Claims("subject-1", "nonce"))
+
+	// the keys are downloaded at most once per refresh interval
+	if _, err := provider.VerifyIDToken(context.Background(), rotated, "nonce"); err == nil {
+		t.Error("expected an error before the keys may be refreshed")
+	}
+	if server.KeyRequests() != 1 {
+		t.Errorf("expected 1 key request, got %d", server.KeyRequests())
+	}
+
+	provider.keysFetched = time.Now().Add(-keyRefreshInterval)
+	if _, err := provider.VerifyIDToken(context.Background(), rotated, "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if server.KeyRequests() != 2 {
+		t.Errorf("expected 2 key requests, got %d", server.KeyRequests())
+	}
+	// known keys aren't downloaded again
+	if _, err := provider.VerifyIDToken(context.Background(), rotated, "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if server.KeyRequests() != 2 {
+		t.Errorf("expected 2 key requests, got %d", server.KeyRequests())
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// example of RFC 7636, Appendix B
+	if challenge := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); challenge != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("unexpected challenge %s", challenge)
+	}
+	verifier, err := RandomString()
+	if err != nil || len(verifier) < 43 || strings.ContainsAny(verifier, "+/=") {
+		t.Errorf("invalid verifier %q: %v", verifier, err)
+	}
+}
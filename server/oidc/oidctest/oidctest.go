@@ -0,0 +1,183 @@
+// Package oidctest provides a local OpenID provider which serves the discovery document, the
+// signing keys and a token endpoint for the authorization code flow with PKCE.
+package oidctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/square/go-jose/v3"
+	"github.com/square/go-jose/v3/jwt"
+)
+
+// tokenValidity is the validity of the ID tokens returned by Claims.
+const tokenValidity = 5 * time.Minute
+
+// grant is an issued authorization code.
+type grant struct {
+	idToken       string
+	redirectURL   string
+	codeChallenge string
+}
+
+// Provider is a provider for a single client. The client must authenticate with HTTP basic
+// authentication if ClientSecret is set.
+type Provider struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mutex       sync.Mutex
+	key         *jose.JSONWebKey
+	keyIDs      int
+	codes       map[string]*grant
+	codeCount   int
+	keyRequests int
+}
+
+// NewProvider starts a provider on a local port. It panics if no key can be generated.
+func NewProvider(clientID string, clientSecret string) *Provider {
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        make(map[string]*grant),
+	}
+	p.RotateKey()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.serveDiscovery)
+	mux.HandleFunc("/keys", p.serveKeys)
+	mux.HandleFunc("/token", p.serveToken)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// Issuer returns the issuer identifier, which is the URL of the server.
+func (p *Provider) Issuer() string {
+	return p.URL
+}
+
+// RotateKey replaces the signing key. The previous key isn't published any longer.
+func (p *Provider) RotateKey() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic("oidctest: failed to generate key: " + err.Error())
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.keyIDs++
+	p.key = &jose.JSONWebKey{Key: key, KeyID: fmt.Sprintf("key-%d", p.keyIDs), Algorithm: string(jose.ES256), Use: "sig"}
+}
+
+// KeyRequests returns how often the keys were downloaded.
+func (p *Provider) KeyRequests() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.keyRequests
+}
+
+// Claims returns the claims of a valid ID token for the client.
+func (p *Provider) Claims(subject string, nonce string) map[string]interface{} {
+	now := jwt.NewNumericDate(time.Now())
+	return map[string]interface{}{
+		"iss":   p.Issuer(),
+		"sub":   subject,
+		"aud":   p.ClientID,
+		"iat":   now,
+		"exp":   jwt.NewNumericDate(time.Now().Add(tokenValidity)),
+		"nonce": nonce,
+	}
+}
+
+// SignIDToken signs the claims with the current key.
+func (p *Provider) SignIDToken(claims map[string]interface{}) string {
+	p.mutex.Lock()
+	key := p.key
+	p.mutex.Unlock()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	if err != nil {
+		panic("oidctest: failed to create signer: " + err.Error())
+	}
+	raw, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		panic("oidctest: failed to sign token: " + err.Error())
+	}
+	return raw
+}
+
+// NewCode returns an authorization code, which the token endpoint exchanges for the ID token if
+// the redirect URL and the S256 challenge of the code verifier match.
+func (p *Provider) NewCode(idToken string, redirectURL string, codeChallenge string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.codeCount++
+	code := fmt.Sprintf("code-%d", p.codeCount)
+	p.codes[code] = &grant{idToken: idToken, redirectURL: redirectURL, codeChallenge: codeChallenge}
+	return code
+}
+
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func (p *Provider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]string{
+		"issuer":                 p.Issuer(),
+		"authorization_endpoint": p.Issuer() + "/authorize",
+		"token_endpoint":         p.Issuer() + "/token",
+		"jwks_uri":               p.Issuer() + "/keys",
+	})
+}
+
+func (p *Provider) serveKeys(w http.ResponseWriter, r *http.Request) {
+	p.mutex.Lock()
+	p.keyRequests++
+	keys := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{p.key.Public()}}
+	p.mutex.Unlock()
+	writeJson(w, http.StatusOK, &keys)
+}
+
+func (p *Provider) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		// the credentials are form encoded, see RFC 6749, section 2.3.1
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != p.ClientID || clientSecret != p.ClientSecret {
+		writeJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	p.mutex.Lock()
+	code := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mutex.Unlock()
+	hash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if code == nil || code.redirectURL != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(hash[:]) != code.codeChallenge {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]string{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"id_token":     code.idToken,
+	})
+}
@@ -0,0 +1,152 @@
+// Package passhash hashes passwords with Argon2id or bcrypt. The hashes describe their algorithm
+// and parameters, so old hashes can be verified after the configuration changed.
+package passhash
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+
+	// bcryptMaxPasswordLength is the number of bytes bcrypt uses, the rest is ignored.
+	bcryptMaxPasswordLength = 72
+	argon2idPrefix          = "$argon2id$"
+)
+
+var (
+	ErrUnknownFormat   = errors.New("unknown password hash format")
+	ErrInvalidHash     = errors.New("invalid password hash")
+	ErrPasswordTooLong = fmt.Errorf("bcrypt can't hash passwords longer than %d bytes", bcryptMaxPasswordLength)
+
+	// DefaultArgon2id uses the second recommended option of RFC 9106.
+	DefaultArgon2id = &Argon2idHasher{
+		Time:       3,
+		Memory:     64 * 1024,
+		Threads:    4,
+		SaltLength: 16,
+		KeyLength:  32,
+	}
+	DefaultBcrypt = &BcryptHasher{Cost: bcrypt.DefaultCost}
+
+	encoding = base64.RawStdEncoding
+)
+
+// Hasher creates new password hashes.
+type Hasher interface {
+	// Hash returns the encoded hash of the password.
+	Hash(password []byte) ([]byte, error)
+	// NeedsRehash returns true if the hash doesn't use the algorithm or parameters of the hasher.
+	NeedsRehash(hash []byte) bool
+}
+
+// Argon2idHasher hashes passwords with Argon2id. Memory is in KiB.
+type Argon2idHasher struct {
+	Time       uint32
+	Memory     uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+type argon2idHash struct {
+	Argon2idHasher
+	salt []byte
+	key  []byte
+}
+
+// Hash returns the hash in the PHC string format, e.g. $argon2id$v=19$m=65536,t=3,p=4$salt$key
+func (h *Argon2idHasher) Hash(password []byte) ([]byte, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey(password, salt, h.Time, h.Memory, h.Threads, h.KeyLength)
+	return []byte(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, h.Memory, h.Time,
+		h.Threads, encoding.EncodeToString(salt), encoding.EncodeToString(key))), nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash []byte) bool {
+	decoded, err := decodeArgon2id(hash)
+	return err != nil || decoded.Argon2idHasher != *h
+}
+
+func decodeArgon2id(hash []byte) (*argon2idHash, error) {
+	var decoded argon2idHash
+	var version int
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrInvalidHash
+	}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &decoded.Memory, &decoded.Time, &decoded.Threads)
+	if err != nil {
+		return nil, ErrInvalidHash
+	}
+	if decoded.salt, err = encoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if decoded.key, err = encoding.DecodeString(parts[5]); err != nil {
+		return nil, ErrInvalidHash
+	}
+	decoded.SaltLength = uint32(len(decoded.salt))
+	decoded.KeyLength = uint32(len(decoded.key))
+	if decoded.Time == 0 || decoded.Threads == 0 || decoded.KeyLength == 0 {
+		return nil, ErrInvalidHash
+	}
+	return &decoded, nil
+}
+
+// BcryptHasher hashes passwords with bcrypt. It is kept for existing hashes.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password []byte) ([]byte, error) {
+	// bcrypt would silently ignore the rest of the password
+	if len(password) > bcryptMaxPasswordLength {
+		return nil, ErrPasswordTooLong
+	}
+	return bcrypt.GenerateFromPassword(password, h.Cost)
+}
+
+func (h *BcryptHasher) NeedsRehash(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost != h.Cost
+}
+
+// Verify checks the password against a hash created by any of the hashers. An empty hash
+// never matches, e.g. for users that log in with single sign-on.
+func Verify(hash []byte, password []byte) (bool, error) {
+	switch {
+	case len(hash) == 0:
+		return false, nil
+	case bytes.HasPrefix(hash, []byte(argon2idPrefix)):
+		decoded, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		key := argon2.IDKey(password, decoded.salt, decoded.Time, decoded.Memory, decoded.Threads, decoded.KeyLength)
+		return subtle.ConstantTimeCompare(key, decoded.key) == 1, nil
+	case bytes.HasPrefix(hash, []byte("$2")):
+		err := bcrypt.CompareHashAndPassword(hash, password)
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownFormat
+	}
+}
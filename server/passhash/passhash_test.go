@@ -0,0 +1,147 @@
+package passhash
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id is cheap enough for tests.
+var testArgon2id = &Argon2idHasher{Time: 1, Memory: 64, Threads: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	hash, err := testArgon2id.Hash([]byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(hash), "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("unexpected format %s", hash)
+	}
+	if ok, err := Verify(hash, []byte("correct horse")); !ok || err != nil {
+		t.Errorf("correct password rejected: %t, %v", ok, err)
+	}
+	if ok, err := Verify(hash, []byte("correct horse!")); ok || err != nil {
+		t.Errorf("wrong password accepted: %t, %v", ok, err)
+	}
+	other, err := testArgon2id.Hash([]byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(hash, other) {
+		t.Error("the hashes must be salted")
+	}
+}
+
+func TestBcryptRoundTrip(t *testing.T) {
+	hasher := &BcryptHasher{Cost: bcrypt.MinCost}
+	hash, err := hasher.Hash([]byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Verify(hash, []byte("correct horse")); !ok || err != nil {
+		t.Errorf("correct password rejected: %t, %v", ok, err)
+	}
+	if ok, err := Verify(hash, []byte("correct horse!")); ok || err != nil {
+		t.Errorf("wrong password accepted: %t, %v", ok, err)
+	}
+	if _, err := hasher.Hash(bytes.Repeat([]byte("a"), bcryptMaxPasswordLength+1)); err != ErrPasswordTooLong {
+		t.Errorf("expected ErrPasswordTooLong, got %v", err)
+	}
+}
+
+func TestVerifyEmptyHash(t *testing.T) {
+	if ok, err := Verify(nil, []byte("")); ok || err != nil {
+		t.Errorf("empty hash matched: %t, %v", ok, err)
+	}
+}
+
+func TestVerifyInvalidHashes(t *testing.T) {
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	tests := []struct {
+		name string
+		hash string
+		err  error
+	}{
+		{"unknown algorithm", "$scrypt$ln=15,r=8,p=1$" + salt + "$" + key, ErrUnknownFormat},
+		{"plain text", "password", ErrUnknownFormat},
+		{"missing key", "$argon2id$v=19$m=64,t=1,p=1$" + salt, ErrInvalidHash},
+		{"additional part", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key + "$", ErrInvalidHash},
+		{"other version", "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key, ErrInvalidHash},
+		{"missing version", "$argon2id$m=64,t=1,p=1$" + salt + "$" + key + "$", ErrInvalidHash},
+		{"malformed parameters", "$argon2id$v=19$m=64;t=1;p=1$" + salt + "$" + key, ErrInvalidHash},
+		{"zero time", "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key, ErrInvalidHash},
+		{"zero threads", "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key, ErrInvalidHash},
+		{"invalid salt", "$argon2id$v=19$m=64,t=1,p=1$not*base64$" + key, ErrInvalidHash},
+		{"invalid key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$not*base64", ErrInvalidHash},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$", ErrInvalidHash},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := Verify([]byte(test.hash), []byte("password"))
+			if ok || err != test.err {
+				t.Errorf("expected %v, got %t, %v", test.err, ok, err)
+			}
+		})
+	}
+	if ok, err := Verify([]byte("$2a$10$truncated"), []byte("password")); ok || err == nil {
+		t.Errorf("truncated bcrypt hash: %t, %v", ok, err)
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	hash, err := testArgon2id.Hash([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testArgon2id.NeedsRehash(hash) {
+		t.Error("hash with the same parameters needs rehash")
+	}
+	changes := map[string]func(h *Argon2idHasher){
+		"time":        func(h *Argon2idHasher) { h.Time++ },
+		"memory":      func(h *Argon2idHasher) { h.Memory *= 2 },
+		"threads":     func(h *Argon2idHasher) { h.Threads++ },
+		"salt length": func(h *Argon2idHasher) { h.SaltLength++ },
+		"key length":  func(h *Argon2idHasher) { h.KeyLength++ },
+	}
+	for name, change := range changes {
+		hasher := *testArgon2id
+		change(&hasher)
+		if !hasher.NeedsRehash(hash) {
+			t.Errorf("changed %s doesn't need rehash", name)
+		}
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testArgon2id.NeedsRehash(bcryptHash) {
+		t.Error("bcrypt hash doesn't need rehash to Argon2id")
+	}
+	if !testArgon2id.NeedsRehash(nil) {
+		t.Error("empty hash doesn't need rehash")
+	}
+}
+
+func TestBcryptNeedsRehash(t *testing.T) {
+	hasher := &BcryptHasher{Cost: bcrypt.MinCost}
+	hash, err := hasher.Hash([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Error("hash with the same cost needs rehash")
+	}
+	if !(&BcryptHasher{Cost: bcrypt.MinCost + 1}).NeedsRehash(hash) {
+		t.Error("changed cost doesn't need rehash")
+	}
+	argon2idHash, err := testArgon2id.Hash([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasher.NeedsRehash(argon2idHash) {
+		t.Error("Argon2id hash doesn't need rehash to bcrypt")
+	}
+}
@@ -0,0 +1,133 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret "12345678901234567890" of the test vectors of RFC 6238.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfcVectors are the SHA-1 test vectors of RFC 6238, Appendix B, truncated to 6 digits.
+var rfcVectors = []struct {
+	time    int64
+	counter uint64
+	code    string
+}{
+	{59, 0x1, "287082"},
+	{1111111109, 0x23523EC, "081804"},
+	{1111111111, 0x23523ED, "050471"},
+	{1234567890, 0x273EF07, "005924"},
+	{2000000000, 0x3F940AA, "279037"},
+	{20000000000, 0x27BC86AA, "353130"},
+}
+
+func TestCodeForCounter(t *testing.T) {
+	for _, vector := range rfcVectors {
+		if counter := Counter(time.Unix(vector.time, 0)); counter != vector.counter {
+			t.Errorf("Counter(%d) = %X, expected %X", vector.time, counter, vector.counter)
+		}
+		code, err := CodeForCounter(rfcSecret, vector.counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != vector.code {
+			t.Errorf("CodeForCounter(%X) = %s, expected %s", vector.counter, code, vector.code)
+		}
+	}
+}
+
+func TestCodeForCounterAcceptsLowerCaseSecrets(t *testing.T) {
+	code, err := CodeForCounter(strings.ToLower(rfcSecret), 1)
+	if err != nil || code != "287082" {
+		t.Errorf("expected 287082, got %s (%v)", code, err)
+	}
+	if _, err := CodeForCounter("not base32!", 1); err == nil {
+		t.Error("expected an error for an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	current := Counter(at)
+	tests := []struct {
+		name    string
+		code    string
+		time    time.Time
+		ok      bool
+		counter uint64
+	}{
+		{"current step", "050471", at, true, current},
+		{"surrounding spaces", " 050471\n", at, true, current},
+		{"previous step", "081804", at, true, current - 1},
+		{"next step", "050471", at.Add(-Period), true, current},
+		{"two steps ago", "050471", at.Add(2 * Period), false, 0},
+		{"two steps ahead", "050471", at.Add(-2 * Period), false, 0},
+		{"wrong code", "050472", at, false, 0},
+		{"too short", "50471", at, false, 0},
+		{"too long", "0504710", at, false, 0},
+		{"empty", "", at, false, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			counter, ok := Validate(rfcSecret, test.code, test.time)
+			if ok != test.ok || counter != test.counter {
+				t.Errorf("Validate(%q) = %X, %t, expected %X, %t", test.code, counter, ok, test.counter, test.ok)
+			}
+		})
+	}
+}
+
+// TestValidateReturnsCounterOfCode checks that a code accepted again in the next time step returns
+// the same counter, so that storing the last counter prevents replays.
+func TestValidateReturnsCounterOfCode(t *testing.T) {
+	at := time.Unix(2000000000, 0)
+	first, ok := Validate(rfcSecret, "279037", at)
+	if !ok {
+		t.Fatal("code not accepted")
+	}
+	replayed, ok := Validate(rfcSecret, "279037", at.Add(Period))
+	if !ok || replayed != first {
+		t.Errorf("replayed code matched counter %X, expected %X", replayed, first)
+	}
+	next, err := CodeForCounter(rfcSecret, first+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter, ok := Validate(rfcSecret, next, at.Add(Period)); !ok || counter <= first {
+		t.Errorf("the code of the next step matched counter %X", counter)
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != secretSize {
+		t.Errorf("invalid secret %q: %v", secret, err)
+	}
+	other, err := GenerateSecret()
+	if err != nil || other == secret {
+		t.Errorf("secrets must be random: %s, %s", secret, other)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("ruck", "alice", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/ruck:alice" {
+		t.Errorf("unexpected URI %s", uri)
+	}
+	query := uri.Query()
+	for key, expected := range map[string]string{"secret": rfcSecret, "issuer": "ruck", "algorithm": "SHA1", "digits": "6", "period": "30"} {
+		if query.Get(key) != expected {
+			t.Errorf("expected %s=%s, got %s", key, expected, query.Get(key))
+		}
+	}
+}
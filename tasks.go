@@ -32,6 +32,15 @@ func (i Interval) String() string {
 	return fmt.Sprintf("every %d %s", i.Amount, i.Unit)
 }
 
+// IsValid returns true if Next can be used.
+func (i Interval) IsValid() bool {
+	switch i.Unit {
+	case Days, Weeks, Months, Years:
+		return i.Amount > 0
+	}
+	return false
+}
+
 func (i Interval) Next(day time.Time) time.Time {
 	switch i.Unit {
 	case Days:
@@ -57,6 +66,18 @@ type Task struct {
 	Assignee      *User          `json:"assignee,omitempty"`
 	AssigneeName  string         `json:"assignee_name,omitempty"`
 	DueDate       time.Time      `json:"due_date"`
+	// Queue is the order of the turns of the members, see RotationQueue.
+	Queue []string `json:"queue,omitempty"`
+}
+
+// TaskRequest creates a task in a group.
+type TaskRequest struct {
+	Name     string   `json:"name"`
+	Interval Interval `json:"interval"`
+	// AssigneeName is the member whose turn is first, a random member if it is empty and Open isn't set.
+	AssigneeName string `json:"assignee_name,omitempty"`
+	// Open creates the task without assignee, so that anyone can take it.
+	Open bool `json:"open,omitempty"`
 }
 
 func (g *Group) NextName(after string) string {
@@ -70,13 +91,94 @@ func (g *Group) NextName(after string) string {
 	return g.MemberNames[0]
 }
 
-// AssignNext sets the assignee name to the next of the group.
+func indexOf(s []string, e string) int {
+	for i, a := range s {
+		if a == e {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveToBack returns the queue with the member at the end if the member is in the queue.
+func moveToBack(queue []string, name string) []string {
+	i := indexOf(queue, name)
+	if i < 0 {
+		return queue
+	}
+	result := make([]string, 0, len(queue))
+	result = append(result, queue[:i]...)
+	result = append(result, queue[i+1:]...)
+	return append(result, name)
+}
+
+// RotationQueue returns the members of the group in the order of their turns, the turn of the
+// first member is the current one. It differs from the assignee if another member claimed the
+// task or it was escalated. Members who left the group are removed and new members are added at
+// the end. Without a stored queue the order of the group starting with the assignee is used.
 // panics if the t.Group is null
-func (t *Task) AssignNext() {
+func (t *Task) RotationQueue() []string {
 	if t.Group == nil {
 		panic("Group not set")
 	}
-	t.AssigneeName = t.Group.NextName(t.AssigneeName)
+	members := t.Group.MemberNames
+	source := t.Queue
+	if len(source) == 0 {
+		start := indexOf(members, t.AssigneeName)
+		if start < 0 {
+			start = 0
+		}
+		source = make([]string, 0, len(members))
+		source = append(source, members[start:]...)
+		source = append(source, members[:start]...)
+	}
+	queue := make([]string, 0, len(members))
+	for _, name := range source {
+		if indexOf(members, name) >= 0 && indexOf(queue, name) < 0 {
+			queue = append(queue, name)
+		}
+	}
+	for _, name := range members {
+		if indexOf(queue, name) < 0 {
+			queue = append(queue, name)
+		}
+	}
+	return queue
+}
+
+// NextInQueue returns the member whose turn comes after the one of the given member.
+// panics if the t.Group is null
+func (t *Task) NextInQueue(name string) string {
+	queue := t.RotationQueue()
+	if len(queue) == 0 {
+		return ""
+	}
+	return queue[(indexOf(queue, name)+1)%len(queue)]
+}
+
+// Rotate updates the queue after the executor completed the task and assigns the task to the
+// member whose turn is next. The executor moves to the end of the queue. If it was another
+// member's turn, the cover policy of the group decides whether that member keeps the turn. Nobody
+// covers for an open task, so the policy doesn't apply if the task isn't assigned.
+// panics if the t.Group is null
+func (t *Task) Rotate(executorName string) {
+	queue := t.RotationQueue()
+	if len(queue) == 0 {
+		return
+	}
+	if owner := queue[0]; t.AssigneeName != "" && owner != executorName {
+		switch t.Group.CoverPolicy {
+		case CoverSwapTurns:
+			if i := indexOf(queue, executorName); i >= 0 {
+				queue[0], queue[i] = queue[i], queue[0]
+			}
+		case CoverSkipTurn:
+			queue = moveToBack(queue, owner)
+		}
+	}
+	queue = moveToBack(queue, executorName)
+	t.Queue = queue
+	t.AssigneeName = queue[0]
 	t.Assignee = nil
 }
 
@@ -96,7 +198,7 @@ func NewTask(name string, intervalType IntervalUnit, interval uint32) *Task {
 
 func (t Task) String() string {
 	return fmt.Sprintf(
-		"Task{ ID=%s, Name=%s, Interval=%s, LastExecution=%s DueDate=%s AssigneeName=%s groupId=%s }",
+		"Task{ ID=%s, Name=%s, Interval=%s, LastExecution=%v DueDate=%s AssigneeName=%s groupId=%s }",
 		t.ID, t.Name, t.Interval, t.LastExecution, t.DueDate, t.AssigneeName, t.GroupID)
 }
 
@@ -0,0 +1,108 @@
+package ruck
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRotationQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		members  []string
+		queue    []string
+		assignee string
+		expected []string
+	}{
+		{"no stored queue starts with the assignee", []string{"a", "b", "c"}, nil, "b", []string{"b", "c", "a"}},
+		{"no stored queue of an open task", []string{"a", "b", "c"}, nil, "", []string{"a", "b", "c"}},
+		{"no stored queue of an assignee who left", []string{"a", "b", "c"}, nil, "x", []string{"a", "b", "c"}},
+		{"stored queue", []string{"a", "b", "c"}, []string{"c", "a", "b"}, "a", []string{"c", "a", "b"}},
+		{"joined members are added at the end", []string{"a", "b", "c", "d"}, []string{"b", "a"}, "b", []string{"b", "a", "c", "d"}},
+		{"members who left are removed", []string{"a", "c"}, []string{"b", "c", "a"}, "b", []string{"c", "a"}},
+		{"duplicates are removed", []string{"a", "b"}, []string{"b", "a", "b"}, "b", []string{"b", "a"}},
+		{"no members", []string{}, []string{"a"}, "a", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := &Task{Group: &Group{MemberNames: test.members}, Queue: test.queue, AssigneeName: test.assignee}
+			if queue := task.RotationQueue(); !reflect.DeepEqual(queue, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, queue)
+			}
+		})
+	}
+}
+
+func TestNextInQueue(t *testing.T) {
+	task := &Task{Group: &Group{MemberNames: []string{"a", "b", "c"}}, Queue: []string{"c", "a", "b"}}
+	for name, expected := range map[string]string{"c": "a", "a": "b", "b": "c", "x": "c", "": "c"} {
+		if next := task.NextInQueue(name); next != expected {
+			t.Errorf("NextInQueue(%q) = %q, expected %q", name, next, expected)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	members := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		name     string
+		members  []string
+		queue    []string
+		assignee string
+		policy   CoverPolicy
+		executor string
+		// expected is the queue after the rotation, its first member is the new assignee
+		expected []string
+	}{
+		// the owner of the turn completes the task
+		{"owner without stored queue", members, nil, "b", CoverKeepTurn, "b", []string{"c", "d", "a", "b"}},
+		{"owner with stored queue", members, []string{"c", "a", "d", "b"}, "c", CoverKeepTurn, "c", []string{"a", "d", "b", "c"}},
+		{"owner ignores the policy", members, []string{"a", "b", "c", "d"}, "a", CoverSkipTurn, "a", []string{"b", "c", "d", "a"}},
+		// another member covers for the owner
+		{"cover keeps the turn", members, nil, "a", CoverKeepTurn, "c", []string{"a", "b", "d", "c"}},
+		{"cover swaps the turns", members, nil, "a", CoverSwapTurns, "c", []string{"b", "a", "d", "c"}},
+		{"cover skips the turn", members, nil, "a", CoverSkipTurn, "c", []string{"b", "d", "a", "c"}},
+		{"cover of a claimed task keeps the turn", members, []string{"a", "b", "c", "d"}, "c", CoverKeepTurn, "c", []string{"a", "b", "d", "c"}},
+		{"cover of a claimed task swaps the turns", members, []string{"a", "b", "c", "d"}, "c", CoverSwapTurns, "c", []string{"b", "a", "d", "c"}},
+		{"cover of a claimed task skips the turn", members, []string{"a", "b", "c", "d"}, "c", CoverSkipTurn, "c", []string{"b", "d", "a", "c"}},
+		{"next member covers and swaps", members, nil, "a", CoverSwapTurns, "b", []string{"a", "c", "d", "b"}},
+		{"executor who isn't a member", members, nil, "a", CoverSwapTurns, "x", []string{"a", "b", "c", "d"}},
+		// nobody covers for an open task
+		{"open task without stored queue keeps the turn", members, nil, "", CoverKeepTurn, "c", []string{"a", "b", "d", "c"}},
+		{"open task without stored queue isn't swapped", members, nil, "", CoverSwapTurns, "c", []string{"a", "b", "d", "c"}},
+		{"open task without stored queue isn't skipped", members, nil, "", CoverSkipTurn, "c", []string{"a", "b", "d", "c"}},
+		{"open task with stored queue", members, []string{"d", "c", "b", "a"}, "", CoverSkipTurn, "b", []string{"d", "c", "a", "b"}},
+		// members joining and leaving
+		{"joined member gets the last turn", []string{"a", "b", "c"}, []string{"b", "a"}, "b", CoverKeepTurn, "b", []string{"a", "c", "b"}},
+		{"member who left loses the turn", []string{"a", "b"}, []string{"c", "a", "b"}, "a", CoverKeepTurn, "a", []string{"b", "a"}},
+		{"assignee who left is skipped", []string{"a", "b", "c"}, []string{"d", "b", "c", "a"}, "d", CoverSkipTurn, "c", []string{"a", "b", "c"}},
+		{"single member", []string{"a"}, nil, "a", CoverSwapTurns, "a", []string{"a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := &Task{
+				Group:        &Group{MemberNames: test.members, CoverPolicy: test.policy},
+				Queue:        test.queue,
+				AssigneeName: test.assignee,
+				Assignee:     &User{Name: test.assignee},
+			}
+			task.Rotate(test.executor)
+			if !reflect.DeepEqual(task.Queue, test.expected) {
+				t.Errorf("expected queue %v, got %v", test.expected, task.Queue)
+			}
+			if task.AssigneeName != test.expected[0] {
+				t.Errorf("expected assignee %s, got %s", test.expected[0], task.AssigneeName)
+			}
+			if task.Assignee != nil {
+				t.Errorf("the loaded assignee %v wasn't reset", task.Assignee)
+			}
+		})
+	}
+}
+
+func TestRotateWithoutMembers(t *testing.T) {
+	task := &Task{Group: &Group{}, AssigneeName: "a"}
+	task.Rotate("a")
+	if task.AssigneeName != "a" || task.Queue != nil {
+		t.Errorf("unexpected rotation to %s with queue %v", task.AssigneeName, task.Queue)
+	}
+}
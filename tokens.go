@@ -0,0 +1,45 @@
+package ruck
+
+import "time"
+
+// TokenScope limits what can be done with a token.
+type TokenScope string
+
+var (
+	// ScopeSession is the scope of tokens issued by logging in. They allow everything.
+	ScopeSession = TokenScope("session")
+	// ScopeFull allows everything except managing the account.
+	ScopeFull = TokenScope("full")
+	// ScopeComplete allows reading and completing tasks.
+	ScopeComplete = TokenScope("complete")
+	// ScopeRead allows only reading.
+	ScopeRead = TokenScope("read")
+)
+
+// PersonalAccessTokenScopes are the scopes which can be used for personal access tokens.
+var PersonalAccessTokenScopes = []TokenScope{ScopeRead, ScopeComplete, ScopeFull}
+
+// PersonalAccessToken is a long-lived token for scripts and integrations.
+type PersonalAccessToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scope      TokenScope `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at,omitempty"`
+	// Token is only set in the response to the creation.
+	Token string `json:"token,omitempty"`
+}
+
+type PersonalAccessTokenRequest struct {
+	Name  string     `json:"name"`
+	Scope TokenScope `json:"scope"`
+}
+
+func (s TokenScope) IsValidForPersonalAccessToken() bool {
+	for _, scope := range PersonalAccessTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
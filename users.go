@@ -1,17 +1,33 @@
 package ruck
 
+import "time"
+
 type Credentials struct {
 	Name     string
 	Password []byte
 }
 
 type DecodedToken struct {
+	ID       string
 	UserName string
+	Scope    TokenScope
+	IssuedAt time.Time
+	Expiry   time.Time
 }
 
 type AuthenticationResult struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	User         *User     `json:"user"`
+	// TwoFactorRequired is set instead of the tokens if a one-time code is required to complete the
+	// login. The code has to be sent together with the TwoFactorToken.
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	TwoFactorToken    string `json:"two_factor_token,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type PasswordPolicy struct {
@@ -27,6 +43,93 @@ type User struct {
 	EmailVerified bool
 	IsDisabled    bool
 	PasswordHash  []byte `json:"-"`
+	// PasswordChangedAt is the time of the last password change. Tokens issued before are invalid.
+	PasswordChangedAt time.Time `json:"-"`
+	DisplayName       string    `json:",omitempty"`
+	// TimeZone is an IANA time zone name, e.g. Europe/Zurich
+	TimeZone string `json:",omitempty"`
+	// Language is a BCP 47 language tag, e.g. de-CH
+	Language string `json:",omitempty"`
+}
+
+// DeletedUserName replaces the name of deleted users in the task history.
+const DeletedUserName = "[deleted]"
+
+// AccountUpdateRequest changes the profile of the user. Only the set fields are changed.
+type AccountUpdateRequest struct {
+	DisplayName *string `json:",omitempty"`
+	// Email requires a new verification of the address.
+	Email    *string `json:",omitempty"`
+	TimeZone *string `json:",omitempty"`
+	Language *string `json:",omitempty"`
+}
+
+// AccountDeletionRequest confirms the deletion of the account with the password.
+type AccountDeletionRequest struct {
+	Password []byte
+}
+
+// AccountExport contains all data stored about the user.
+type AccountExport struct {
+	User                 *User                 `json:"user"`
+	Groups               []*Group              `json:"groups"`
+	Tasks                []*Task               `json:"tasks"`
+	Executions           []*TaskExecution      `json:"executions"`
+	NotificationSettings *NotificationSettings `json:"notification_settings"`
+	PushSettings         *PushSettings         `json:"push_settings,omitempty"`
+}
+
+// NotificationSettings select the mails a user receives. Mails are only sent to verified addresses.
+type NotificationSettings struct {
+	// Reminders are sent when a task of the user is due soon or overdue.
+	Reminders bool `json:"reminders"`
+	// DailyDigest lists the due and overdue tasks of the user every morning.
+	DailyDigest bool `json:"daily_digest"`
+	// WeeklySummary lists the completed and upcoming tasks of the groups of the user every Monday.
+	WeeklySummary bool `json:"weekly_summary"`
+}
+
+// DefaultNotificationSettings apply to users who didn't change their settings.
+var DefaultNotificationSettings = NotificationSettings{Reminders: true}
+
+// NotificationSettingsUpdateRequest changes the set fields of the notification settings.
+type NotificationSettingsUpdateRequest struct {
+	Reminders     *bool `json:"reminders,omitempty"`
+	DailyDigest   *bool `json:"daily_digest,omitempty"`
+	WeeklySummary *bool `json:"weekly_summary,omitempty"`
+}
+
+type PushService string
+
+const (
+	// PushServiceNtfy publishes to an ntfy topic URL, e.g. https://ntfy.sh/my-topic.
+	PushServiceNtfy PushService = "ntfy"
+	// PushServiceGotify sends messages to a Gotify server with an application token.
+	PushServiceGotify PushService = "gotify"
+	// PushServiceJSON posts a JSON object to an arbitrary URL.
+	PushServiceJSON PushService = "json"
+)
+
+func (s PushService) IsValid() bool {
+	switch s {
+	case PushServiceNtfy, PushServiceGotify, PushServiceJSON:
+		return true
+	}
+	return false
+}
+
+// PushSettings configure the push notifications of a user.
+type PushSettings struct {
+	Service PushService `json:"service"`
+	URL     string      `json:"url"`
+	// Token is the access token of ntfy, the application token of Gotify or the bearer token of
+	// JSON endpoints. The server never returns it.
+	Token string `json:"token,omitempty"`
+	// Reminders are pushed when a task of the user is due soon or overdue and when an overdue task
+	// of a group of the user is escalated.
+	Reminders bool `json:"reminders"`
+	// Assignments are pushed when a task is assigned to the user after someone completed it.
+	Assignments bool `json:"assignments"`
 }
 
 type RegistrationRequest struct {
@@ -34,4 +137,49 @@ type RegistrationRequest struct {
 	Email                string
 	Password             []byte
 	PasswordConfirmation []byte
+	// RegistrationCode or GroupID are required if the registration is invite-only.
+	RegistrationCode string `json:",omitempty"`
+	// GroupID is the group the user is invited to and joins after the registration.
+	GroupID string `json:",omitempty"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword      []byte
+	Password             []byte
+	PasswordConfirmation []byte
+}
+
+type PasswordForgottenRequest struct {
+	Name string
+}
+
+type PasswordResetRequest struct {
+	Token                string
+	Password             []byte
+	PasswordConfirmation []byte
+}
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorLoginRequest struct {
+	Token string `json:"token"`
+	// Code is either a one-time code or a recovery code
+	Code string `json:"code"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// SSOTokenRequest exchanges the code of a single sign-on login for tokens.
+type SSOTokenRequest struct {
+	Code         string `json:"code"`
+	CodeVerifier string `json:"code_verifier"`
 }
@@ -0,0 +1,4 @@
+package ruck
+
+// Version of ruck, set when building with -ldflags "-X github.com/coffeemakr/ruck.Version=..."
+var Version = "dev"